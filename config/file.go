@@ -0,0 +1,582 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	ConfigDir    = configDir()
+	ConfigFile   = filepath.Join(ConfigDir, "config.json")
+	StationsFile = filepath.Join(ConfigDir, "stations.json")
+)
+
+// settingsFile data type
+type settingsFile struct {
+	AlarmFile        *string  `json:"alarmFile"`
+	AudioDevice      *string  `json:"audioDevice"`
+	Log              *string  `json:"log"`
+	MaxMenuTries     *int     `json:"maxMenuTries"`
+	PlayTimeout      *string  `json:"playTimeout"`
+	Player           *string  `json:"player"`
+	PlayerArgs       []string `json:"playerArgs"`
+	PlayerRestartMax *int     `json:"playerRestartMax"`
+	ReconnectDelay   *string  `json:"reconnectDelay"`
+	ReconnectMax     *int     `json:"reconnectMax"`
+	Resume           *bool    `json:"resume"`
+	VolumeAbsolute   *int     `json:"volumeAbsolute"`
+	VolumeMax        *int     `json:"volumeMax"`
+	VolumeMin        *int     `json:"volumeMin"`
+	WmFile           *string  `json:"wmFile"`
+}
+
+// StationError data type
+type StationError struct {
+	Field string
+	Msg   string
+}
+
+// fileError data type
+type fileError struct {
+	file string
+	line int
+	col  int
+	msg  string
+}
+
+// Error returns the file error message with its line and column
+func (e *fileError) Error() string {
+	if e.line == 0 {
+		return fmt.Sprintf("config: error: %s: %s\n", e.file, e.msg)
+	}
+	return fmt.Sprintf("config: error: %s:%d:%d: %s\n", e.file, e.line, e.col, e.msg)
+}
+
+// Error returns the station error message
+func (e *StationError) Error() string {
+	return fmt.Sprintf("station: error: %s\n", e.Msg)
+}
+
+// configDir returns the user configuration directory
+func configDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" && filepath.IsAbs(dir) {
+		return filepath.Join(dir, ProgName)
+	}
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(homeDir, ".config", ProgName)
+	}
+	return filepath.Join(tmpDir, userName+"-"+ProgName+"-config")
+}
+
+// Load loads the user configuration files, keeping the built-in defaults when they do not exist
+func Load() error {
+	logFile, wmFile := Log, WmFile
+	if errLs := loadSettings(ConfigFile); errLs != nil {
+		return errLs
+	}
+	// the shared log and wm files get the profile name, two profiles never write the same files
+	if Profile != "" {
+		if Log != logFile {
+			Log = profileFile(Log, Profile)
+		}
+		if WmFile != wmFile {
+			WmFile = profileFile(WmFile, Profile)
+		}
+	}
+	if ProfileConfigFile != "" {
+		if errLs := loadSettings(ProfileConfigFile); errLs != nil {
+			return errLs
+		}
+	}
+	streams, mirrors, errLs := LoadStations(StationsFile)
+	if errLs != nil {
+		return errLs
+	}
+	// a profile without its own stations file uses the default one until it saves its stations
+	if streams == nil && Profile != "" {
+		if streams, mirrors, errLs = LoadStations(filepath.Join(ConfigDir, "stations.json")); errLs != nil {
+			return errLs
+		}
+	}
+	if streams != nil {
+		SetStations(streams, mirrors)
+	}
+	return nil
+}
+
+// loadSettings loads the settings file
+func loadSettings(file string) error {
+	data, errRf := os.ReadFile(file)
+	if os.IsNotExist(errRf) {
+		return nil
+	} else if errRf != nil {
+		return errRf
+	}
+	var sf settingsFile
+	if errDj := decodeJson(file, data, &sf); errDj != nil {
+		return errDj
+	}
+	offsets := jsonOffsets(data)
+	fail := func(path string, format string, v ...interface{}) error {
+		return newFileError(file, data, offsets, path, fmt.Sprintf(format, v...))
+	}
+	player, playerArgs := Player, PlayerArgs
+	if sf.Player != nil {
+		if strings.TrimSpace(*sf.Player) == "" {
+			return fail("player", "player cannot be empty")
+		}
+		player = *sf.Player
+	}
+	if sf.PlayerArgs != nil {
+		for num, arg := range sf.PlayerArgs {
+			if strings.HasPrefix(arg, "--input-ipc-server") {
+				return fail("playerArgs."+strconv.Itoa(num), "'%s' is set by %s, remove it", arg, ProgName)
+			}
+		}
+		playerArgs = append([]string{}, sf.PlayerArgs...)
+		if !hasArg(playerArgs, "--idle") {
+			playerArgs = append(playerArgs, "--idle=yes")
+		}
+		playerArgs = append(playerArgs, "--input-ipc-server="+PlayerControlFile)
+	}
+	volMin, volMax, volAbs := VolumeMin, VolumeMax, VolumeAbsolute
+	if sf.VolumeMin != nil {
+		volMin = *sf.VolumeMin
+	}
+	if sf.VolumeMax != nil {
+		volMax = *sf.VolumeMax
+	}
+	if sf.VolumeAbsolute != nil {
+		volAbs = *sf.VolumeAbsolute
+	}
+	if volMin < 0 {
+		return fail("volumeMin", "volumeMin '%d' cannot be lower than 0", volMin)
+	}
+	if volMax <= volMin {
+		return fail("volumeMax", "volumeMax '%d' must be greater than volumeMin '%d'", volMax, volMin)
+	}
+	if volAbs < 0 || volAbs > 1000 {
+		return fail("volumeAbsolute", "volumeAbsolute '%d' must be between 0 and 1000", volAbs)
+	}
+	maxMenuTries := MaxMenuTries
+	if sf.MaxMenuTries != nil {
+		if *sf.MaxMenuTries < 1 {
+			return fail("maxMenuTries", "maxMenuTries '%d' must be greater than 0", *sf.MaxMenuTries)
+		}
+		maxMenuTries = *sf.MaxMenuTries
+	}
+	playTimeout := PlayTimeout
+	if sf.PlayTimeout != nil {
+		timeout, errPd := time.ParseDuration(*sf.PlayTimeout)
+		if errPd != nil || timeout < 0 {
+			return fail("playTimeout", "playTimeout '%s' is not a valid duration", *sf.PlayTimeout)
+		}
+		playTimeout = timeout
+	}
+	reconnectDelay, reconnectMax := ReconnectDelay, ReconnectMax
+	if sf.ReconnectDelay != nil {
+		delay, errPd := time.ParseDuration(*sf.ReconnectDelay)
+		if errPd != nil || delay <= 0 {
+			return fail("reconnectDelay", "reconnectDelay '%s' is not a valid duration", *sf.ReconnectDelay)
+		}
+		reconnectDelay = delay
+	}
+	if sf.ReconnectMax != nil {
+		if *sf.ReconnectMax < 0 {
+			return fail("reconnectMax", "reconnectMax '%d' cannot be lower than 0", *sf.ReconnectMax)
+		}
+		reconnectMax = *sf.ReconnectMax
+	}
+	playerRestartMax := PlayerRestartMax
+	if sf.PlayerRestartMax != nil {
+		if *sf.PlayerRestartMax < 0 {
+			return fail("playerRestartMax", "playerRestartMax '%d' cannot be lower than 0", *sf.PlayerRestartMax)
+		}
+		playerRestartMax = *sf.PlayerRestartMax
+	}
+	resume := Resume
+	if sf.Resume != nil {
+		resume = *sf.Resume
+	}
+	audioDevice := AudioDevice
+	if sf.AudioDevice != nil {
+		audioDevice = strings.TrimSpace(*sf.AudioDevice)
+	}
+	alarmFile, logFile, wmFile := AlarmFile, Log, WmFile
+	if sf.AlarmFile != nil {
+		if *sf.AlarmFile != "" && !filepath.IsAbs(*sf.AlarmFile) {
+			return fail("alarmFile", "alarmFile '%s' must be an absolute path", *sf.AlarmFile)
+		}
+		alarmFile = *sf.AlarmFile
+	}
+	if sf.Log != nil {
+		if !filepath.IsAbs(*sf.Log) {
+			return fail("log", "log '%s' must be an absolute path", *sf.Log)
+		}
+		logFile = *sf.Log
+	}
+	if sf.WmFile != nil {
+		if !filepath.IsAbs(*sf.WmFile) {
+			return fail("wmFile", "wmFile '%s' must be an absolute path", *sf.WmFile)
+		}
+		wmFile = *sf.WmFile
+	}
+	Player, PlayerArgs, PlayerRestartMax = player, playerArgs, playerRestartMax
+	VolumeMin, VolumeMax, VolumeAbsolute = volMin, volMax, volAbs
+	MaxMenuTries = maxMenuTries
+	PlayTimeout = playTimeout
+	ReconnectDelay, ReconnectMax = reconnectDelay, reconnectMax
+	Resume = resume
+	AudioDevice = audioDevice
+	AlarmFile, Log, WmFile = alarmFile, logFile, wmFile
+	return nil
+}
+
+// LoadStations loads the stations file and their mirror urls, returns nil streams when the file does not exist
+func LoadStations(file string) (map[int]map[string]string, map[int][]string, error) {
+	data, errRf := os.ReadFile(file)
+	if os.IsNotExist(errRf) {
+		return nil, nil, nil
+	} else if errRf != nil {
+		return nil, nil, errRf
+	}
+	var raw map[string]map[string]json.RawMessage
+	if errDj := decodeJson(file, data, &raw); errDj != nil {
+		return nil, nil, errDj
+	}
+	offsets := jsonOffsets(data)
+	streams := make(map[int]map[string]string, len(raw))
+	mirrors := make(map[int][]string)
+	for key, fields := range raw {
+		fail := func(path string, format string, v ...interface{}) error {
+			return newFileError(file, data, offsets, path, fmt.Sprintf(format, v...))
+		}
+		id, errSa := strconv.Atoi(key)
+		if errSa != nil || id < 1 || strconv.Itoa(id) != key {
+			return nil, nil, fail(key, "station id '%s' must be a positive number", key)
+		}
+		if fields == nil {
+			return nil, nil, fail(key, "station '%d' must be an object", id)
+		}
+		stream := make(map[string]string, len(fields))
+		var urls []string
+		for field, value := range fields {
+			if field == "mirrors" {
+				if errJu := json.Unmarshal(value, &urls); errJu != nil {
+					return nil, nil, fail(key+"."+field, "station '%d' mirrors must be a list of urls", id)
+				}
+				continue
+			}
+			var text string
+			if errJu := json.Unmarshal(value, &text); errJu != nil {
+				return nil, nil, fail(key+"."+field, "station '%d' %s must be a string", id, field)
+			}
+			stream[field] = text
+		}
+		if errCs := CheckStation(id, stream, urls); errCs != nil {
+			var errStation *StationError
+			if !errors.As(errCs, &errStation) {
+				return nil, nil, errCs
+			}
+			path := key
+			if errStation.Field != "" {
+				path = key + "." + errStation.Field
+			}
+			return nil, nil, fail(path, "%s", errStation.Msg)
+		}
+		streams[id] = stream
+		if len(urls) > 0 {
+			mirrors[id] = urls
+		}
+	}
+	return streams, mirrors, nil
+}
+
+// CheckStation checks the station id, fields and mirror urls
+func CheckStation(id int, stream map[string]string, mirrors []string) error {
+	if id < 1 {
+		return &StationError{Msg: fmt.Sprintf("station id '%d' must be a positive number", id)}
+	}
+	for field := range stream {
+		if field != "name" && field != "nameIcy" && field != "url" {
+			return &StationError{Field: field, Msg: fmt.Sprintf("station '%d' has unknown field '%s'", id, field)}
+		}
+	}
+	if strings.TrimSpace(stream["name"]) == "" {
+		return &StationError{Msg: fmt.Sprintf("station '%d' needs a name", id)}
+	}
+	if u, errUp := url.Parse(stream["url"]); errUp != nil || u.Scheme == "" || u.Host == "" {
+		return &StationError{Field: "url", Msg: fmt.Sprintf("station '%d' url '%s' is not valid", id, stream["url"])}
+	}
+	for num, mirror := range mirrors {
+		if u, errUp := url.Parse(mirror); errUp != nil || u.Scheme == "" || u.Host == "" {
+			return &StationError{Field: "mirrors." + strconv.Itoa(num), Msg: fmt.Sprintf("station '%d' mirror url '%s' is not valid", id, mirror)}
+		}
+	}
+	return nil
+}
+
+// StationUrls returns the station url followed by its mirror urls
+func StationUrls(id int) []string {
+	streams, mirrors := Stations()
+	stream, ok := streams[id]
+	if !ok {
+		return nil
+	}
+	urls := []string{stream["url"]}
+	seen := map[string]bool{stream["url"]: true}
+	for _, mirror := range mirrors[id] {
+		if !seen[mirror] {
+			seen[mirror] = true
+			urls = append(urls, mirror)
+		}
+	}
+	return urls
+}
+
+// SaveStations writes the streams and their mirror urls into the stations file atomically, ordered by their ids
+func SaveStations(file string, streams map[int]map[string]string, mirrors map[int][]string) error {
+	ids := make([]int, 0, len(streams))
+	for id, stream := range streams {
+		if errCs := CheckStation(id, stream, mirrors[id]); errCs != nil {
+			return errCs
+		}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	var data bytes.Buffer
+	data.WriteString("{")
+	for num, id := range ids {
+		fields := make(map[string]interface{}, len(streams[id])+1)
+		for key, value := range streams[id] {
+			fields[key] = value
+		}
+		if len(mirrors[id]) > 0 {
+			fields["mirrors"] = mirrors[id]
+		}
+		stream, errJm := json.MarshalIndent(fields, "    ", "    ")
+		if errJm != nil {
+			return errJm
+		}
+		if num > 0 {
+			data.WriteString(",")
+		}
+		data.WriteString(fmt.Sprintf("\n    \"%d\": %s", id, stream))
+	}
+	data.WriteString("\n}\n")
+	return writeFileAtomic(file, data.Bytes(), 0600)
+}
+
+// LockUpdate takes the exclusive lock of the file updates shared by every process, it is held until calling unlock
+func LockUpdate(file string) (func() error, error) {
+	if errMa := os.MkdirAll(filepath.Dir(file), 0700); errMa != nil {
+		return nil, errMa
+	}
+	fh, errOf := os.OpenFile(file+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if errOf != nil {
+		return nil, errOf
+	}
+	if errFl := syscall.Flock(int(fh.Fd()), syscall.LOCK_EX); errFl != nil {
+		fh.Close()
+		return nil, errFl
+	}
+	return fh.Close, nil
+}
+
+// writeFileAtomic writes the data into a temporary file and renames it to file
+func writeFileAtomic(file string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(file)
+	if errMa := os.MkdirAll(dir, 0700); errMa != nil {
+		return errMa
+	}
+	tmpFile, errCt := os.CreateTemp(dir, "."+filepath.Base(file)+"-*")
+	if errCt != nil {
+		return errCt
+	}
+	tmpName := tmpFile.Name()
+	defer func() {
+		if _, errOs := os.Stat(tmpName); errOs == nil {
+			os.Remove(tmpName)
+		}
+	}()
+	if _, errTw := tmpFile.Write(data); errTw != nil {
+		tmpFile.Close()
+		return errTw
+	}
+	if errTs := tmpFile.Sync(); errTs != nil {
+		tmpFile.Close()
+		return errTs
+	}
+	if errTc := tmpFile.Chmod(perm); errTc != nil {
+		tmpFile.Close()
+		return errTc
+	}
+	if errTc := tmpFile.Close(); errTc != nil {
+		return errTc
+	}
+	return os.Rename(tmpName, file)
+}
+
+// decodeJson decodes the json data into v, returning errors with their line and column
+func decodeJson(file string, data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	errDd := decoder.Decode(v)
+	if errDd == nil {
+		if _, errTo := decoder.Token(); errTo != io.EOF {
+			return newFileErrorAt(file, data, decoder.InputOffset(), "unexpected data after the top-level value")
+		}
+		return nil
+	}
+	var (
+		errSyntax *json.SyntaxError
+		errType   *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(errDd, &errSyntax):
+		return newFileErrorAt(file, data, errSyntax.Offset-1, errSyntax.Error())
+	case errors.As(errDd, &errType):
+		msg := fmt.Sprintf("'%s' must be %s, not %s", errType.Field, typeName(errType.Type.String()), errType.Value)
+		if errType.Field == "" {
+			msg = fmt.Sprintf("value must be %s, not %s", typeName(errType.Type.String()), errType.Value)
+		}
+		return newFileErrorAt(file, data, errType.Offset, msg)
+	case errDd == io.EOF:
+		return &fileError{file: file, msg: "empty file"}
+	}
+	regexField := regexp.MustCompile(`^json: unknown field "(.*)"$`)
+	if match := regexField.FindStringSubmatch(errDd.Error()); match != nil {
+		offsets := jsonOffsets(data)
+		return newFileError(file, data, offsets, match[1], fmt.Sprintf("unknown field '%s'", match[1]))
+	}
+	return &fileError{file: file, msg: strings.TrimPrefix(errDd.Error(), "json: ")}
+}
+
+// typeName returns a readable name for the go type
+func typeName(name string) string {
+	switch {
+	case strings.HasPrefix(name, "[]"):
+		return "a list"
+	case strings.HasPrefix(name, "map["), strings.HasPrefix(name, "config."):
+		return "an object"
+	case strings.Contains(name, "int"):
+		return "a number"
+	default:
+		return "a " + name
+	}
+}
+
+// newFileError returns a file error located at the json path
+func newFileError(file string, data []byte, offsets map[string]int64, path string, msg string) error {
+	offset, ok := offsets[path]
+	if !ok {
+		return &fileError{file: file, msg: msg}
+	}
+	return newFileErrorAt(file, data, offset, msg)
+}
+
+// newFileErrorAt returns a file error located at the byte offset
+func newFileErrorAt(file string, data []byte, offset int64, msg string) error {
+	line, col := lineCol(data, offset)
+	return &fileError{file: file, line: line, col: col, msg: msg}
+}
+
+// lineCol returns the line and column numbers of the byte offset
+func lineCol(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	} else if offset < 0 {
+		offset = 0
+	}
+	line, col := 1, 1
+	for _, b := range data[:offset] {
+		if b == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return line, col
+}
+
+// hasArg checks if the option is in the command line arguments
+func hasArg(args []string, option string) bool {
+	for _, arg := range args {
+		if arg == option || strings.HasPrefix(arg, option+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonOffsets returns the byte offset where every json path starts, paths are keys joined by dots
+func jsonOffsets(data []byte) map[string]int64 {
+	offsets := make(map[string]int64)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	var walk func(path string) error
+	walk = func(path string) error {
+		tok, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'):
+			for decoder.More() {
+				start := skipSpace(data, decoder.InputOffset())
+				key, errTo := decoder.Token()
+				if errTo != nil {
+					return errTo
+				}
+				keyPath := fmt.Sprintf("%v", key)
+				if path != "" {
+					keyPath = path + "." + keyPath
+				}
+				offsets[keyPath] = start
+				if errWa := walk(keyPath); errWa != nil {
+					return errWa
+				}
+			}
+			_, err = decoder.Token()
+		case json.Delim('['):
+			for num := 0; decoder.More(); num++ {
+				offsets[path+"."+strconv.Itoa(num)] = skipSpace(data, decoder.InputOffset())
+				if errWa := walk(path + "." + strconv.Itoa(num)); errWa != nil {
+					return errWa
+				}
+			}
+			_, err = decoder.Token()
+		}
+		return err
+	}
+	_ = walk("")
+	return offsets
+}
+
+// skipSpace returns the offset of the next json token after whitespace and separators
+func skipSpace(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) {
+		switch data[offset] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+			offset++
+		default:
+			return offset
+		}
+	}
+	return offset
+}
@@ -0,0 +1,109 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile writes the content into a temporary file
+func writeFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoadSettings(t *testing.T) {
+	player, playerArgs, volMax, playTimeout := Player, PlayerArgs, VolumeMax, PlayTimeout
+	defer func() {
+		Player, PlayerArgs, VolumeMax, PlayTimeout = player, playerArgs, volMax, playTimeout
+	}()
+	if err := loadSettings(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Fatal(err)
+	}
+	file := writeFile(t, "config.json", `{
+    "player": "mpv",
+    "playerArgs": ["--no-config"],
+    "volumeMax": 80,
+    "playTimeout": "3s"
+}`)
+	if err := loadSettings(file); err != nil {
+		t.Fatal(err)
+	}
+	if VolumeMax != 80 || PlayTimeout != 3*time.Second {
+		t.Fatalf("unexpected settings volumeMax %d playTimeout %s", VolumeMax, PlayTimeout)
+	}
+	args := strings.Join(PlayerArgs, " ")
+	if args != "--no-config --idle=yes --input-ipc-server="+PlayerControlFile {
+		t.Fatalf("unexpected player args %q", args)
+	}
+}
+
+func TestLoadSettingsErrors(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"{\n    \"volumeMax\": 80,\n    \"player\": \"mpv\"\n    \"log\": \"/tmp/x\"\n}", ":4:5: "},
+		{"{\n    \"volumeMax\": 80,\n    \"colour\": \"red\"\n}", ":3:5: unknown field 'colour'"},
+		{"{\n    \"volumeMin\": 10,\n    \"volumeMax\": 5\n}", ":3:5: volumeMax '5' must be greater"},
+		{"{\n    \"playerArgs\": [\n        \"--no-config\",\n        \"--input-ipc-server=/tmp/x\"\n    ]\n}", ":4:9: "},
+		{"{\n    \"volumeMax\": \"loud\"\n}", ":2:"},
+		{"{\n    \"playTimeout\": \"soon\"\n}", ":2:5: playTimeout 'soon'"},
+		{"{\n    \"reconnectMax\": -1\n}", ":2:5: reconnectMax '-1'"},
+		{"{\n    \"playerRestartMax\": -1\n}", ":2:5: playerRestartMax '-1'"},
+	}
+	for _, test := range tests {
+		file := writeFile(t, "config.json", test.content)
+		err := loadSettings(file)
+		if err == nil || !strings.Contains(err.Error(), file+test.want) {
+			t.Fatalf("expected error containing %q, got %v", file+test.want, err)
+		}
+	}
+}
+
+func TestLoadStations(t *testing.T) {
+	streams, mirrors, errLs := LoadStations(filepath.Join(t.TempDir(), "missing.json"))
+	if errLs != nil || streams != nil {
+		t.Fatalf("expected no stations and no error, got %v %v", streams, errLs)
+	}
+	file := writeFile(t, "stations.json", `{
+    "1": {"name": "One", "url": "https://example.org/one"},
+    "7": {"name": "Seven", "nameIcy": "7", "url": "https://example.org/seven", "mirrors": ["https://example.org/seven%20b", "https://b.example.org/seven"]}
+}`)
+	streams, mirrors, errLs = LoadStations(file)
+	if errLs != nil {
+		t.Fatal(errLs)
+	}
+	if len(streams) != 2 || streams[7]["nameIcy"] != "7" {
+		t.Fatalf("unexpected stations %v", streams)
+	}
+	if len(mirrors) != 1 || strings.Join(mirrors[7], " ") != "https://example.org/seven%20b https://b.example.org/seven" {
+		t.Fatalf("unexpected mirrors %v", mirrors)
+	}
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"{\n  \"1\": {\"name\": \"One\", \"url\": \"https://example.org/one\"},\n  \"x\": {\"name\": \"X\", \"url\": \"https://example.org/x\"}\n}", ":3:3: station id 'x'"},
+		{"{\n  \"1\": {\"name\": \"One\",\n        \"url\": \"not a url\"}\n}", ":3:9: station '1' url"},
+		{"{\n  \"1\": {\"url\": \"https://example.org/one\"}\n}", ":2:3: station '1' needs a name"},
+		{"{\n  \"1\": {\"name\": \"One\", \"url\": \"https://example.org/one\",\n        \"mirrors\": \"https://example.org/uno\"}\n}", ":3:9: station '1' mirrors must be a list of urls"},
+		{"{\n  \"1\": {\"name\": \"One\", \"url\": \"https://example.org/one\",\n        \"mirrors\": [\"https://example.org/uno\",\n                    \"not a url\"]}\n}", ":4:21: station '1' mirror url 'not a url'"},
+	}
+	for _, test := range tests {
+		file := writeFile(t, "stations.json", test.content)
+		_, _, err := LoadStations(file)
+		if err == nil || !strings.Contains(err.Error(), file+test.want) {
+			t.Fatalf("expected error containing %q, got %v", file+test.want, err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+// the test package is external since configtest imports config
+package config_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/internal/configtest"
+)
+
+// pathVars returns the package variables built from a directory and the directory variable they are joined to
+func pathVars(t *testing.T) map[string]string {
+	t.Helper()
+	files, errFg := filepath.Glob("*.go")
+	if errFg != nil {
+		t.Fatal(errFg)
+	}
+	vars := make(map[string]string)
+	fset := token.NewFileSet()
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		parsed, errPf := parser.ParseFile(fset, file, nil, 0)
+		if errPf != nil {
+			t.Fatal(errPf)
+		}
+		for _, decl := range parsed.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				value := spec.(*ast.ValueSpec)
+				for num, name := range value.Names {
+					if num >= len(value.Values) {
+						continue
+					}
+					call, ok := value.Values[num].(*ast.CallExpr)
+					if !ok {
+						continue
+					}
+					switch fun := call.Fun.(type) {
+					case *ast.Ident:
+						// the base directories: configDir(), dataDir() and runtimeDir()
+						if strings.HasSuffix(fun.Name, "Dir") {
+							vars[name.Name] = ""
+						}
+					case *ast.SelectorExpr:
+						if pkg, ok := fun.X.(*ast.Ident); ok && pkg.Name == "filepath" && fun.Sel.Name == "Join" && len(call.Args) > 0 {
+							dir, _ := call.Args[0].(*ast.Ident)
+							if dir != nil {
+								vars[name.Name] = dir.Name
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return vars
+}
+
+func TestUseDirsCoversPaths(t *testing.T) {
+	vars := pathVars(t)
+	if len(vars) == 0 {
+		t.Fatal("expected the config path variables")
+	}
+	runtime, data := t.TempDir(), t.TempDir()
+	restore := configtest.UseDirs(runtime, data)
+	defer restore()
+	paths := configtest.Paths()
+	for name, dir := range vars {
+		path, ok := paths[name]
+		if !ok {
+			t.Errorf("the path variable %s is not saved by configtest.UseDirs", name)
+			continue
+		}
+		switch {
+		case dir == "RuntimeDir" && filepath.Dir(*path) != runtime:
+			t.Errorf("the runtime path variable %s is not moved by configtest.UseDirs, got %s", name, *path)
+		case dir == "DataDir" && filepath.Dir(*path) != data:
+			t.Errorf("the data path variable %s is not moved by configtest.UseDirs, got %s", name, *path)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultProfile the name of the profile used without --profile
+const DefaultProfile = "default"
+
+// Instance data type, the running program files of a profile
+type Instance struct {
+	Profile     string
+	LockFile    string
+	PidFile     string
+	ControlFile string
+}
+
+var (
+	// Profile the current profile name, empty for the default profile
+	Profile = ""
+
+	// ProfileConfigFile the settings file of the current profile, loaded over the settings file
+	ProfileConfigFile = ""
+
+	// profileName the valid profile names
+	profileName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,31}$`)
+)
+
+// SetProfile makes name the current profile, it has its own runtime (with its log and wm files), configuration and data files
+func SetProfile(name string) error {
+	if name == DefaultProfile {
+		return nil
+	}
+	if !profileName.MatchString(name) {
+		return fmt.Errorf("SetProfile: error: invalid profile name '%s', use up to 32 letters, digits, '-' or '_'\n", name)
+	}
+	Profile = name
+	setRuntimeDir(profileRuntimeDir(name))
+	profileConfigDir := filepath.Join(ConfigDir, "profiles", name)
+	ProfileConfigFile = filepath.Join(profileConfigDir, "config.json")
+	StationsFile = filepath.Join(profileConfigDir, "stations.json")
+	setDataDir(filepath.Join(dataDir(), "profiles", name))
+	return nil
+}
+
+// ProfileArgs returns the command line arguments selecting the current profile
+func ProfileArgs() []string {
+	if Profile == "" {
+		return nil
+	}
+	return []string{"--profile", Profile}
+}
+
+// Instances returns the running program files of the default profile and of the profiles started before
+func Instances() ([]Instance, error) {
+	instances := []Instance{instance(DefaultProfile, runtimeDir())}
+	entries, errRd := os.ReadDir(filepath.Join(runtimeDir(), "profiles"))
+	if errors.Is(errRd, os.ErrNotExist) {
+		return instances, nil
+	} else if errRd != nil {
+		return nil, errRd
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && profileName.MatchString(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		instances = append(instances, instance(name, profileRuntimeDir(name)))
+	}
+	return instances, nil
+}
+
+// instance returns the running program files of the profile inside the runtime directory
+func instance(name string, dir string) Instance {
+	return Instance{
+		Profile:     name,
+		LockFile:    filepath.Join(dir, lockFileName),
+		PidFile:     filepath.Join(dir, pidFileName),
+		ControlFile: filepath.Join(dir, controlFileName),
+	}
+}
+
+// profileFile returns the file name with the profile name before its extension
+func profileFile(file string, name string) string {
+	ext := filepath.Ext(file)
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(file, ext), name, ext)
+}
+
+// profileRuntimeDir returns the runtime directory of the profile
+func profileRuntimeDir(name string) string {
+	return filepath.Join(runtimeDir(), "profiles", name)
+}
+
+// setRuntimeDir moves the running program files into the runtime directory
+func setRuntimeDir(dir string) {
+	RuntimeDir = dir
+	ControlFile = filepath.Join(dir, controlFileName)
+	LockFile = filepath.Join(dir, lockFileName)
+	Log = filepath.Join(dir, logFileName)
+	PidFile = filepath.Join(dir, pidFileName)
+	PlayerControlFile = filepath.Join(dir, playerControlName)
+	PlayerPidFile = filepath.Join(dir, playerPidFileName)
+	ReconnectFile = filepath.Join(dir, reconnectFileName)
+	WmFile = filepath.Join(dir, wmFileName)
+	args := make([]string, 0, len(PlayerArgs))
+	for _, arg := range PlayerArgs {
+		if strings.HasPrefix(arg, "--input-ipc-server=") {
+			arg = "--input-ipc-server=" + PlayerControlFile
+		}
+		args = append(args, arg)
+	}
+	PlayerArgs = args
+}
+
+// setDataDir moves the state files into the data directory
+func setDataDir(dir string) {
+	DataDir = dir
+	HistoryFile = filepath.Join(dir, "history.jsonl")
+	ModesFile = filepath.Join(dir, "modes.json")
+	RecordDir = filepath.Join(dir, "recordings")
+	ResumeFile = filepath.Join(dir, "resume.json")
+	ScheduleFile = filepath.Join(dir, "schedule.json")
+	ScheduleStateFile = filepath.Join(dir, "schedule-state.json")
+	SongsFile = filepath.Join(dir, "songs.jsonl")
+}
@@ -0,0 +1,133 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// savePaths returns a function restoring the profile and its files as they are now
+func savePaths() func() {
+	paths := []*string{
+		&Profile, &ProfileConfigFile, &ConfigDir, &ConfigFile, &StationsFile, &Log, &WmFile,
+		&RuntimeDir, &ControlFile, &LockFile, &PidFile, &PlayerControlFile, &PlayerPidFile,
+		&ReconnectFile,
+		&DataDir, &HistoryFile, &ModesFile, &RecordDir, &ResumeFile, &ScheduleFile, &ScheduleStateFile, &SongsFile,
+	}
+	saved := make([]string, len(paths))
+	for num, path := range paths {
+		saved[num] = *path
+	}
+	playerArgs := PlayerArgs
+	return func() {
+		for num, path := range paths {
+			*path = saved[num]
+		}
+		PlayerArgs = playerArgs
+	}
+}
+
+// setUpProfileTest restores the files changed by the profile after the test
+func setUpProfileTest(t *testing.T) {
+	t.Helper()
+	restore := savePaths()
+	streams, mirrors := Stations()
+	volMax, audioDevice := VolumeMax, AudioDevice
+	t.Cleanup(func() {
+		restore()
+		SetStations(streams, mirrors)
+		VolumeMax, AudioDevice = volMax, audioDevice
+	})
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	ConfigDir = t.TempDir()
+	ConfigFile = filepath.Join(ConfigDir, "config.json")
+	StationsFile = filepath.Join(ConfigDir, "stations.json")
+}
+
+func TestSetProfile(t *testing.T) {
+	setUpProfileTest(t)
+	for _, name := range []string{"", "-x", "a/b", "..", strings.Repeat("x", 33)} {
+		if err := SetProfile(name); err == nil {
+			t.Errorf("expected error for profile name %q", name)
+		}
+	}
+	if err := SetProfile(DefaultProfile); err != nil || Profile != "" {
+		t.Fatalf("expected the default profile unchanged, got %q %v", Profile, err)
+	}
+	if err := SetProfile("kitchen"); err != nil {
+		t.Fatal(err)
+	}
+	runtime := filepath.Join(os.Getenv("XDG_RUNTIME_DIR"), ProgName, "profiles", "kitchen")
+	if RuntimeDir != runtime || LockFile != filepath.Join(runtime, lockFileName) || PlayerControlFile != filepath.Join(runtime, playerControlName) {
+		t.Fatalf("unexpected runtime files %s %s %s", RuntimeDir, LockFile, PlayerControlFile)
+	}
+	if !strings.Contains(strings.Join(PlayerArgs, " "), "--input-ipc-server="+PlayerControlFile) {
+		t.Fatalf("expected the profile control file in the player args %q", PlayerArgs)
+	}
+	if HistoryFile != filepath.Join(os.Getenv("XDG_DATA_HOME"), ProgName, "profiles", "kitchen", "history.jsonl") {
+		t.Fatalf("unexpected history file %s", HistoryFile)
+	}
+	if Log != filepath.Join(runtime, logFileName) || WmFile != filepath.Join(runtime, wmFileName) {
+		t.Fatalf("unexpected log %s or wm file %s", Log, WmFile)
+	}
+	if args := ProfileArgs(); len(args) != 2 || args[1] != "kitchen" {
+		t.Fatalf("unexpected profile args %q", args)
+	}
+
+	// the profile settings are loaded over the settings, the default stations are used until it has its own
+	writeConfig := func(file string, content string) {
+		if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig(ConfigFile, `{"volumeMax": 80, "audioDevice": "alsa/default", "log": "/var/log/gorum.log", "wmFile": "/var/tmp/wm"}`)
+	writeConfig(ProfileConfigFile, `{"audioDevice": "pulse/kitchen", "wmFile": "/var/tmp/kitchen-wm"}`)
+	writeConfig(filepath.Join(ConfigDir, "stations.json"), `{"1": {"name": "One", "url": "https://example.org/one"}}`)
+	if err := Load(); err != nil {
+		t.Fatal(err)
+	}
+	if streams, _ := Stations(); VolumeMax != 80 || AudioDevice != "pulse/kitchen" || streams[1]["name"] != "One" {
+		t.Fatalf("unexpected profile settings volumeMax %d audioDevice %q streams %v", VolumeMax, AudioDevice, streams)
+	}
+	if Log != "/var/log/gorum-kitchen.log" || WmFile != "/var/tmp/kitchen-wm" {
+		t.Fatalf("expected the shared log with the profile name and the profile wm file, got %s %s", Log, WmFile)
+	}
+	writeConfig(StationsFile, `{"2": {"name": "Two", "url": "https://example.org/two"}}`)
+	if err := Load(); err != nil {
+		t.Fatal(err)
+	}
+	if streams, _ := Stations(); len(streams) != 1 || streams[2] == nil {
+		t.Fatalf("expected the profile stations, got %v", streams)
+	}
+}
+
+func TestInstances(t *testing.T) {
+	setUpProfileTest(t)
+	for _, name := range []string{"office", "kitchen"} {
+		if err := os.MkdirAll(profileRuntimeDir(name), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	instances, err := Instances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, inst := range instances {
+		names = append(names, inst.Profile)
+	}
+	if strings.Join(names, " ") != "default kitchen office" {
+		t.Fatalf("unexpected instances %q", names)
+	}
+	if instances[1].ControlFile != filepath.Join(profileRuntimeDir("kitchen"), controlFileName) {
+		t.Fatalf("unexpected control file %s", instances[1].ControlFile)
+	}
+}
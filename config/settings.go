@@ -4,23 +4,41 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
 	"os/user"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 )
 
 // ProgName the name of the program
 const ProgName = "gorum"
 
+// the running program file names inside the runtime directory
+const (
+	controlFileName   = "control.socket"
+	lockFileName      = ProgName + ".lock"
+	logFileName       = ProgName + ".log"
+	pidFileName       = ProgName + ".pid"
+	playerControlName = "player-control.socket"
+	playerPidFileName = "player.pid"
+	reconnectFileName = "reconnect.json"
+	wmFileName        = "wm.txt"
+)
+
 var (
-	LockDir    = fmt.Sprintf("%s/%s-%s.lock", tmpDir, userName, ProgName)
-	PidFile    = fmt.Sprintf("%s/%s-%s.pid", tmpDir, userName, ProgName)
-	Player     = "mpv"
-	PlayerArgs = []string{
+	RuntimeDir  = runtimeDir()
+	ControlFile = filepath.Join(RuntimeDir, controlFileName)
+	LockFile    = filepath.Join(RuntimeDir, lockFileName)
+	PidFile     = filepath.Join(RuntimeDir, pidFileName)
+	Player      = "mpv"
+	PlayerArgs  = []string{
 		"--no-config",
-		"--msg-level=all=v",
 		"--network-timeout=10",
 		"--cache=no",
 		"--cache-pause=no",
@@ -29,22 +47,34 @@ var (
 		"--idle=yes",
 		"--input-ipc-server=" + PlayerControlFile,
 	}
-	PlayerControlFile = fmt.Sprintf("%s/%s-%s-player-control.socket", tmpDir, userName, ProgName)
-	PlayerPidFile     = fmt.Sprintf("%s/%s-%s-player.pid", tmpDir, userName, ProgName)
-	Log               = fmt.Sprintf("%s/%s-%s.log", tmpDir, userName, ProgName)
+	PlayerControlFile = filepath.Join(RuntimeDir, playerControlName)
+	PlayerPidFile     = filepath.Join(RuntimeDir, playerPidFileName)
+	PlayerRestartMax  = 5
+	AlarmFile         = ""
+	AudioDevice       = ""
+	Log               = filepath.Join(RuntimeDir, logFileName)
 	MaxMenuTries      = 5
-	MinStatusTries    = 1
-	MaxStatusTries    = 10
+	PlayTimeout       = 10 * time.Second
+	ReconnectDelay    = time.Second
+	ReconnectFile     = filepath.Join(RuntimeDir, reconnectFileName)
+	ReconnectMax      = 10
+	Resume            = false
 	VolumeMin         = 0
 	VolumeMax         = 100
 	VolumeAbsolute    = 100
 	WmDoBarUpdate     = wmCheckBarUpdate("wmbarupdate")
-	WmFile            = fmt.Sprintf("%s/%s-%s-wm.txt", tmpDir, userName, ProgName)
+	WmFile            = filepath.Join(RuntimeDir, wmFileName)
 	WmFilePerms       = os.FileMode(0600)
 	tmpDir            = os.TempDir()
 	userName          = getUserName()
 )
 
+// MediaExtensions the local media file extensions played from directories
+var MediaExtensions = []string{
+	".aac", ".ac3", ".aif", ".aiff", ".alac", ".ape", ".avi", ".flac", ".m4a", ".m4b", ".mka", ".mkv", ".mov",
+	".mp2", ".mp3", ".mp4", ".mpc", ".oga", ".ogg", ".ogv", ".opus", ".wav", ".webm", ".wma", ".wmv", ".wv",
+}
+
 // getUserName returns the current user name
 func getUserName() string {
 	usc, err := user.Current()
@@ -54,6 +84,57 @@ func getUserName() string {
 	return usc.Username
 }
 
+// runtimeDir returns the user runtime directory of the running program files
+func runtimeDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" && filepath.IsAbs(dir) {
+		return filepath.Join(dir, ProgName)
+	}
+	return filepath.Join(tmpDir, fmt.Sprintf("%s-%d", ProgName, os.Getuid()))
+}
+
+// MakeRuntimeDir creates the runtime directory, it and its parents up to the base runtime directory must be private directories of the current user
+func MakeRuntimeDir() error {
+	dirs := []string{RuntimeDir}
+	base := runtimeDir()
+	if rel, errFr := filepath.Rel(base, RuntimeDir); errFr == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		// a profile runtime directory is inside the base one, another user could own one of its parents
+		dirs = []string{base}
+		for _, name := range strings.Split(rel, string(filepath.Separator)) {
+			dirs = append(dirs, filepath.Join(dirs[len(dirs)-1], name))
+		}
+	}
+	if errMa := os.MkdirAll(filepath.Dir(dirs[0]), 0700); errMa != nil {
+		return errMa
+	}
+	for _, dir := range dirs {
+		if errPd := privateDir(dir); errPd != nil {
+			return errPd
+		}
+	}
+	return nil
+}
+
+// privateDir creates the directory when it does not exist, it must be a directory of the current user, its mode is set to 0700
+func privateDir(dir string) error {
+	if errMd := os.Mkdir(dir, 0700); errMd != nil && !errors.Is(errMd, os.ErrExist) {
+		return errMd
+	}
+	fi, errLs := os.Lstat(dir)
+	if errLs != nil {
+		return errLs
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("MakeRuntimeDir: error: '%s' is not a directory\n", dir)
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok && int(st.Uid) != os.Getuid() {
+		return fmt.Errorf("MakeRuntimeDir: error: '%s' is not owned by the current user\n", dir)
+	}
+	if fi.Mode().Perm() != 0700 {
+		return os.Chmod(dir, 0700)
+	}
+	return nil
+}
+
 // wmCheckBarUpdate checks if wmbarupdate command exists
 func wmCheckBarUpdate(cmd string) bool {
 	_, err := exec.LookPath(cmd)
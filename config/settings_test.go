@@ -0,0 +1,91 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMakeRuntimeDir(t *testing.T) {
+	runtimeDir := RuntimeDir
+	t.Cleanup(func() {
+		RuntimeDir = runtimeDir
+	})
+	RuntimeDir = filepath.Join(t.TempDir(), "run", ProgName)
+	if err := MakeRuntimeDir(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(RuntimeDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := MakeRuntimeDir(); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(RuntimeDir); err != nil || fi.Mode().Perm() != 0700 {
+		t.Fatalf("expected a private runtime directory, got %v %v", fi.Mode(), err)
+	}
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(RuntimeDir, link); err != nil {
+		t.Fatal(err)
+	}
+	RuntimeDir = link
+	if err := MakeRuntimeDir(); err == nil || !strings.Contains(err.Error(), "is not a directory") {
+		t.Fatalf("expected error for a symlinked runtime directory, got %v", err)
+	}
+}
+
+func TestMakeProfileRuntimeDir(t *testing.T) {
+	runtimeDir := RuntimeDir
+	t.Cleanup(func() {
+		RuntimeDir = runtimeDir
+	})
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	base := filepath.Join(os.Getenv("XDG_RUNTIME_DIR"), ProgName)
+	RuntimeDir = profileRuntimeDir("kitchen")
+	if err := MakeRuntimeDir(); err != nil {
+		t.Fatal(err)
+	}
+	profiles := filepath.Join(base, "profiles")
+	for _, dir := range []string{base, profiles} {
+		if err := os.Chmod(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := MakeRuntimeDir(); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{base, profiles, RuntimeDir} {
+		if fi, err := os.Lstat(dir); err != nil || fi.Mode().Perm() != 0700 {
+			t.Fatalf("expected the private directory '%s', got %v %v", dir, fi.Mode(), err)
+		}
+	}
+
+	// another user owns a parent of the profile runtime directory
+	if os.Getuid() == 0 {
+		if err := os.Chown(profiles, 12345, -1); err != nil {
+			t.Fatal(err)
+		}
+		if err := MakeRuntimeDir(); err == nil || !strings.Contains(err.Error(), "'"+profiles+"' is not owned") {
+			t.Fatalf("expected error for a parent owned by another user, got %v", err)
+		}
+	}
+
+	// another user replaced a parent of the profile runtime directory with a symlink
+	if err := os.RemoveAll(profiles); err != nil {
+		t.Fatal(err)
+	}
+	other := t.TempDir()
+	if err := os.Symlink(other, profiles); err != nil {
+		t.Fatal(err)
+	}
+	if err := MakeRuntimeDir(); err == nil || !strings.Contains(err.Error(), "'"+profiles+"' is not a directory") {
+		t.Fatalf("expected error for a symlinked parent, got %v", err)
+	}
+	if entries, _ := os.ReadDir(other); len(entries) != 0 {
+		t.Fatalf("expected nothing created through the symlink, got %v", entries)
+	}
+}
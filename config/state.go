@@ -0,0 +1,120 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	DataDir           = dataDir()
+	HistoryFile       = filepath.Join(DataDir, "history.jsonl")
+	ModesFile         = filepath.Join(DataDir, "modes.json")
+	RecordDir         = filepath.Join(DataDir, "recordings")
+	ResumeFile        = filepath.Join(DataDir, "resume.json")
+	ScheduleFile      = filepath.Join(DataDir, "schedule.json")
+	ScheduleStateFile = filepath.Join(DataDir, "schedule-state.json")
+	SongsFile         = filepath.Join(DataDir, "songs.jsonl")
+)
+
+// AppendState appends v as a json line to the state file
+func AppendState(file string, v interface{}) error {
+	data, errJm := json.Marshal(v)
+	if errJm != nil {
+		return errJm
+	}
+	if errMa := os.MkdirAll(filepath.Dir(file), 0700); errMa != nil {
+		return errMa
+	}
+	fh, errOf := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if errOf != nil {
+		return errOf
+	}
+	if _, errFw := fh.Write(append(data, '\n')); errFw != nil {
+		fh.Close()
+		return errFw
+	}
+	return fh.Close()
+}
+
+// dataDir returns the user data directory
+func dataDir() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" && filepath.IsAbs(dir) {
+		return filepath.Join(dir, ProgName)
+	}
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(homeDir, ".local", "share", ProgName)
+	}
+	return filepath.Join(tmpDir, userName+"-"+ProgName+"-data")
+}
+
+// LoadState decodes the json state file into v, keeping v unchanged when the file does not exist
+func LoadState(file string, v interface{}) error {
+	data, errRf := os.ReadFile(file)
+	if errors.Is(errRf, os.ErrNotExist) {
+		return nil
+	}
+	if errRf != nil {
+		return errRf
+	}
+	if errJu := json.Unmarshal(data, v); errJu != nil {
+		return fmt.Errorf("loadState: error: %s: %s\n", file, errJu.Error())
+	}
+	return nil
+}
+
+// LoadStateLines calls fn with every json line of the state file, doing nothing when the file does not exist
+func LoadStateLines(file string, fn func(data []byte) error) error {
+	fh, errOo := os.Open(file)
+	if errors.Is(errOo, os.ErrNotExist) {
+		return nil
+	}
+	if errOo != nil {
+		return errOo
+	}
+	defer fh.Close()
+	scanner := bufio.NewScanner(fh)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		if errFn := fn(scanner.Bytes()); errFn != nil {
+			return fmt.Errorf("loadStateLines: error: %s:%d: %s\n", file, lineNum, strings.TrimSpace(errFn.Error()))
+		}
+	}
+	return scanner.Err()
+}
+
+// UpdateState loads the json state file into v, changes it with fn and saves it, holding a lock so that concurrent updates are not lost
+func UpdateState(file string, v interface{}, fn func() error) error {
+	unlock, errLu := LockUpdate(file)
+	if errLu != nil {
+		return errLu
+	}
+	defer unlock()
+	if errLs := LoadState(file, v); errLs != nil {
+		return errLs
+	}
+	if errFn := fn(); errFn != nil {
+		return errFn
+	}
+	return SaveState(file, v)
+}
+
+// SaveState writes v as the json state file atomically
+func SaveState(file string, v interface{}) error {
+	data, errJm := json.MarshalIndent(v, "", "    ")
+	if errJm != nil {
+		return errJm
+	}
+	return writeFileAtomic(file, append(data, '\n'), 0600)
+}
@@ -3,8 +3,15 @@
 
 package config
 
-// Streams urls, needs more :)
-var Streams = map[int]map[string]string{
+import (
+	"sync"
+)
+
+// stationsMu guards the stations tables, they are replaced as a whole and never changed in place
+var stationsMu sync.RWMutex
+
+// stationStreams urls, needs more :)
+var stationStreams = map[int]map[string]string{
 	1: {
 		"name":    "Русские Песни",
 		"nameIcy": "RUSSIAN SONGS",
@@ -56,3 +63,28 @@ var Streams = map[int]map[string]string{
 		"url":     "https://skymedia-live.bitflip.ee/SKY",
 	},
 }
+
+// stationMirrors the stations mirror urls, played when their url fails
+var stationMirrors = map[int][]string{}
+
+// Stations returns the stations streams and their mirror urls, the returned tables must not be modified
+func Stations() (map[int]map[string]string, map[int][]string) {
+	stationsMu.RLock()
+	defer stationsMu.RUnlock()
+	return stationStreams, stationMirrors
+}
+
+// Station returns the station stream
+func Station(id int) (map[string]string, bool) {
+	stationsMu.RLock()
+	defer stationsMu.RUnlock()
+	stream, ok := stationStreams[id]
+	return stream, ok
+}
+
+// SetStations replaces the stations streams and their mirror urls, the tables must not be modified afterwards
+func SetStations(streams map[int]map[string]string, mirrors map[int][]string) {
+	stationsMu.Lock()
+	defer stationsMu.Unlock()
+	stationStreams, stationMirrors = streams, mirrors
+}
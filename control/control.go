@@ -0,0 +1,165 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Version the control protocol version, requests of another version are refused
+const Version = 1
+
+// Request data type, a command sent to the control socket as a json line
+type Request struct {
+	Version int      `json:"version"`
+	Id      int64    `json:"id"`
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+}
+
+// Response data type, the json line answering a request, or an event after subscribing
+type Response struct {
+	Version int             `json:"version"`
+	Id      int64           `json:"id,omitempty"`
+	Event   string          `json:"event,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// Client data type, a connection to the control socket
+type Client struct {
+	// Timeout the maximum time to wait for the answer of a call, zero waits forever
+	Timeout time.Duration
+	mu      sync.Mutex
+	conn    net.Conn
+	rd      *bufio.Reader
+	lastId  int64
+	events  []Response
+}
+
+// Error data type, an error returned by the control server
+type Error struct {
+	Msg string
+}
+
+// Error returns the error message of the control server
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+// Dial connects to the control socket file, waiting up to timeout for the connection and for the answer of each call
+func Dial(file string, timeout time.Duration) (*Client, error) {
+	conn, err := net.DialTimeout("unix", file, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{Timeout: timeout, conn: conn, rd: bufio.NewReader(conn)}, nil
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Call runs the command and decodes its result into v, a failed command may have a result too, a nil v discards it
+func (c *Client) Call(v interface{}, command string, args ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastId++
+	id := c.lastId
+	if c.Timeout > 0 {
+		if errSd := c.conn.SetDeadline(time.Now().Add(c.Timeout)); errSd != nil {
+			return errSd
+		}
+		defer c.conn.SetDeadline(time.Time{})
+	}
+	if errWr := c.write(Request{Version: Version, Id: id, Command: command, Args: args}); errWr != nil {
+		return c.deadline(command, errWr)
+	}
+	for {
+		resp, errRe := c.read()
+		if errRe != nil {
+			return c.deadline(command, errRe)
+		}
+		// the events arriving before the answer are kept for Next
+		if resp.Event != "" {
+			c.events = append(c.events, resp)
+			continue
+		}
+		if resp.Id != id {
+			continue
+		}
+		if resp.Error != "" {
+			if v != nil && len(resp.Data) > 0 {
+				json.Unmarshal(resp.Data, v)
+			}
+			return &Error{Msg: resp.Error}
+		}
+		if v == nil || len(resp.Data) == 0 {
+			return nil
+		}
+		return json.Unmarshal(resp.Data, v)
+	}
+}
+
+// deadline returns a clearer error when the call did not finish within the client timeout
+func (c *Client) deadline(command string, err error) error {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return fmt.Errorf("control: error: no answer to '%s' within %s\n", command, c.Timeout)
+	}
+	return err
+}
+
+// Subscribe turns the connection into a stream of events read with Next
+func (c *Client) Subscribe() error {
+	return c.Call(nil, "subscribe")
+}
+
+// Next returns the next response or event, the events received during Call come first
+func (c *Client) Next() (Response, error) {
+	c.mu.Lock()
+	if len(c.events) > 0 {
+		resp := c.events[0]
+		c.events = c.events[1:]
+		c.mu.Unlock()
+		return resp, nil
+	}
+	c.mu.Unlock()
+	return c.read()
+}
+
+// read reads the next json line
+func (c *Client) read() (Response, error) {
+	var resp Response
+	line, errRs := c.rd.ReadBytes('\n')
+	if errRs != nil {
+		return resp, errRs
+	}
+	if errJu := json.Unmarshal(line, &resp); errJu != nil {
+		return resp, errJu
+	}
+	if resp.Version != Version {
+		return resp, fmt.Errorf("control: error: unsupported protocol version %d, expected %d\n", resp.Version, Version)
+	}
+	return resp, nil
+}
+
+// write writes the request as a json line
+func (c *Client) write(req Request) error {
+	data, errJm := json.Marshal(req)
+	if errJm != nil {
+		return errJm
+	}
+	if _, errCw := c.conn.Write(append(data, '\n')); errCw != nil {
+		return errCw
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveTest answers every request on the socket with the responses returned by reply
+func serveTest(t *testing.T, reply func(req Request) []Response) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "control.socket")
+	ln, errNl := net.Listen("unix", file)
+	if errNl != nil {
+		t.Fatal(errNl)
+	}
+	t.Cleanup(func() {
+		ln.Close()
+	})
+	go func() {
+		conn, errLa := ln.Accept()
+		if errLa != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			var req Request
+			if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+				return
+			}
+			for _, resp := range reply(req) {
+				data, _ := json.Marshal(resp)
+				if _, err := conn.Write(append(data, '\n')); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	return file
+}
+
+func TestCall(t *testing.T) {
+	file := serveTest(t, func(req Request) []Response {
+		if req.Version != Version {
+			t.Errorf("unexpected version %d", req.Version)
+		}
+		switch req.Command {
+		case "title":
+			return []Response{
+				{Version: Version, Event: "state", Data: json.RawMessage(`{}`)},
+				{Version: Version, Id: req.Id + 100},
+				{Version: Version, Id: req.Id, Data: json.RawMessage(`"Artist - Song"`)},
+			}
+		case "old":
+			return []Response{{Version: Version + 1, Id: req.Id}}
+		}
+		return []Response{{Version: Version, Id: req.Id, Error: "control: error: unknown command\n"}}
+	})
+	cli, errCd := Dial(file, time.Second)
+	if errCd != nil {
+		t.Fatal(errCd)
+	}
+	defer cli.Close()
+	var title string
+	if err := cli.Call(&title, "title"); err != nil || title != "Artist - Song" {
+		t.Fatalf("expected the title, got %q %v", title, err)
+	}
+	var errCtl *Error
+	if err := cli.Call(nil, "dance"); !errors.As(err, &errCtl) {
+		t.Fatalf("expected a control error, got %v", err)
+	}
+	if err := cli.Call(nil, "old"); err == nil || errors.As(err, &errCtl) {
+		t.Fatalf("expected a protocol version error, got %v", err)
+	}
+}
+
+func TestCallTimeout(t *testing.T) {
+	file := serveTest(t, func(req Request) []Response {
+		// the server hangs and never answers
+		return nil
+	})
+	cli, errCd := Dial(file, 100*time.Millisecond)
+	if errCd != nil {
+		t.Fatal(errCd)
+	}
+	defer cli.Close()
+	start := time.Now()
+	err := cli.Call(nil, "status")
+	if err == nil || !strings.Contains(err.Error(), "no answer to 'status' within 100ms") {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected the call to give up after its timeout, it took %s", elapsed)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	file := serveTest(t, func(req Request) []Response {
+		return []Response{
+			{Version: Version, Event: "state", Data: json.RawMessage(`"first"`)},
+			{Version: Version, Id: req.Id},
+			{Version: Version, Event: "state", Data: json.RawMessage(`"second"`)},
+		}
+	})
+	cli, errCd := Dial(file, time.Second)
+	if errCd != nil {
+		t.Fatal(errCd)
+	}
+	defer cli.Close()
+	if err := cli.Subscribe(); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"first"`, `"second"`} {
+		resp, errCn := cli.Next()
+		if errCn != nil {
+			t.Fatal(errCn)
+		}
+		if resp.Event != "state" || string(resp.Data) != want {
+			t.Fatalf("expected the state event %s, got %+v", want, resp)
+		}
+	}
+}
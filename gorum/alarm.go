@@ -0,0 +1,190 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+)
+
+const (
+	// alarmFade the default time in seconds to fade in the alarm volume
+	alarmFade = 60
+
+	// alarmStep the time between two volume steps of the fade in
+	alarmStep = time.Second
+)
+
+// Alarm runs the alarm command: list (default), cancel n or hh:mm [--station n] [--fade 60s] [--volume n] [--file f] [--days d]
+func Alarm(args []string) (string, error) {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch args[0] {
+	case "cancel", "rm":
+		return "", alarmCancel(args[1:])
+	case "list", "ls":
+		alarms, errLa := loadAlarms()
+		if errLa != nil {
+			return "", errLa
+		}
+		return scheduleList(alarms, time.Now())
+	}
+	return alarmAdd(args)
+}
+
+// alarmAdd adds the alarm ringing once or on the days
+func alarmAdd(args []string) (string, error) {
+	job := scheduleJob{Action: "alarm", At: args[0], Days: "daily", Once: true, Missed: "skip", Created: time.Now()}
+	fs := flag.NewFlagSet("alarm", flag.ContinueOnError)
+	station := fs.Int("station", 0, "station id to play")
+	fade := fs.Duration("fade", alarmFade*time.Second, "time to fade in the volume")
+	volume := fs.Int("volume", 0, "target volume of the fade in, the current volume by default")
+	file := fs.String("file", config.AlarmFile, "local file played when the station cannot be played")
+	days := fs.String("days", "", "rings on the days instead of once: daily, weekdays, weekends, mon,wed or sat-sun")
+	if errPf := parseFlags(fs, args[1:]); errPf != nil {
+		return "", errPf
+	}
+	if fs.NArg() > 0 {
+		return "", fmt.Errorf("alarm: error: unexpected argument '%s'\n", fs.Arg(0))
+	}
+	if *station != 0 {
+		if _, ok := config.Station(*station); !ok {
+			return "", fmt.Errorf("alarm: error: station '%d' not found\n", *station)
+		}
+	}
+	if *file != "" {
+		path, errFa := filepath.Abs(*file)
+		if errFa != nil {
+			return "", errFa
+		}
+		if _, errOs := os.Stat(path); errOs != nil {
+			return "", fmt.Errorf("alarm: error: fallback file '%s' not found\n", path)
+		}
+		*file = path
+	}
+	if *station == 0 && *file == "" {
+		return "", fmt.Errorf("alarm: error: the alarm needs a station or a file, see --station and --file\n")
+	}
+	if *volume != 0 && (*volume < config.VolumeMin || *volume > config.VolumeMax) {
+		return "", fmt.Errorf("alarm: error: volume '%d' must be between %d and %d\n", *volume, config.VolumeMin, config.VolumeMax)
+	}
+	if *fade < 0 {
+		return "", fmt.Errorf("alarm: error: the fade time cannot be negative\n")
+	}
+	if *days != "" {
+		job.Days, job.Once = *days, false
+	}
+	job.Station, job.Fade, job.Volume, job.File = *station, fade.Seconds(), *volume, *file
+	if errCj := checkJob(job); errCj != nil {
+		return "", errCj
+	}
+	id, errAj := addJob(job)
+	if errAj != nil {
+		return "", errAj
+	}
+	msg := fmt.Sprintf("alarm: info: alarm %d rings at %s\n", id, job.next(time.Now()).Format("2006-01-02 15:04"))
+	if !IsRunning() {
+		msg += fmt.Sprintf("alarm: warning: '%s' is not running, start it for the alarm to ring\n", config.ProgName)
+	}
+	return msg, nil
+}
+
+// alarmCancel removes the alarm number n
+func alarmCancel(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("alarm: error: usage: %s alarm cancel n\n", config.ProgName)
+	}
+	id, errSa := strconv.Atoi(args[0])
+	if errSa != nil {
+		return fmt.Errorf("alarm: error: '%s' is not an alarm number\n", args[0])
+	}
+	alarms, errLa := loadAlarms()
+	if errLa != nil {
+		return errLa
+	}
+	for _, alarm := range alarms {
+		if alarm.Id == id {
+			_, errRj := removeJobs(map[int]bool{id: true})
+			return errRj
+		}
+	}
+	return fmt.Errorf("alarm: error: alarm '%d' not found\n", id)
+}
+
+// loadAlarms loads the alarm jobs of the schedule
+func loadAlarms() ([]scheduleJob, error) {
+	jobs, errLs := loadSchedule()
+	if errLs != nil {
+		return nil, errLs
+	}
+	var alarms []scheduleJob
+	for _, job := range jobs {
+		if job.Action == "alarm" {
+			alarms = append(alarms, job)
+		}
+	}
+	return alarms, nil
+}
+
+// startAlarm plays the alarm station, or its file when the station cannot be played, and fades in the volume
+func startAlarm(job scheduleJob) error {
+	target := job.Volume
+	if target == 0 {
+		volume, errSv := systemVolume()
+		if errSv != nil {
+			return errSv
+		}
+		target = volume
+	}
+	fade := time.Duration(job.Fade * float64(time.Second))
+	if fade > 0 {
+		if errVo := Volume(config.VolumeMin); errVo != nil {
+			return errVo
+		}
+	}
+	errPw := fmt.Errorf("alarm: error: alarm %d has no station\n", job.Id)
+	if job.Station != 0 {
+		errPw = PlayWait(strconv.Itoa(job.Station), config.PlayTimeout)
+	}
+	if errPw != nil && job.File != "" {
+		log.Print(errPw)
+		log.Printf("alarm: info: playing the fallback file '%s'\n", job.File)
+		errPw = PlayWait(job.File, config.PlayTimeout)
+	}
+	if errPw != nil || fade <= 0 {
+		if errVo := Volume(target); errVo != nil {
+			log.Print(errVo)
+		}
+		return errPw
+	}
+	go func() {
+		if errFi := fadeIn(target, fade, alarmStep); errFi != nil {
+			log.Print(errFi)
+		}
+	}()
+	return nil
+}
+
+// fadeIn raises the volume from the minimum up to the target during the fade time
+func fadeIn(target int, fade time.Duration, step time.Duration) error {
+	steps := int(fade / step)
+	for num := 1; num <= steps; num++ {
+		time.Sleep(step)
+		if errVo := Volume(config.VolumeMin + (target-config.VolumeMin)*num/steps); errVo != nil {
+			return errVo
+		}
+	}
+	return Volume(target)
+}
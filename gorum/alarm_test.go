@@ -0,0 +1,115 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+)
+
+func TestAlarm(t *testing.T) {
+	srv := setUpTest(t)
+	file := filepath.Join(t.TempDir(), "wake.mp3")
+	if err := os.WriteFile(file, []byte("mp3"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"07:00", "--station", "1", "--fade", "2s", "--volume", "80", "--file", file},
+		{"08:30", "--station", "2", "--days", "weekdays"},
+	} {
+		if _, err := Alarm(args); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, args := range [][]string{
+		{"07:00"},
+		{"07:00", "--station", "999"},
+		{"7pm", "--station", "1"},
+		{"07:00", "--file", filepath.Join(t.TempDir(), "missing.mp3")},
+		{"07:00", "--station", "1", "--volume", "1000"},
+		{"07:00", "--station", "1", "--days", "someday"},
+	} {
+		if _, err := Alarm(args); err == nil {
+			t.Errorf("expected error for %q", args)
+		}
+	}
+	content, errAl := Alarm(nil)
+	if errAl != nil {
+		t.Fatal(errAl)
+	}
+	if strings.Count(content, "\n") != 2 || !strings.Contains(content, "once 07:00") || !strings.Contains(content, "weekdays 08:30") {
+		t.Fatalf("unexpected alarms:\n%s", content)
+	}
+
+	alarms, errLa := loadAlarms()
+	if errLa != nil {
+		t.Fatal(errLa)
+	}
+	alarm := alarms[0]
+	if alarm.Fade != 2 || alarm.Volume != 80 || alarm.File != file || !alarm.Once {
+		t.Fatalf("unexpected alarm %+v", alarm)
+	}
+	srv.FailLoad(testStation(1)["url"], "loading failed")
+	alarm.Fade = 0
+	if err := startAlarm(alarm); err != nil {
+		t.Fatal(err)
+	}
+	if playlist := srv.Playlist(); len(playlist) != 1 || playlist[0] != file {
+		t.Fatalf("expected the fallback file playing, got %q", playlist)
+	}
+	if volume := srv.Get("ao-volume"); volume != float64(80) {
+		t.Fatalf("unexpected alarm volume %v", volume)
+	}
+	if err := Volume(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := fadeIn(60, 30*time.Millisecond, 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if volume := srv.Get("ao-volume"); volume != float64(60) {
+		t.Fatalf("unexpected faded in volume %v", volume)
+	}
+
+	monday := time.Date(2026, 10, 12, 0, 0, 0, 0, time.Local)
+	for num := range alarms {
+		alarms[num].Created = monday
+	}
+	if err := config.SaveState(config.ScheduleFile, alarms); err != nil {
+		t.Fatal(err)
+	}
+	var rung []int
+	s, errNs := newScheduler()
+	if errNs != nil {
+		t.Fatal(errNs)
+	}
+	s.start = func(job scheduleJob) error {
+		rung = append(rung, job.Id)
+		return nil
+	}
+	for _, now := range []time.Time{monday.Add(7*time.Hour + 10*time.Second), monday.Add(8*time.Hour + 30*time.Minute + 10*time.Second)} {
+		if err := s.tick(now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(rung) != 2 || rung[0] != 1 || rung[1] != 2 {
+		t.Fatalf("unexpected rung alarms %v", rung)
+	}
+	if alarms, _ = loadAlarms(); len(alarms) != 1 || alarms[0].Id != 2 {
+		t.Fatalf("expected the once alarm removed, got %+v", alarms)
+	}
+	if _, err := Alarm([]string{"cancel", "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Alarm([]string{"cancel", "2"}); err == nil {
+		t.Fatal("expected error cancelling a missing alarm")
+	}
+}
@@ -0,0 +1,634 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+	"github.com/gonzaru/gorum/control"
+	"github.com/gonzaru/gorum/mpv"
+	"github.com/gonzaru/gorum/stations"
+	"github.com/gonzaru/gorum/utils"
+)
+
+// controlState data type, the main program state sent to the control clients
+type controlState struct {
+	Profile    string     `json:"profile,omitempty"`
+	Idle       bool       `json:"idle"`
+	Path       string     `json:"path,omitempty"`
+	Station    int        `json:"station,omitempty"`
+	Name       string     `json:"name,omitempty"`
+	Title      string     `json:"title,omitempty"`
+	Pause      bool       `json:"pause"`
+	Mute       bool       `json:"mute"`
+	Volume     int        `json:"volume"`
+	QueuePos   int        `json:"queuePos"`
+	QueueCount int        `json:"queueCount"`
+	Shuffle    bool       `json:"shuffle"`
+	Repeat     string     `json:"repeat"`
+	Sleep      int        `json:"sleep,omitempty"`
+	Recording  bool       `json:"recording"`
+	NextAlarm  *time.Time `json:"nextAlarm,omitempty"`
+	NextJob    *time.Time `json:"nextJob,omitempty"`
+}
+
+// controlServer data type, serves the control socket of the main program
+type controlServer struct {
+	ln      net.Listener
+	done    chan struct{}
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	subs    map[chan []byte]bool
+	last    []byte
+	pending sync.RWMutex
+}
+
+// controlHandler runs a control command in the main program
+type controlHandler func(args []string) (interface{}, error)
+
+// controlSubscribers the number of pending events kept for a slow subscriber, older events are dropped
+const controlSubscribers = 16
+
+// controlChanged wakes up the control server to publish the state when it may have changed
+var controlChanged = make(chan struct{}, 1)
+
+// controlHandlers the control commands and their number of arguments, -1 accepts any number
+var controlHandlers = map[string]struct {
+	args int
+	run  controlHandler
+}{
+	"alarm": {-1, func(args []string) (interface{}, error) {
+		return Alarm(args)
+	}},
+	"enqueue": {1, func(args []string) (interface{}, error) {
+		return nil, Enqueue(args[0])
+	}},
+	"history": {-1, func(args []string) (interface{}, error) {
+		return History(args)
+	}},
+	"jump": {1, func(args []string) (interface{}, error) {
+		num, errSa := strconv.Atoi(args[0])
+		if errSa != nil {
+			return nil, fmt.Errorf("jump: error: '%s' is not a queue entry number\n", args[0])
+		}
+		return nil, Jump(num)
+	}},
+	"modes": {0, func(args []string) (interface{}, error) {
+		shuffle, repeat, errMo := Modes()
+		return map[string]interface{}{"shuffle": shuffle, "repeat": repeat}, errMo
+	}},
+	"mute":  {0, controlToggle("mute")},
+	"pause": {0, controlToggle("pause")},
+	"next": {0, func(args []string) (interface{}, error) {
+		return nil, Next()
+	}},
+	"play": {1, func(args []string) (interface{}, error) {
+		return nil, PlayWait(args[0], config.PlayTimeout)
+	}},
+	"prev": {0, func(args []string) (interface{}, error) {
+		return nil, Prev()
+	}},
+	"profiles": {0, func(args []string) (interface{}, error) {
+		return Profiles()
+	}},
+	"property": {1, func(args []string) (interface{}, error) {
+		return Property(args[0])
+	}},
+	"queue": {-1, func(args []string) (interface{}, error) {
+		return Queue(args)
+	}},
+	"record": {-1, func(args []string) (interface{}, error) {
+		return Record(args)
+	}},
+	"repeat": {-1, func(args []string) (interface{}, error) {
+		if len(args) > 1 {
+			return nil, fmt.Errorf("repeat: error: unexpected argument '%s'\n", args[1])
+		}
+		mode := ""
+		if len(args) == 1 {
+			mode = args[0]
+		}
+		return nil, Repeat(mode)
+	}},
+	"resume": {1, func(args []string) (interface{}, error) {
+		return nil, PlayResume(args[0], config.PlayTimeout)
+	}},
+	"schedule": {-1, func(args []string) (interface{}, error) {
+		return Schedule(args)
+	}},
+	"seek": {1, func(args []string) (interface{}, error) {
+		seconds, errSa := strconv.Atoi(args[0])
+		if errSa != nil {
+			return nil, fmt.Errorf("seek: error: '%s' is not a number of seconds\n", args[0])
+		}
+		if errSe := Seek(seconds); errSe != nil {
+			return nil, errSe
+		}
+		return Property("playback-time")
+	}},
+	"shuffle": {0, func(args []string) (interface{}, error) {
+		return nil, Shuffle()
+	}},
+	"sleep": {-1, func(args []string) (interface{}, error) {
+		return Sleep(args)
+	}},
+	"songs": {-1, func(args []string) (interface{}, error) {
+		return Songs(args)
+	}},
+	"state": {0, func(args []string) (interface{}, error) {
+		return currentState()
+	}},
+	"stations": {-1, func(args []string) (interface{}, error) {
+		var out bytes.Buffer
+		errSr := stations.Run(&out, args)
+		return out.String(), errSr
+	}},
+	"status": {0, func(args []string) (interface{}, error) {
+		return Status()
+	}},
+	"stop": {0, func(args []string) (interface{}, error) {
+		log.Print("control: info: stop requested\n")
+		return nil, Stop()
+	}},
+	"stopplay": {0, func(args []string) (interface{}, error) {
+		return nil, PlayStop()
+	}},
+	"title": {0, func(args []string) (interface{}, error) {
+		return Title()
+	}},
+	"version": {0, func(args []string) (interface{}, error) {
+		return map[string]interface{}{"program": config.ProgName, "version": control.Version}, nil
+	}},
+	"video": {0, controlToggle("video")},
+	"volume": {1, func(args []string) (interface{}, error) {
+		num, errSa := strconv.Atoi(args[0])
+		if errSa != nil {
+			return nil, fmt.Errorf("volume: error: '%s' is not a number\n", args[0])
+		}
+		return nil, Volume(num)
+	}},
+}
+
+// parseFlags parses the command flags, the usage text is returned in the error since the handlers run in the main program
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	var usage bytes.Buffer
+	fs.SetOutput(&usage)
+	if errFp := fs.Parse(args); errFp != nil {
+		return errors.New(usage.String())
+	}
+	return nil
+}
+
+// controlToggle returns the handler toggling the property and returning its new value
+func controlToggle(property string) controlHandler {
+	return func(args []string) (interface{}, error) {
+		if errTo := Toggle(property); errTo != nil {
+			return nil, errTo
+		}
+		return Property(property)
+	}
+}
+
+// notifyControl tells the control server that the state may have changed
+func notifyControl() {
+	select {
+	case controlChanged <- struct{}{}:
+	default:
+	}
+}
+
+// startControl listens on the control socket and serves its clients in background
+func startControl() (*controlServer, error) {
+	if errOr := os.Remove(config.ControlFile); errOr != nil && !errors.Is(errOr, os.ErrNotExist) {
+		return nil, errOr
+	}
+	ln, errNl := net.Listen("unix", config.ControlFile)
+	if errNl != nil {
+		return nil, errNl
+	}
+	if errCm := os.Chmod(config.ControlFile, 0600); errCm != nil {
+		ln.Close()
+		return nil, errCm
+	}
+	s := &controlServer{ln: ln, done: make(chan struct{}), subs: make(map[chan []byte]bool)}
+	s.wg.Add(2)
+	go s.accept()
+	go s.publish()
+	log.Printf("start: info: control socket %s\n", config.ControlFile)
+	return s, nil
+}
+
+// close stops serving the control socket, waiting for the server to stop publishing and for the pending answers,
+// the answer of stop is written once the main program is stopped
+func (s *controlServer) close() error {
+	close(s.done)
+	errLc := s.ln.Close()
+	s.wg.Wait()
+	s.pending.Lock()
+	defer s.pending.Unlock()
+	return errLc
+}
+
+// accept serves every client connection until the listener is closed
+func (s *controlServer) accept() {
+	defer s.wg.Done()
+	for {
+		conn, errLa := s.ln.Accept()
+		if errLa != nil {
+			return
+		}
+		go s.serve(conn)
+	}
+}
+
+// serve answers the client requests, a subscribed client also receives the state events
+func (s *controlServer) serve(conn net.Conn) {
+	defer conn.Close()
+	var writeMu sync.Mutex
+	write := func(resp control.Response) bool {
+		data, errJm := json.Marshal(resp)
+		if errJm != nil {
+			log.Print(errJm)
+			return false
+		}
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		_, errCw := conn.Write(append(data, '\n'))
+		return errCw == nil
+	}
+	var (
+		events     chan []byte
+		subscribed bool
+	)
+	defer func() {
+		if events != nil {
+			s.unsubscribe(events)
+		}
+	}()
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var req control.Request
+		resp := control.Response{Version: control.Version}
+		if errJu := json.Unmarshal(scanner.Bytes(), &req); errJu != nil {
+			resp.Error = fmt.Sprintf("control: error: invalid request: %s\n", errJu)
+		} else if resp.Id = req.Id; req.Version != control.Version {
+			resp.Error = fmt.Sprintf("control: error: unsupported protocol version %d, expected %d\n", req.Version, control.Version)
+		} else if req.Command == "subscribe" {
+			subscribed = events == nil
+			if subscribed {
+				events = s.subscribe()
+			}
+		} else {
+			// the server is not closed before the answer is written
+			s.pending.RLock()
+			resp.Data, resp.Error = runControl(req)
+			written := write(resp)
+			s.pending.RUnlock()
+			if !written {
+				return
+			}
+			continue
+		}
+		if !write(resp) {
+			return
+		}
+		// the events follow the subscribe answer, the client skips the events while waiting for it
+		if subscribed {
+			subscribed = false
+			go s.forward(events, write)
+		}
+	}
+}
+
+// controlHandlerOf returns the handler of the command, checking its number of arguments
+func controlHandlerOf(command string, args []string) (controlHandler, error) {
+	handler, ok := controlHandlers[command]
+	if !ok {
+		return nil, fmt.Errorf("control: error: unknown command '%s'\n", command)
+	}
+	if handler.args >= 0 && len(args) != handler.args {
+		return nil, fmt.Errorf("control: error: '%s' expects %d arguments, got %d\n", command, handler.args, len(args))
+	}
+	return handler.run, nil
+}
+
+// runControl runs the request command returning its json result and its error message, a failed command may have a result too
+func runControl(req control.Request) (json.RawMessage, string) {
+	run, errCh := controlHandlerOf(req.Command, req.Args)
+	if errCh != nil {
+		return nil, errCh.Error()
+	}
+	defer notifyControl()
+	result, errRu := run(req.Args)
+	var data json.RawMessage
+	if result != nil {
+		var errJm error
+		if data, errJm = json.Marshal(result); errJm != nil {
+			return nil, errJm.Error()
+		}
+	}
+	if errRu != nil {
+		return data, errRu.Error()
+	}
+	return data, ""
+}
+
+// subscribe registers a state events subscriber, it receives the current state first
+func (s *controlServer) subscribe() chan []byte {
+	events := make(chan []byte, controlSubscribers)
+	s.mu.Lock()
+	s.subs[events] = true
+	last := s.last
+	s.mu.Unlock()
+	if last != nil {
+		events <- last
+	}
+	notifyControl()
+	return events
+}
+
+// unsubscribe removes the state events subscriber
+func (s *controlServer) unsubscribe(events chan []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.subs[events] {
+		delete(s.subs, events)
+		close(events)
+	}
+}
+
+// forward writes the subscriber events to its connection until it is closed
+func (s *controlServer) forward(events chan []byte, write func(resp control.Response) bool) {
+	for data := range events {
+		if !write(control.Response{Version: control.Version, Event: "state", Data: data}) {
+			return
+		}
+	}
+}
+
+// publish sends the state to the subscribers every time it changes
+func (s *controlServer) publish() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-controlChanged:
+		}
+		if !IsRunning() {
+			continue
+		}
+		state, errCs := currentState()
+		if errCs != nil {
+			continue
+		}
+		data, errJm := json.Marshal(state)
+		if errJm != nil {
+			log.Print(errJm)
+			continue
+		}
+		s.mu.Lock()
+		if !bytes.Equal(data, s.last) {
+			s.last = data
+			for events := range s.subs {
+				sendLatest(events, data)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// sendLatest sends the event without blocking, dropping the oldest pending event of a full channel
+func sendLatest(events chan []byte, data []byte) {
+	for {
+		select {
+		case events <- data:
+			return
+		default:
+		}
+		select {
+		case <-events:
+		default:
+		}
+	}
+}
+
+// currentState returns the main program state
+func currentState() (controlState, error) {
+	state := controlState{Profile: config.Profile}
+	cli, errPc := playerClient()
+	if errPc != nil {
+		return state, errPc
+	}
+	ctx, cancel := playerContext()
+	defer cancel()
+	values := make(map[string]string)
+	for _, name := range []string{"idle-active", "path", "media-title", "pause", "mute", "ao-volume", "playlist-pos", "playlist-count", "loop-file", "loop-playlist"} {
+		value, errGp := cli.GetPropertyString(ctx, name)
+		if errGp != nil && !mpv.IsUnavailable(errGp) {
+			return state, errGp
+		}
+		values[name] = value
+	}
+	state.Idle = values["idle-active"] == "yes"
+	if !state.Idle {
+		state.Path, state.Title = values["path"], values["media-title"]
+		state.Station, state.Name = stationOf(state.Path)
+	}
+	state.Pause, state.Mute = values["pause"] == "yes", values["mute"] == "yes"
+	if volume, errPf := strconv.ParseFloat(values["ao-volume"], 64); errPf == nil {
+		state.Volume = int(math.Round(volume))
+	}
+	if pos, errSa := strconv.Atoi(values["playlist-pos"]); errSa == nil {
+		state.QueuePos = pos + 1
+	}
+	state.QueueCount, _ = strconv.Atoi(values["playlist-count"])
+	if md, errLm := loadModes(); errLm == nil {
+		state.Shuffle = md.Shuffle
+	}
+	state.Repeat = repeatMode(values["loop-file"], values["loop-playlist"])
+	if remaining, ok := sleepRemaining(); ok {
+		state.Sleep = int(remaining.Seconds())
+	}
+	state.Recording = isRecording()
+	if jobs, errLs := loadSchedule(); errLs == nil {
+		state.NextAlarm, state.NextJob = nextJobs(jobs, time.Now())
+	}
+	return state, nil
+}
+
+// nextJobs returns the next start time of the alarms and of the other scheduled jobs, nil when there are none
+func nextJobs(jobs []scheduleJob, now time.Time) (*time.Time, *time.Time) {
+	var nextAlarm, nextJob *time.Time
+	for _, job := range jobs {
+		next := job.next(now)
+		switch {
+		case next.IsZero():
+		case job.Action == "alarm" && (nextAlarm == nil || next.Before(*nextAlarm)):
+			nextAlarm = &next
+		case job.Action != "alarm" && (nextJob == nil || next.Before(*nextJob)):
+			nextJob = &next
+		}
+	}
+	return nextAlarm, nextJob
+}
+
+// Control runs the command in the main program through its control socket, decoding its result into v
+func Control(v interface{}, command string, args ...string) error {
+	if !IsRunning() {
+		return fmt.Errorf("control: error: '%s' is not running\n", config.ProgName)
+	}
+	args, errCa := controlArgs(command, args)
+	if errCa != nil {
+		return errCa
+	}
+	cli, errCd := control.Dial(config.ControlFile, mpv.DefaultTimeout)
+	if errCd != nil {
+		return fmt.Errorf("control: error: %s\n", errCd)
+	}
+	defer cli.Close()
+	cli.Timeout = controlTimeout(command, args)
+	return cli.Call(v, command, args...)
+}
+
+// ControlOutput runs the command writing text in the main program when it is running, or else in this process
+func ControlOutput(command string, args ...string) (string, error) {
+	if IsRunning() {
+		var content string
+		errCo := Control(&content, command, args...)
+		return content, errCo
+	}
+	run, errCh := controlHandlerOf(command, args)
+	if errCh != nil {
+		return "", errCh
+	}
+	result, errRu := run(args)
+	content, _ := result.(string)
+	return content, errRu
+}
+
+// Watch writes the main program state as json lines every time it changes, until the main program stops
+func Watch(w func(line []byte) error) error {
+	if !IsRunning() {
+		return fmt.Errorf("watch: error: '%s' is not running\n", config.ProgName)
+	}
+	cli, errCd := control.Dial(config.ControlFile, mpv.DefaultTimeout)
+	if errCd != nil {
+		return fmt.Errorf("watch: error: %s\n", errCd)
+	}
+	defer cli.Close()
+	if errCs := cli.Subscribe(); errCs != nil {
+		return errCs
+	}
+	for {
+		resp, errCn := cli.Next()
+		if errCn != nil {
+			if !IsRunning() {
+				return nil
+			}
+			return errCn
+		}
+		if resp.Event == "state" {
+			if errWl := w(resp.Data); errWl != nil {
+				return errWl
+			}
+		}
+	}
+}
+
+// controlTimeout returns the maximum time to wait for the command answer, a command may wait for the playback to start
+func controlTimeout(command string, args []string) time.Duration {
+	timeout := mpv.DefaultTimeout + config.PlayTimeout
+	if command == "stations" && len(args) > 0 && args[0] == "check" {
+		timeout += stations.CheckDuration(args[1:])
+	}
+	return timeout
+}
+
+// controlArgs returns the command arguments with the local paths made absolute, the main program runs in another directory
+func controlArgs(command string, args []string) ([]string, error) {
+	dup := append([]string{}, args...)
+	switch {
+	case command == "enqueue", command == "play", command == "resume":
+		for num, arg := range dup {
+			target, errCt := controlTarget(arg)
+			if errCt != nil {
+				return nil, errCt
+			}
+			dup[num] = target
+		}
+	case command == "alarm":
+		return absFlag(dup, "file")
+	case command == "record":
+		return absFlag(dup, "dir")
+	case command == "stations" && len(dup) == 2 && dup[0] == "import":
+		path, errFa := filepath.Abs(dup[1])
+		if errFa != nil {
+			return nil, errFa
+		}
+		dup[1] = path
+	case command == "stations" && len(dup) > 0 && dup[0] == "export":
+		return absFlag(dup, "output")
+	}
+	return dup, nil
+}
+
+// absFlag makes the non empty values of the flag absolute paths, given as -name value or -name=value with one or two dashes
+func absFlag(args []string, name string) ([]string, error) {
+	for num := 0; num < len(args); num++ {
+		arg, value := args[num], -1
+		if arg == "-"+name || arg == "--"+name {
+			value = num + 1
+		} else if strings.HasPrefix(arg, "-"+name+"=") || strings.HasPrefix(arg, "--"+name+"=") {
+			value = num
+		}
+		if value < 0 || value >= len(args) {
+			continue
+		}
+		prefix, path := "", args[value]
+		if value == num {
+			prefix = arg[:strings.Index(arg, "=")+1]
+			path = arg[len(prefix):]
+		}
+		if path == "" {
+			continue
+		}
+		abs, errFa := filepath.Abs(path)
+		if errFa != nil {
+			return nil, errFa
+		}
+		args[value] = prefix + abs
+		num = value
+	}
+	return args, nil
+}
+
+// controlTarget returns the absolute path of a local file as seen by the client, the station ids and the urls are sent as they are
+func controlTarget(arg string) (string, error) {
+	if _, errSa := strconv.Atoi(arg); errSa == nil || utils.ValidUrl(arg) {
+		return arg, nil
+	}
+	if _, errOs := os.Stat(arg); errOs != nil {
+		return arg, nil
+	}
+	return filepath.Abs(arg)
+}
@@ -0,0 +1,313 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+	"github.com/gonzaru/gorum/control"
+	"github.com/gonzaru/gorum/mpv"
+)
+
+// startControlTest serves the control socket during the test
+func startControlTest(t *testing.T) {
+	t.Helper()
+	ctl, errSc := startControl()
+	if errSc != nil {
+		t.Fatal(errSc)
+	}
+	t.Cleanup(func() {
+		ctl.close()
+	})
+}
+
+func TestControl(t *testing.T) {
+	srv := setUpTest(t)
+	startControlTest(t)
+	if err := Control(nil, "play", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if path := srv.Get("path"); path != testStation(1)["url"] {
+		t.Fatalf("expected the station url to play, got %v", path)
+	}
+	if err := Control(nil, "play", "999"); err == nil {
+		t.Fatal("expected error for a missing station")
+	}
+	if err := Control(nil, "volume", "40"); err != nil {
+		t.Fatal(err)
+	}
+	var mute string
+	if err := Control(&mute, "mute"); err != nil || mute != "yes" {
+		t.Fatalf("expected mute yes, got %q %v", mute, err)
+	}
+	var state controlState
+	if err := Control(&state, "state"); err != nil {
+		t.Fatal(err)
+	}
+	if state.Idle || state.Path != testStation(1)["url"] || state.Station != 1 || state.Volume != 40 || !state.Mute {
+		t.Fatalf("unexpected state %+v", state)
+	}
+	if state.QueuePos != 1 || state.QueueCount != 1 || state.Repeat != "off" {
+		t.Fatalf("unexpected queue state %+v", state)
+	}
+	var status string
+	if err := Control(&status, "status"); err != nil || !strings.Contains(status, "file:  "+testStation(1)["url"]+"\n") {
+		t.Fatalf("unexpected status %q %v", status, err)
+	}
+	if err := Control(nil, "jump", "x"); err == nil || !strings.HasPrefix(err.Error(), "jump: error:") {
+		t.Fatalf("expected jump error, got %v", err)
+	}
+	if err := Control(nil, "volume"); err == nil || !strings.Contains(err.Error(), "expects 1 arguments") {
+		t.Fatalf("expected arguments error, got %v", err)
+	}
+	if err := Control(nil, "dance"); err == nil || !strings.Contains(err.Error(), "unknown command 'dance'") {
+		t.Fatalf("expected unknown command error, got %v", err)
+	}
+}
+
+func TestControlNotRunning(t *testing.T) {
+	setUpTest(t)
+	startControlTest(t)
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Control(nil, "next"); err == nil {
+		t.Fatal("expected error when not running")
+	}
+	// the commands run in this process check their arguments too
+	if _, err := ControlOutput("property", "path", "extra"); err == nil || !strings.Contains(err.Error(), "expects 1 arguments, got 2") {
+		t.Fatalf("expected an argument count error, got %v", err)
+	}
+	if _, err := ControlOutput("dance"); err == nil || !strings.Contains(err.Error(), "unknown command 'dance'") {
+		t.Fatalf("expected an unknown command error, got %v", err)
+	}
+}
+
+func TestControlStop(t *testing.T) {
+	setUpTest(t)
+	startControlTest(t)
+	// the wm file cannot be removed when finishing, its error comes back to the client
+	if err := os.MkdirAll(filepath.Join(config.WmFile, "busy"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := Control(nil, "stop"); err == nil || !strings.Contains(err.Error(), config.WmFile) {
+		t.Fatalf("expected the finish error, got %v", err)
+	}
+	setUpTest(t)
+	startControlTest(t)
+	if err := Control(nil, "stop"); err != nil {
+		t.Fatal(err)
+	}
+	if IsRunning() {
+		t.Fatal("expected the main program stopped once stop answers")
+	}
+}
+
+func TestControlVersion(t *testing.T) {
+	setUpTest(t)
+	startControlTest(t)
+	conn, errNd := net.Dial("unix", config.ControlFile)
+	if errNd != nil {
+		t.Fatal(errNd)
+	}
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	for _, line := range []string{`{"version":2,"id":7,"command":"title"}`, `not json`} {
+		if _, err := conn.Write([]byte(line + "\n")); err != nil {
+			t.Fatal(err)
+		}
+		content, errRs := rd.ReadBytes('\n')
+		if errRs != nil {
+			t.Fatal(errRs)
+		}
+		var resp control.Response
+		if err := json.Unmarshal(content, &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Version != control.Version || !strings.HasPrefix(resp.Error, "control: error:") {
+			t.Fatalf("expected a control error for %s, got %+v", line, resp)
+		}
+	}
+}
+
+func TestControlSubscribe(t *testing.T) {
+	setUpTest(t)
+	startControlTest(t)
+	cli, errCd := control.Dial(config.ControlFile, mpv.DefaultTimeout)
+	if errCd != nil {
+		t.Fatal(errCd)
+	}
+	defer cli.Close()
+	if err := cli.Subscribe(); err != nil {
+		t.Fatal(err)
+	}
+	next := func() controlState {
+		t.Helper()
+		var state controlState
+		done := make(chan error, 1)
+		go func() {
+			resp, errCn := cli.Next()
+			if errCn == nil && resp.Event != "state" {
+				t.Errorf("unexpected event %+v", resp)
+			}
+			if errCn == nil {
+				errCn = json.Unmarshal(resp.Data, &state)
+			}
+			done <- errCn
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for a state event")
+		}
+		return state
+	}
+	if state := next(); !state.Idle || state.Pause {
+		t.Fatalf("unexpected first state %+v", state)
+	}
+	if err := Control(nil, "pause"); err != nil {
+		t.Fatal(err)
+	}
+	if state := next(); !state.Pause {
+		t.Fatalf("expected a paused state, got %+v", state)
+	}
+	if err := Control(nil, "shuffle"); err != nil {
+		t.Fatal(err)
+	}
+	if state := next(); !state.Shuffle || !state.Pause {
+		t.Fatalf("expected a shuffled state, got %+v", state)
+	}
+	if err := Control(nil, "schedule", "add", "play", "1", "--at", "07:00"); err != nil {
+		t.Fatal(err)
+	}
+	if state := next(); state.NextJob == nil || state.NextJob.Format("15:04") != "07:00" || state.NextAlarm != nil {
+		t.Fatalf("expected the next job, got %+v", state)
+	}
+	if err := Control(nil, "alarm", "06:30", "--station", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if state := next(); state.NextAlarm == nil || state.NextAlarm.Format("15:04") != "06:30" || state.NextJob == nil {
+		t.Fatalf("expected the next alarm, got %+v", state)
+	}
+
+	// a later subscriber receives the current state first
+	for num := 0; num < 20; num++ {
+		later, errCd := control.Dial(config.ControlFile, mpv.DefaultTimeout)
+		if errCd != nil {
+			t.Fatal(errCd)
+		}
+		if err := later.Subscribe(); err != nil {
+			t.Fatal(err)
+		}
+		got := make(chan control.Response, 1)
+		go func() {
+			if resp, errCn := later.Next(); errCn == nil {
+				got <- resp
+			}
+		}()
+		select {
+		case resp := <-got:
+			if resp.Event != "state" {
+				t.Fatalf("unexpected event %+v", resp)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for the current state")
+		}
+		later.Close()
+	}
+}
+
+func TestSendLatest(t *testing.T) {
+	events := make(chan []byte, controlSubscribers)
+	for num := 0; num < controlSubscribers+3; num++ {
+		sendLatest(events, []byte(strconv.Itoa(num)))
+	}
+	if len(events) != controlSubscribers {
+		t.Fatalf("expected a full channel, got %d events", len(events))
+	}
+	if first := string(<-events); first != "3" {
+		t.Fatalf("expected the oldest events dropped, got %s first", first)
+	}
+	var last []byte
+	for len(events) > 0 {
+		last = <-events
+	}
+	if string(last) != strconv.Itoa(controlSubscribers+2) {
+		t.Fatalf("expected the newest event kept, got %s", last)
+	}
+}
+
+func TestControlOutput(t *testing.T) {
+	setUpTest(t)
+	startControlTest(t)
+	streams, mirrors := config.Stations()
+	t.Cleanup(func() {
+		config.SetStations(streams, mirrors)
+	})
+	config.StationsFile = filepath.Join(t.TempDir(), "stations.json")
+	content, errCo := ControlOutput("stations", "add", "--name", "Offline", "--url", "http://127.0.0.1:1/live", "--id", "50")
+	if errCo != nil || !strings.Contains(content, "added station '50' Offline") {
+		t.Fatalf("unexpected stations add %q %v", content, errCo)
+	}
+	if testStation(50)["name"] != "Offline" {
+		t.Fatalf("expected the station added to the main program, got %v", testStation(50))
+	}
+	content, errCo = ControlOutput("stations", "check", "--timeout", "500ms", "50")
+	if errCo == nil || !strings.Contains(errCo.Error(), "1 of 1 stations are dead") || !strings.Contains(content, "50)  Offline") {
+		t.Fatalf("expected the check output and its error, got %q %v", content, errCo)
+	}
+	if content, errCo = ControlOutput("schedule", "list"); errCo != nil || content != "" {
+		t.Fatalf("unexpected schedule list %q %v", content, errCo)
+	}
+	// the usage text comes back to the client instead of going to the main program output
+	if _, errCo = ControlOutput("history", "-h"); errCo == nil || !strings.Contains(errCo.Error(), "Usage of history:") || !strings.Contains(errCo.Error(), "-since") {
+		t.Fatalf("expected the history usage, got %v", errCo)
+	}
+	content, _ = ControlOutput("stations", "add", "-h")
+	if !strings.Contains(content, "Usage of stations add:") {
+		t.Fatalf("expected the stations add usage, got %q", content)
+	}
+}
+
+func TestControlArgs(t *testing.T) {
+	dir, errOg := os.Getwd()
+	if errOg != nil {
+		t.Fatal(errOg)
+	}
+	tests := []struct {
+		command string
+		args    []string
+		want    []string
+	}{
+		{"record", []string{"start", "--dir", "rec", "--split-by-title"}, []string{"start", "--dir", filepath.Join(dir, "rec"), "--split-by-title"}},
+		{"alarm", []string{"07:00", "-file=a.ogg"}, []string{"07:00", "-file=" + filepath.Join(dir, "a.ogg")}},
+		{"alarm", []string{"07:00", "--file", ""}, []string{"07:00", "--file", ""}},
+		{"stations", []string{"import", "radios.pls"}, []string{"import", filepath.Join(dir, "radios.pls")}},
+		{"stations", []string{"export", "--output", "/tmp/r.m3u"}, []string{"export", "--output", "/tmp/r.m3u"}},
+		{"history", []string{"--station", "1"}, []string{"--station", "1"}},
+		{"play", []string{"3"}, []string{"3"}},
+		{"enqueue", []string{"https://example.org/live"}, []string{"https://example.org/live"}},
+	}
+	for _, test := range tests {
+		args, err := controlArgs(test.command, test.args)
+		if err != nil || strings.Join(args, " ") != strings.Join(test.want, " ") {
+			t.Errorf("%s %q: got %q %v, want %q", test.command, test.args, args, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,373 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+	"github.com/gonzaru/gorum/mpv"
+	"github.com/gonzaru/gorum/utils"
+)
+
+// observedProperties the media player properties observed while running
+var observedProperties = []string{
+	"media-title",
+	"metadata",
+	"path",
+	"idle-active",
+	"eof-reached",
+	"time-pos",
+	"pause",
+	"mute",
+	"ao-volume",
+}
+
+// watcher data type
+type watcher struct {
+	idle      bool
+	lastPath  string
+	lastTitle string
+	loaded    bool
+	path      string
+	pausedAt  time.Time
+	paused    time.Duration
+	pos       float64
+	reconnect *reconnector
+	saved     time.Time
+	song      songEntry
+	started   time.Time
+	title     string
+}
+
+// dialPlayer waits until the media player control file is available and connects to it
+func dialPlayer(timeout time.Duration) (*mpv.Client, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		if errCf := controlFileExists(config.PlayerControlFile); errCf == nil {
+			cli, errMd := mpv.Dial(config.PlayerControlFile)
+			if errMd == nil {
+				return cli, nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("dialPlayer: error: '%s' control file not available\n", config.Player)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// watchEvents observes the media player properties and reacts to its events until the connection is closed
+func watchEvents(cli *mpv.Client) error {
+	events, unsubscribe := cli.Subscribe("time-pos")
+	defer unsubscribe()
+	ctx, cancel := playerContext()
+	defer cancel()
+	for num, name := range observedProperties {
+		if errCo := cli.Observe(ctx, int64(num+1), name); errCo != nil {
+			return errCo
+		}
+	}
+	w := watcher{idle: true, reconnect: newReconnector()}
+	defer w.reconnect.stop()
+	for ev := range events {
+		if errWh := w.handle(ev); errWh != nil {
+			utils.ErrPrint(errWh)
+			log.Print(errWh)
+		}
+	}
+	now := time.Now()
+	if errFs := w.finishSong(now); errFs != nil {
+		return errFs
+	}
+	return w.finishEntry(now)
+}
+
+// finishEntry appends the current file to the play history
+func (w *watcher) finishEntry(now time.Time) error {
+	if w.path == "" || w.started.IsZero() {
+		return nil
+	}
+	paused := w.paused
+	if !w.pausedAt.IsZero() {
+		paused += now.Sub(w.pausedAt)
+	}
+	station, name := stationOf(w.path)
+	entry := historyEntry{
+		Path:     w.path,
+		Station:  station,
+		Name:     name,
+		Title:    w.lastTitle,
+		Start:    w.started,
+		Stop:     now,
+		Duration: (now.Sub(w.started) - paused).Seconds(),
+	}
+	w.started = time.Time{}
+	return config.AppendState(config.HistoryFile, entry)
+}
+
+// finishSong appends the current song to the song log
+func (w *watcher) finishSong(now time.Time) error {
+	if w.song.Title == "" {
+		return nil
+	}
+	w.song.End = now
+	song := w.song
+	w.song = songEntry{}
+	return config.AppendState(config.SongsFile, song)
+}
+
+// forgetPosition removes the saved playback position of the current file
+func (w *watcher) forgetPosition() error {
+	w.pos = 0
+	return forgetPosition(w.path)
+}
+
+// handle reacts to the media player event
+func (w *watcher) handle(ev mpv.Event) error {
+	switch ev.Event {
+	case "property-change":
+		// the playback position changes all the time and it is not part of the control state
+		if ev.Name != "time-pos" {
+			defer notifyControl()
+		}
+		return w.propertyChange(ev.Name, ev.Data)
+	case "start-file":
+		w.loaded = false
+	case "file-loaded":
+		w.loaded = true
+	case "end-file":
+		log.Printf("watchEvents: info: end of file '%s' reason: %s\n", w.path, ev.Reason)
+		if ev.Reason == "error" {
+			log.Printf("watchEvents: error: '%s' %s\n", w.path, ev.FileError)
+		}
+		if ev.Reason == "eof" {
+			if errFe := w.finishEntry(time.Now()); errFe != nil {
+				return errFe
+			}
+			if errFp := w.forgetPosition(); errFp != nil {
+				return errFp
+			}
+		}
+		if ev.Reason == "eof" || ev.Reason == "error" {
+			w.dropped()
+			return w.clear()
+		}
+	}
+	return nil
+}
+
+// propertyChange reacts to the observed property change
+func (w *watcher) propertyChange(name string, data interface{}) error {
+	switch name {
+	case "media-title":
+		if title, ok := data.(string); ok {
+			return w.setTitle(title)
+		}
+	case "metadata":
+		if meta, ok := data.(map[string]interface{}); ok {
+			if errSc := w.songChange(meta); errSc != nil {
+				log.Print(errSc)
+			}
+			if title, okIt := meta["icy-title"].(string); okIt {
+				return w.setTitle(title)
+			}
+		}
+	case "path":
+		path, _ := data.(string)
+		if path != w.path {
+			now := time.Now()
+			if errSp := savePosition(w.path, w.pos); errSp != nil {
+				log.Print(errSp)
+			}
+			if errFs := w.finishSong(now); errFs != nil {
+				log.Print(errFs)
+			}
+			if errFe := w.finishEntry(now); errFe != nil {
+				log.Print(errFe)
+			}
+			w.pos = 0
+			w.lastTitle = ""
+			w.paused = 0
+			if !w.pausedAt.IsZero() {
+				w.pausedAt = now
+			}
+			if path != "" {
+				w.started = now
+			}
+		}
+		if path != "" && path != w.path {
+			log.Printf("watchEvents: info: path: %s\n", path)
+		}
+		if path != "" {
+			if !w.reconnect.owns(path) {
+				w.reconnect.stop()
+			}
+			w.lastPath = path
+		}
+		w.path = path
+		updateSnapshot(func(snap *playerSnapshot) {
+			snap.path = path
+		})
+	case "idle-active":
+		w.idle, _ = data.(bool)
+		if w.idle {
+			return w.clear()
+		}
+	case "eof-reached":
+		if eof, _ := data.(bool); eof {
+			if errFe := w.finishEntry(time.Now()); errFe != nil {
+				return errFe
+			}
+			if errFp := w.forgetPosition(); errFp != nil {
+				return errFp
+			}
+			w.dropped()
+			return w.clear()
+		}
+	case "ao-volume":
+		volume, ok := data.(float64)
+		updateSnapshot(func(snap *playerSnapshot) {
+			snap.volume, snap.hasVolume = volume, ok
+		})
+	case "mute":
+		mute, _ := data.(bool)
+		updateSnapshot(func(snap *playerSnapshot) {
+			snap.mute = mute
+		})
+	case "pause":
+		paused, _ := data.(bool)
+		updateSnapshot(func(snap *playerSnapshot) {
+			snap.pause = paused
+		})
+		if paused && w.pausedAt.IsZero() {
+			w.pausedAt = time.Now()
+		} else if !paused && !w.pausedAt.IsZero() {
+			w.paused += time.Since(w.pausedAt)
+			w.pausedAt = time.Time{}
+		}
+	case "time-pos":
+		pos, ok := data.(float64)
+		if !ok {
+			return nil
+		}
+		w.pos = pos
+		if pos >= resumeMinPosition && time.Since(w.saved) >= resumeInterval {
+			w.saved = time.Now()
+			return savePosition(w.path, w.pos)
+		}
+	}
+	return nil
+}
+
+// dropped reconnects the stream when it ends after having been loaded, live streams are not expected to end
+func (w *watcher) dropped() {
+	if w.loaded && utils.ValidUrl(w.lastPath) {
+		log.Printf("watchEvents: info: stream '%s' dropped\n", w.lastPath)
+		w.loaded = false
+		w.reconnect.start(w.lastPath)
+	}
+}
+
+// songChange starts a new song log entry when the icy title changes, repeated titles are the same song
+func (w *watcher) songChange(meta map[string]interface{}) error {
+	title := songIcyTitle(meta)
+	if title == "" || title == w.song.Title {
+		return nil
+	}
+	now := time.Now()
+	if errFs := w.finishSong(now); errFs != nil {
+		return errFs
+	}
+	station, name := stationName(w.path, meta)
+	w.song = songEntry{Station: station, Name: name, Title: title, Path: w.path, Start: now}
+	return nil
+}
+
+// setTitle logs the new media title and updates the window manager media title file
+func (w *watcher) setTitle(title string) error {
+	if title == "" || title == w.title {
+		return nil
+	}
+	w.title = title
+	w.lastTitle = title
+	log.Printf("start: title: %s\n", title)
+	if errWf := wmFileUpdate(config.WmFile, []byte(title+"\n"), config.WmFilePerms); errWf != nil {
+		return errWf
+	}
+	return nil
+}
+
+// clear forgets the media title and removes the window manager media title file
+func (w *watcher) clear() error {
+	w.title = ""
+	if errFs := w.finishSong(time.Now()); errFs != nil {
+		return errFs
+	}
+	if _, errOs := os.Stat(config.WmFile); errOs == nil {
+		if errOr := os.Remove(config.WmFile); errOr != nil {
+			return errOr
+		}
+		if errWb := wmBarUpdate(); errWb != nil {
+			return errWb
+		}
+	}
+	return nil
+}
+
+// startWatcher connects to the media player and watches its events in background
+func startWatcher() {
+	cli, errDp := dialPlayer(mpv.DefaultTimeout)
+	if errDp != nil {
+		log.Print(errDp)
+		return
+	}
+	if errAm := applyModes(cli); errAm != nil {
+		log.Print(errAm)
+	}
+	go func() {
+		defer cli.Close()
+		if errWe := watchEvents(cli); errWe != nil {
+			log.Print(errWe)
+		}
+	}()
+}
+
+// waitLoaded waits until the media player has loaded the file or has failed loading it
+func waitLoaded(ctx context.Context, events <-chan mpv.Event, file string, timeout time.Duration) error {
+	started := false
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return fmt.Errorf("play: error: '%s' connection closed while loading '%s'\n", config.Player, file)
+			}
+			switch ev.Event {
+			case "start-file":
+				started = true
+			case "file-loaded":
+				if started {
+					return nil
+				}
+			case "end-file":
+				if started && ev.Reason == "error" {
+					reason := ev.FileError
+					if reason == "" {
+						reason = "unknown error"
+					}
+					return fmt.Errorf("play: error: '%s' cannot be played: %s\n", file, reason)
+				}
+			}
+		case <-ctx.Done():
+			return fmt.Errorf("play: error: '%s' did not start playing within %s\n", file, timeout)
+		}
+	}
+}
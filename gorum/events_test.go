@@ -0,0 +1,70 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+	"github.com/gonzaru/gorum/mpv"
+)
+
+// waitFor waits until cond is true or fails the test
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// wmFileContent returns the window manager media title file content
+func wmFileContent() string {
+	content, _ := os.ReadFile(config.WmFile)
+	return string(content)
+}
+
+func TestWatchEvents(t *testing.T) {
+	srv := setUpTest(t)
+	cli, errMd := mpv.Dial(config.PlayerControlFile)
+	if errMd != nil {
+		t.Fatal(errMd)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- watchEvents(cli)
+	}()
+	waitFor(t, "observed properties", func() bool {
+		return len(srv.Commands()) >= len(observedProperties)
+	})
+	if err := Play("2"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "media title", func() bool {
+		return wmFileContent() == "nebenwelten\n"
+	})
+	srv.Set("metadata", map[string]interface{}{"icy-title": "Artist - Song"})
+	waitFor(t, "icy title", func() bool {
+		return wmFileContent() == "Artist - Song\n"
+	})
+	if err := PlayStop(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "media title file removal", func() bool {
+		_, errOs := os.Stat(config.WmFile)
+		return os.IsNotExist(errOs)
+	})
+	cli.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -5,17 +5,15 @@ package gorum
 
 import (
 	"bufio"
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
-	"net"
 	"os"
 	"os/exec"
 	"os/signal"
-	"path/filepath"
-	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
@@ -27,9 +25,18 @@ import (
 // local packages
 import (
 	"github.com/gonzaru/gorum/config"
+	"github.com/gonzaru/gorum/mpv"
 	"github.com/gonzaru/gorum/utils"
 )
 
+var (
+	client   *mpv.Client
+	clientMu sync.Mutex
+
+	// finishMu serializes finish, the program may finish from Start, the signal handler and the stop command
+	finishMu sync.Mutex
+)
+
 // checkOS checks if the current operating system has been tested
 func checkOS() bool {
 	status := false
@@ -63,17 +70,16 @@ func CheckOut() error {
 // cleanUp removes the temporary files if necessary
 func cleanUp() error {
 	files := []string{
-		config.LockDir,
+		config.ControlFile,
 		config.PidFile,
 		config.PlayerControlFile,
 		config.PlayerPidFile,
+		config.ReconnectFile,
 		config.WmFile,
 	}
 	for _, file := range files {
-		if _, errOs := os.Stat(file); errOs == nil {
-			if errOr := os.Remove(file); errOr != nil {
-				return errOr
-			}
+		if errOr := os.Remove(file); errOr != nil && !errors.Is(errOr, os.ErrNotExist) {
+			return errOr
 		}
 	}
 	if errWb := wmBarUpdate(); errWb != nil {
@@ -96,15 +102,17 @@ func controlFileExists(file string) error {
 	return nil
 }
 
-// finish performs actions before leaving the program
+// finish performs actions before leaving the program, running it again once finished does nothing
 func finish() error {
+	finishMu.Lock()
+	defer finishMu.Unlock()
 	if errSp := stopPlayer(); errSp != nil {
 		return errSp
 	}
 	if errCu := cleanUp(); errCu != nil {
 		return errCu
 	}
-	return nil
+	return unlock()
 }
 
 // Help shows help information
@@ -114,13 +122,46 @@ func Help() {
 	maxVol := config.VolumeMax
 	fmt.Print("Usage:\n")
 	fmt.Printf("  %s check          # checks if %s is already running or locked\n", progName, progName)
-	fmt.Printf("  %s number         # number key id from config.Streams\n", progName)
+	fmt.Printf("  %s number         # number key id from the stations list\n", progName)
 	fmt.Printf("  %s url            # plays the stream url\n", progName)
 	fmt.Printf("  %s /path/to/file  # plays the local file\n", progName)
+	fmt.Printf("  %s --resume file  # plays the local file from its saved position\n", progName)
+	fmt.Printf("  %s /path/to/dir   # plays the directory media files as a queue\n", progName)
+	fmt.Printf("  %s list.m3u       # plays the m3u, pls or xspf playlist as a queue\n", progName)
+	fmt.Printf("  %s enqueue file   # appends a number, url, file, directory or playlist to the queue\n", progName)
+	fmt.Printf("  %s next           # plays the next queue entry\n", progName)
+	fmt.Printf("  %s prev           # plays the previous queue entry\n", progName)
+	fmt.Printf("  %s jump n         # plays the queue entry number n\n", progName)
+	fmt.Printf("  %s queue          # lists the queue, the current entry is marked [ls]\n", progName)
+	fmt.Printf("  %s queue clear    # removes every queue entry except the current one\n", progName)
+	fmt.Printf("  %s queue remove n # removes the queue entry number n [rm]\n", progName)
+	fmt.Printf("  %s history [--since d] [--station n] [--json]\n", progName)
+	fmt.Printf("  %s                # lists the play history, the most recent first\n", strings.Repeat(" ", len(progName)))
+	fmt.Printf("  %s history replay n # plays the history entry number n again\n", progName)
+	fmt.Printf("  %s songs [--since d] [--until d] [--station n] [--json] [pattern]\n", progName)
+	fmt.Printf("  %s                # lists or greps the radio song log, the most recent first\n", strings.Repeat(" ", len(progName)))
+	fmt.Printf("  %s record start [--dir dir] [--split-by-title]\n", progName)
+	fmt.Printf("  %s                # records the playing stream, one file per stream title when splitting\n", strings.Repeat(" ", len(progName)))
+	fmt.Printf("  %s record stop    # stops recording the stream\n", progName)
+	fmt.Printf("  %s schedule       # lists the scheduled jobs and their next run [ls]\n", progName)
+	fmt.Printf("  %s schedule add play|record n --at hh:mm [--until hh:mm] [--days d] [--missed skip|run]\n", progName)
+	fmt.Printf("  %s                # plays or records the station n on the days (daily, weekdays, mon,wed, sat-sun)\n", strings.Repeat(" ", len(progName)))
+	fmt.Printf("  %s schedule remove n # removes the scheduled job number n [rm]\n", progName)
+	fmt.Printf("  %s alarm hh:mm [--station n] [--fade 60s] [--volume n] [--file f] [--days d]\n", progName)
+	fmt.Printf("  %s                # plays the station fading in the volume, or the file if the station fails\n", strings.Repeat(" ", len(progName)))
+	fmt.Printf("  %s alarm          # lists the alarms [ls]\n", progName)
+	fmt.Printf("  %s alarm cancel n # cancels the alarm number n [rm]\n", progName)
+	fmt.Printf("  %s sleep 30m [--fade 60s] [--quit]\n", progName)
+	fmt.Printf("  %s                # stops the playback, or %s with --quit, fading out the volume\n", strings.Repeat(" ", len(progName)), progName)
+	fmt.Printf("  %s sleep          # prints the sleep timer remaining time\n", progName)
+	fmt.Printf("  %s sleep cancel   # cancels the sleep timer\n", progName)
+	fmt.Printf("  %s shuffle        # toggles the queue shuffle mode\n", progName)
+	fmt.Printf("  %s repeat mode    # sets the repeat mode off|one|all, cycles without mode\n", progName)
 	fmt.Printf("  %s start          # starts %s\n", progName, progName)
 	fmt.Printf("  %s stop           # stops %s\n", progName, progName)
 	fmt.Printf("  %s stopplay       # stops playing the current media file [stopp]\n", progName)
 	fmt.Printf("  %s status         # prints status information\n", progName)
+	fmt.Printf("  %s stations       # manages the stations list, see '%s stations help'\n", progName, progName)
 	fmt.Printf("  %s seek +n/-n     # seeks forward (+n) or backward (-n) number in seconds\n", progName)
 	fmt.Printf("  %s title          # prints media title\n", progName)
 	fmt.Printf("  %s mute           # toggles between mute and unmute\n", progName)
@@ -128,93 +169,140 @@ func Help() {
 	fmt.Printf("  %s video          # toggles between video auto and off\n", progName)
 	fmt.Printf("  %s volume n       # sets volume number between (%d-%d) [vol]\n", progName, minVol, maxVol)
 	fmt.Printf("  %s menu           # opens an interactive menu\n", progName)
+	fmt.Printf("  %s profiles       # lists the running profiles, their pid and media title\n", progName)
+	fmt.Printf("  %s watch          # prints the state as json lines every time it changes\n", progName)
 	fmt.Printf("  %s help           # shows help menu information\n", progName)
+	fmt.Printf("  %s --profile name command # runs the command in the named instance, with its own files and stations\n", progName)
+	fmt.Print("Files:\n")
+	fmt.Printf("  %s  # settings\n", config.ConfigFile)
+	if config.ProfileConfigFile != "" {
+		fmt.Printf("  %s  # profile settings\n", config.ProfileConfigFile)
+	}
+	fmt.Printf("  %s  # stations list\n", config.StationsFile)
+	fmt.Printf("  %s  # play history\n", config.HistoryFile)
+	fmt.Printf("  %s  # shuffle and repeat modes\n", config.ModesFile)
+	fmt.Printf("  %s  # local files playback positions\n", config.ResumeFile)
+	fmt.Printf("  %s  # radio song log\n", config.SongsFile)
+	fmt.Printf("  %s  # recorded streams\n", config.RecordDir)
+	fmt.Printf("  %s  # scheduled jobs\n", config.ScheduleFile)
 }
 
 // isIdle checks if no file is loaded
 func isIdle() bool {
-	status := false
-	cmd := `{"command": ["get_property_string", "idle-active"]}`
-	if _, content, errSc := SendCmd(cmd); errSc == nil && content["data"] == "yes" {
-		status = true
+	cli, errPc := playerClient()
+	if errPc != nil {
+		return false
 	}
-	return status
+	ctx, cancel := playerContext()
+	defer cancel()
+	status, errGb := cli.GetBool(ctx, "idle-active")
+	return status && errGb == nil
 }
 
 // IsRunning checks if the main program is locked or already running
 func IsRunning() bool {
-	if fi, errOs := os.Stat(config.LockDir); errOs == nil && fi.IsDir() {
-		return true
-	}
-	if status, errPf := utils.PidFileExists(config.PidFile); status && errPf == nil {
-		return true
-	}
-	return false
+	return locked()
 }
 
 // isSeekable checks if it's possible to seek the current file
 func isSeekable() bool {
-	status := false
-	cmd := `{"command": ["get_property_string", "seekable"]}`
-	if _, content, errSc := SendCmd(cmd); errSc == nil && content["data"] == "yes" {
-		status = true
+	cli, errPc := playerClient()
+	if errPc != nil {
+		return false
 	}
-	return status
+	ctx, cancel := playerContext()
+	defer cancel()
+	status, errGb := cli.GetBool(ctx, "seekable")
+	return status && errGb == nil
 }
 
 // Play plays media files
 func Play(file string) error {
+	return PlayWait(file, 0)
+}
+
+// PlayResume plays media files resuming the saved playback position of local files
+func PlayResume(file string, timeout time.Duration) error {
+	return playWait(file, timeout, true)
+}
+
+// PlayWait plays media files waiting up to timeout until playback starts, zero does not wait
+func PlayWait(file string, timeout time.Duration) error {
+	return playWait(file, timeout, config.Resume)
+}
+
+// playWait plays media files waiting up to timeout until playback starts, resuming local files if resume is set
+func playWait(file string, timeout time.Duration, resume bool) error {
+	if resume && timeout <= 0 {
+		timeout = config.PlayTimeout
+	}
 	if !IsRunning() {
 		return fmt.Errorf("play: error: '%s' is not running\n", config.ProgName)
 	}
 	if os.Getenv("DISPLAY") == "" {
-		cmd := `{"command": ["set_property", "video", false]}`
-		if _, _, errSc := SendCmd(cmd); errSc != nil {
-			return errSc
+		cli, errPc := playerClient()
+		if errPc != nil {
+			return errPc
+		}
+		ctx, cancel := playerContext()
+		defer cancel()
+		if errSp := cli.SetProperty(ctx, "video", false); errSp != nil {
+			return errSp
 		}
 	}
 	streamInt, errSa := strconv.Atoi(file)
 	if errSa == nil {
-		if errPs := playStream(streamInt); errPs != nil {
+		if errPs := playStream(streamInt, timeout); errPs != nil {
 			return errPs
 		}
 	} else {
-		if errPf := playFile(file); errPf != nil {
+		if errPf := playFile(file, timeout); errPf != nil {
 			return errPf
 		}
 	}
+	if resume {
+		cli, errPc := playerClient()
+		if errPc != nil {
+			return errPc
+		}
+		if errRp := resumePlayer(cli); errRp != nil {
+			return errRp
+		}
+	}
 	if errWb := wmBarUpdate(); errWb != nil {
 		return errWb
 	}
 	return nil
 }
 
-// playFile plays streaming media files or local files
-func playFile(file string) error {
-	var (
-		fileLoad string
-		isLocal  = false
-		isStream = false
-	)
-	if fi, errOs := os.Stat(file); errOs == nil {
-		if fi.IsDir() {
-			return fmt.Errorf("playFile: error: '%s' is a directory, not a file\n", file)
-		}
-		isLocal = true
-	} else if utils.ValidUrl(file) {
-		isStream = true
-		fileLoad = file
+// playerClient returns the persistent media player client, connecting it if necessary
+func playerClient() (*mpv.Client, error) {
+	if !IsRunning() {
+		return nil, fmt.Errorf("playerClient: error: '%s' is not running\n", config.ProgName)
 	}
-	if !isLocal && !isStream {
-		return fmt.Errorf("playFile: error: '%s' no such file or stream url\n", file)
+	clientMu.Lock()
+	defer clientMu.Unlock()
+	if client != nil && !client.Closed() {
+		return client, nil
 	}
-	if isLocal {
-		fileAbs, errFa := filepath.Abs(file)
-		if errFa != nil {
-			return errFa
-		}
-		fileLoad = fileAbs
+	if errCf := controlFileExists(config.PlayerControlFile); errCf != nil {
+		return nil, errCf
+	}
+	cli, errMd := mpv.Dial(config.PlayerControlFile)
+	if errMd != nil {
+		return nil, errMd
 	}
+	client = cli
+	return client, nil
+}
+
+// playerContext returns a context bounded by the media player timeout
+func playerContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), mpv.DefaultTimeout)
+}
+
+// playerLoad loads the files or urls replacing the current playlist, waiting up to timeout until playback starts
+func playerLoad(files []string, timeout time.Duration) error {
 	if errPs := PlayStop(); errPs != nil {
 		return errPs
 	}
@@ -223,32 +311,93 @@ func playFile(file string) error {
 			return errOr
 		}
 	}
-	cmd := "{\"command\": [\"loadfile\", \"" + fileLoad + "\", \"replace\"]}"
-	if _, _, errSc := SendCmd(cmd); errSc != nil {
-		return errSc
+	cli, errPc := playerClient()
+	if errPc != nil {
+		return errPc
+	}
+	// with the shuffle mode on the whole queue is shuffled before playing its first entry
+	shuffle := false
+	if len(files) > 1 {
+		md, errLm := loadModes()
+		if errLm != nil {
+			return errLm
+		}
+		shuffle = md.Shuffle
+	}
+	fileLoad := files[0]
+	events, unsubscribe := cli.Subscribe()
+	defer unsubscribe()
+	if shuffle {
+		var errSq error
+		if fileLoad, errSq = shuffleQueue(cli, files); errSq != nil {
+			return errSq
+		}
+	}
+	ctx, cancel := playerContext()
+	if timeout > 0 {
+		cancel()
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	}
+	defer cancel()
+	var errLf error
+	if shuffle {
+		errLf = cli.PlaylistPlayIndex(ctx, 0)
+	} else {
+		errLf = cli.LoadFile(ctx, fileLoad, "replace")
+	}
+	if errLf != nil {
+		if timeout > 0 && ctx.Err() != nil {
+			return fmt.Errorf("play: error: '%s' did not start playing within %s\n", fileLoad, timeout)
+		}
+		return errLf
+	}
+	if timeout > 0 {
+		if errWl := waitLoaded(ctx, events, fileLoad, timeout); errWl != nil {
+			return errWl
+		}
+	}
+	// the events are not read anymore while appending the rest of the queue
+	unsubscribe()
+	if shuffle {
+		return nil
+	}
+	for _, file := range files[1:] {
+		ctxAppend, cancelAppend := playerContext()
+		errLf := cli.LoadFile(ctxAppend, file, "append")
+		cancelAppend()
+		if errLf != nil {
+			return errLf
+		}
 	}
 	return nil
 }
 
-// playStream plays streaming media files
-func playStream(stream int) error {
-	streams := config.Streams
-	if _, ok := streams[stream]["url"]; !ok {
-		return fmt.Errorf("playStream: error: key map '%d' not found in streams\n", stream)
+// playFile plays streaming media files, local files, directories or playlist files
+func playFile(file string, timeout time.Duration) error {
+	files, errMf := mediaFiles(file)
+	if errMf != nil {
+		return errMf
 	}
-	if errPs := PlayStop(); errPs != nil {
-		return errPs
+	return playerLoad(files, timeout)
+}
+
+// playStream plays the station stream, failing over to its mirror urls when it fails to load
+func playStream(stream int, timeout time.Duration) error {
+	urls := config.StationUrls(stream)
+	if len(urls) == 0 {
+		return fmt.Errorf("playStream: error: key map '%d' not found in streams\n", stream)
 	}
-	if _, errOs := os.Stat(config.WmFile); errOs == nil {
-		if errOr := os.Remove(config.WmFile); errOr != nil {
-			return errOr
+	var errPl error
+	for num, url := range urls {
+		if num > 0 {
+			log.Print(errPl)
+			log.Printf("playStream: info: failing over to the mirror '%s'\n", url)
+		}
+		if errPl = playerLoad([]string{url}, timeout); errPl == nil {
+			return nil
 		}
 	}
-	cmd := "{\"command\": [\"loadfile\", \"" + streams[stream]["url"] + "\", \"replace\"]}"
-	if _, _, errSc := SendCmd(cmd); errSc != nil {
-		return errSc
-	}
-	return nil
+	return errPl
 }
 
 // PlayStop stops playing the current media
@@ -259,12 +408,17 @@ func PlayStop() error {
 	if isIdle() {
 		return nil
 	}
-	cmds := []string{
-		`{"command": ["playlist-remove", "current"]}`,
-		`{"command": ["stop"]}`,
+	cli, errPc := playerClient()
+	if errPc != nil {
+		return errPc
 	}
-	if _, errSc := sendCmds(cmds, false); errSc != nil {
-		return errSc
+	ctx, cancel := playerContext()
+	defer cancel()
+	if errPr := cli.PlaylistRemoveCurrent(ctx); errPr != nil {
+		return errPr
+	}
+	if errCs := cli.Stop(ctx); errCs != nil {
+		return errCs
 	}
 	if _, errOs := os.Stat(config.WmFile); errOs == nil {
 		if errOr := os.Remove(config.WmFile); errOr != nil {
@@ -288,13 +442,29 @@ func Seek(seconds int) error {
 	if !isSeekable() {
 		return fmt.Errorf("seek: error: the current file is not seekable\n")
 	}
-	cmd := fmt.Sprintf(`{"command": ["seek", "%d"]}`, seconds)
-	if _, _, errSc := SendCmd(cmd); errSc != nil {
-		return errSc
+	cli, errPc := playerClient()
+	if errPc != nil {
+		return errPc
+	}
+	ctx, cancel := playerContext()
+	defer cancel()
+	if errCs := cli.Seek(ctx, float64(seconds)); errCs != nil {
+		return errCs
 	}
 	return nil
 }
 
+// Property returns the media player property value formatted as a string
+func Property(name string) (string, error) {
+	cli, errPc := playerClient()
+	if errPc != nil {
+		return "", errPc
+	}
+	ctx, cancel := playerContext()
+	defer cancel()
+	return cli.GetPropertyString(ctx, name)
+}
+
 // SendCmd sends the command to media player
 func SendCmd(cmd string) ([]byte, map[string]interface{}, error) {
 	var content map[string]interface{}
@@ -304,32 +474,13 @@ func SendCmd(cmd string) ([]byte, map[string]interface{}, error) {
 	if !json.Valid([]byte(cmd)) {
 		return nil, nil, fmt.Errorf("sendCmd: error: invalid json %s\n", cmd)
 	}
-	if errCf := controlFileExists(config.PlayerControlFile); errCf != nil {
-		return nil, nil, errCf
+	cli, errPc := playerClient()
+	if errPc != nil {
+		return nil, nil, errPc
 	}
-	conn, errNd := net.Dial("unix", config.PlayerControlFile)
-	if errNd != nil {
-		return nil, nil, errNd
-	}
-	defer func() {
-		if errCc := conn.Close(); errCc != nil {
-			utils.ErrPrint(errCc)
-			log.Fatal(errCc)
-		}
-	}()
-	sendData := cmd + "\n"
-	if num, errCw := conn.Write([]byte(sendData)); num != len(sendData) || errCw != nil {
-		return nil, nil, errCw
-	}
-	// avoids [ipc_0] Write error (Broken pipe)
-	time.Sleep(time.Millisecond * 100)
-	recvData := make([]byte, 1024)
-	if _, errCr := conn.Read(recvData); errCr != nil {
-		return nil, nil, errCr
-	}
-	dataJson := bytes.Split(recvData, []byte{'\n'})[0]
-	if !json.Valid(dataJson) {
-		return nil, nil, fmt.Errorf("sendCmd: error: invalid json %s\n", cmd)
+	dataJson, errCs := cli.Send([]byte(cmd))
+	if errCs != nil {
+		return nil, nil, errCs
 	}
 	if errJu := json.Unmarshal(dataJson, &content); errJu != nil {
 		return nil, nil, errJu
@@ -337,40 +488,34 @@ func SendCmd(cmd string) ([]byte, map[string]interface{}, error) {
 	return dataJson, content, nil
 }
 
-// sendCmds sends the commands to media player
+// sendCmds sends the commands to media player through its persistent client
 func sendCmds(cmds []string, async bool) ([][]interface{}, error) {
-	var (
-		dataJson []byte
-		content  map[string]interface{}
-		err      error
-	)
 	if !IsRunning() {
 		return nil, fmt.Errorf("sendCmds: error: '%s' is not running\n", config.ProgName)
 	}
 	arrSc := make([][]interface{}, len(cmds))
 	if async {
 		var wg sync.WaitGroup
+		errs := make([]error, len(cmds))
 		wg.Add(len(cmds))
 		for num, cmd := range cmds {
 			go func(num int, cmd string) {
 				defer wg.Done()
-				dataJson, content, err = SendCmd(cmd)
-				arrSc[num] = append(arrSc[num], dataJson)
-				arrSc[num] = append(arrSc[num], content)
-				arrSc[num] = append(arrSc[num], err)
-				if err != nil {
-					utils.ErrPrint(err)
-					log.Fatal(err)
-				}
+				dataJson, content, err := SendCmd(cmd)
+				arrSc[num] = []interface{}{dataJson, content, err}
+				errs[num] = err
 			}(num, cmd)
 		}
 		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				return arrSc, err
+			}
+		}
 	} else {
 		for num, cmd := range cmds {
-			dataJson, content, err = SendCmd(cmd)
-			arrSc[num] = append(arrSc[num], dataJson)
-			arrSc[num] = append(arrSc[num], content)
-			arrSc[num] = append(arrSc[num], err)
+			dataJson, content, err := SendCmd(cmd)
+			arrSc[num] = []interface{}{dataJson, content, err}
 			if err != nil {
 				return arrSc, err
 			}
@@ -381,8 +526,11 @@ func sendCmds(cmds []string, async bool) ([][]interface{}, error) {
 
 // SetLog sets logging output file
 func SetLog() error {
+	if errMr := config.MakeRuntimeDir(); errMr != nil {
+		return errMr
+	}
 	// create file if does not exist or append it
-	file, err := os.OpenFile(config.Log, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	file, err := utils.OpenPrivate(config.Log, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
 	if err != nil {
 		return err
 	}
@@ -392,10 +540,15 @@ func SetLog() error {
 
 // setUp creates initial starting files
 func setUp() error {
-	if errCd := os.Mkdir(config.LockDir, 0700); errCd != nil {
-		return errCd
+	if errLo := lock(); errLo != nil {
+		return errLo
+	}
+	if errCs := cleanStale(); errCs != nil {
+		unlock()
+		return errCs
 	}
 	if errCp := os.WriteFile(config.PidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0600); errCp != nil {
+		unlock()
 		return errCp
 	}
 	return nil
@@ -436,40 +589,13 @@ func SignalHandler() {
 	os.Exit(code)
 }
 
-// scanOut scans the output text
-func scanOut(stdout io.ReadCloser) error {
-	title := ""
+// logOut logs the media player output text
+func logOut(stdout io.ReadCloser) error {
 	scanner := bufio.NewScanner(stdout)
 	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.Contains(line, "[cplayer] audio EOF reached") {
-			if _, errOs := os.Stat(config.WmFile); errOs == nil {
-				if errOr := os.Remove(config.WmFile); errOr != nil {
-					return errOr
-				}
-				if errWb := wmBarUpdate(); errWb != nil {
-					return errWb
-				}
-			}
-			continue
-		}
-		regexTitle := regexp.MustCompile(`^\sicy-title:\s|^Title:\s|^\[cplayer].*/force-media-title=`)
-		if strings.Contains(line, "[file] Opening ") {
-			lineSplit := strings.Split(line, "/")
-			title = strings.TrimSpace(lineSplit[len(lineSplit)-1])
-		} else if match := regexTitle.MatchString(line); match {
-			title = strings.Trim(strings.TrimRight(regexTitle.ReplaceAllString(line, ""), " -> 1"), `"`)
-		} else {
-			continue
-		}
-		if title != "" {
-			log.Printf("start: title: %s\n", title)
-			if errWf := wmFileUpdate(config.WmFile, []byte(title+"\n"), config.WmFilePerms); errWf != nil {
-				return errWf
-			}
-		}
+		log.Printf("start: %s: %s\n", config.Player, scanner.Text())
 	}
-	return nil
+	return scanner.Err()
 }
 
 // Start starts the main program
@@ -488,31 +614,58 @@ func Start() error {
 	}()
 	msg := fmt.Sprintf("start: info: starting '%s'\n", config.ProgName)
 	log.Print(msg)
-	cmd := exec.Command(config.Player, config.PlayerArgs...)
-	stdout, errSp := cmd.StdoutPipe()
-	if errSp != nil {
-		return errSp
-	}
-	cmd.Stderr = cmd.Stdout
-	if errCs := cmd.Start(); errCs != nil {
-		return errCs
-	}
-	if errCp := os.WriteFile(config.PlayerPidFile, []byte(strconv.Itoa(cmd.Process.Pid)+"\n"), 0600); errCp != nil {
-		return errCp
-	}
-	msg = fmt.Sprintf(
-		"start: info: %s pid: %d, %s pid: %d\n", config.ProgName, os.Getpid(), config.Player, cmd.Process.Pid,
+	var (
+		loop crashLoop
+		snap *playerSnapshot
 	)
-	fmt.Print(msg)
-	log.Print(msg)
-	log.Printf("start: info: run %s\n", strings.Join(cmd.Args, " "))
-	if errDn := scanOut(stdout); errDn != nil {
-		return errDn
-	}
-	if errCw := cmd.Wait(); errCw != nil {
-		return fmt.Errorf("start: error: '%s' command error %s\n", config.Player, errCw.Error())
+	for {
+		cmd, stdout, errSp := startPlayer()
+		if errSp != nil {
+			return errSp
+		}
+		startWatcher()
+		if snap == nil {
+			ctl, errSc := startControl()
+			if errSc != nil {
+				return errSc
+			}
+			defer ctl.close()
+			startScheduler()
+			startSleeper()
+		} else {
+			go func(snap playerSnapshot) {
+				if errRp := restorePlayer(snap); errRp != nil {
+					log.Print(errRp)
+				}
+			}(*snap)
+		}
+		if errLo := logOut(stdout); errLo != nil {
+			return errLo
+		}
+		errCw := cmd.Wait()
+		if !IsRunning() || !playerCrashed(cmd.ProcessState) {
+			if errCw != nil {
+				return fmt.Errorf("start: error: '%s' command error %s\n", config.Player, errCw.Error())
+			}
+			return nil
+		}
+		log.Printf("start: error: '%s' crashed: %s\n", config.Player, cmd.ProcessState)
+		last := currentSnapshot()
+		snap = &last
+		delay, ok := loop.crash(time.Now())
+		if !ok {
+			return fmt.Errorf(
+				"start: error: '%s' crashed %d times within %s, giving up, see playerRestartMax\n",
+				config.Player, len(loop.crashes), restartWindow,
+			)
+		}
+		log.Printf("start: info: restarting '%s' in %s\n", config.Player, delay)
+		time.Sleep(delay)
+		// the pid file is removed when stopping
+		if _, errOs := os.Stat(config.PidFile); errOs != nil {
+			return nil
+		}
 	}
-	return nil
 }
 
 // Status prints the status information
@@ -524,87 +677,104 @@ func Status() (string, error) {
 	return content, err
 }
 
-// StatusCmd checks command status
-func StatusCmd(cmd string, field string, maxTries int) (map[string]interface{}, error) {
+// statusPlayer prints the media player status information
+func statusPlayer() (string, error) {
+	var statusInfo strings.Builder
+	cli, errPc := playerClient()
+	if errPc != nil {
+		return "", errPc
+	}
+	ctx, cancel := playerContext()
+	defer cancel()
+	meta := json.RawMessage("{}")
+	if errCg := cli.Get(ctx, "metadata", &meta); errCg != nil && !mpv.IsUnavailable(errCg) {
+		return "", errCg
+	}
+	outPretty, errJp := utils.JsonPretty(meta, "", "    ")
+	if errJp != nil {
+		return "", errJp
+	}
+	names := []string{
+		"mute",
+		"pause",
+		"video",
+		"idle-active",
+		"seekable",
+		"media-title",
+		"path",
+		"file-format",
+		"duration",
+		"time-pos",
+		"time-remaining",
+		"percent-pos",
+		"ao-volume",
+		"eof-reached",
+		"playlist-pos",
+		"playlist-count",
+		"loop-file",
+		"loop-playlist",
+	}
+	values := make(map[string]string, len(names))
+	errs := make([]error, len(names))
 	var (
-		content map[string]interface{}
-		err     error = nil
-		errSc   error = nil
+		mu sync.Mutex
+		wg sync.WaitGroup
 	)
-	if !IsRunning() {
-		return nil, fmt.Errorf("statusCmd: error: '%s' is not running\n", config.ProgName)
-	}
-	for i := 0; i < maxTries; i++ {
-		time.Sleep(time.Second)
-		_, content, errSc = SendCmd(cmd)
-		if errSc != nil {
-			err = errSc
-			break
-		}
-		if _, okFi := content[field]; okFi && content["error"] == "success" {
-			err = nil
-			break
-		}
-		if _, okDa := content["data"]; okDa {
-			if _, okFi := content["data"].(map[string]interface{})[field]; okFi && content["error"] == "success" {
-				break
+	wg.Add(len(names))
+	for num, name := range names {
+		go func(num int, name string) {
+			defer wg.Done()
+			value, errGp := cli.GetPropertyString(ctx, name)
+			if errGp != nil && !mpv.IsUnavailable(errGp) {
+				errs[num] = errGp
 			}
+			mu.Lock()
+			values[name] = value
+			mu.Unlock()
+		}(num, name)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return "", err
 		}
-		if i == maxTries-1 {
-			err = fmt.Errorf("statusCmd: error: property '%s' unavailable\n", field)
+	}
+	statusInfo.WriteString(fmt.Sprintf("mute:  %s\n", values["mute"]))
+	statusInfo.WriteString(fmt.Sprintf("pause: %s\n", values["pause"]))
+	statusInfo.WriteString(fmt.Sprintf("video: %s\n", values["video"]))
+	statusInfo.WriteString(fmt.Sprintf("idle:  %s\n", values["idle-active"]))
+	statusInfo.WriteString(fmt.Sprintf("seek:  %s\n", values["seekable"]))
+	statusInfo.WriteString(fmt.Sprintf("title: %s\n", values["media-title"]))
+	statusInfo.WriteString(fmt.Sprintf("file:  %s\n", values["path"]))
+	statusInfo.WriteString(fmt.Sprintf("ffmt:  %s\n", values["file-format"]))
+	if count, errSa := strconv.Atoi(values["playlist-count"]); errSa == nil && count > 0 {
+		pos, errSa := strconv.Atoi(values["playlist-pos"])
+		if errSa != nil {
+			pos = -1
 		}
+		statusInfo.WriteString(fmt.Sprintf("list:  %d/%d\n", pos+1, count))
 	}
-	return content, err
-}
-
-// statusPlayer prints the media player status information
-func statusPlayer() (string, error) {
-	var statusInfo strings.Builder
-	cmd := `{"command": ["get_property", "metadata"]}`
-	dataJson, _, errSc := SendCmd(cmd)
-	if errSc != nil {
-		return "", errSc
+	if values["seekable"] == "yes" {
+		statusInfo.WriteString(fmt.Sprintf("time:  duration:  %s\n", values["duration"]))
+		statusInfo.WriteString(fmt.Sprintf("time:  position:  %s\n", values["time-pos"]))
+		statusInfo.WriteString(fmt.Sprintf("time:  remaining: %s\n", values["time-remaining"]))
+		statusInfo.WriteString(fmt.Sprintf("time:  percent:   %s\n", values["percent-pos"]))
 	}
-	outPretty, errJp := utils.JsonPretty(dataJson, "", "    ")
-	if errJp != nil {
-		return "", errJp
+	shuffle := "no"
+	if md, errLm := loadModes(); errLm == nil && md.Shuffle {
+		shuffle = "yes"
+	}
+	statusInfo.WriteString(fmt.Sprintf("shuf:  %s\n", shuffle))
+	statusInfo.WriteString(fmt.Sprintf("rept:  %s\n", repeatMode(values["loop-file"], values["loop-playlist"])))
+	statusInfo.WriteString(fmt.Sprintf("vol%%:  %s\n", values["ao-volume"]))
+	statusInfo.WriteString(reconnectStatus())
+	if remaining, ok := sleepRemaining(); ok {
+		statusInfo.WriteString(fmt.Sprintf("sleep: %s\n", remaining))
+	}
+	if config.Profile != "" {
+		statusInfo.WriteString(fmt.Sprintf("prof:  %s\n", config.Profile))
 	}
-	cmds := []string{
-		`{"command": ["get_property_string", "mute"]}`,
-		`{"command": ["get_property_string", "pause"]}`,
-		`{"command": ["get_property_string", "video"]}`,
-		`{"command": ["get_property_string", "idle-active"]}`,
-		`{"command": ["get_property_string", "seekable"]}`,
-		`{"command": ["get_property_string", "media-title"]}`,
-		`{"command": ["get_property_string", "path"]}`,
-		`{"command": ["get_property_string", "file-format"]}`,
-		`{"command": ["get_property_string", "duration"]}`,
-		`{"command": ["get_property_string", "time-pos"]}`,
-		`{"command": ["get_property_string", "time-remaining"]}`,
-		`{"command": ["get_property_string", "percent-pos"]}`,
-		`{"command": ["get_property_string", "ao-volume"]}`,
-		`{"command": ["get_property_string", "eof-reached"]}`,
-	}
-	arrSc, errSm := sendCmds(cmds, false)
-	if errSm != nil {
-		return "", errSm
-	}
-	statusInfo.WriteString(fmt.Sprintf("mute:  %s\n", arrSc[0][1].(map[string]interface{})["data"]))
-	statusInfo.WriteString(fmt.Sprintf("pause: %s\n", arrSc[1][1].(map[string]interface{})["data"]))
-	statusInfo.WriteString(fmt.Sprintf("video: %s\n", arrSc[2][1].(map[string]interface{})["data"]))
-	statusInfo.WriteString(fmt.Sprintf("idle:  %s\n", arrSc[3][1].(map[string]interface{})["data"]))
-	statusInfo.WriteString(fmt.Sprintf("seek:  %v\n", arrSc[4][1].(map[string]interface{})["data"]))
-	statusInfo.WriteString(fmt.Sprintf("title: %v\n", arrSc[5][1].(map[string]interface{})["data"]))
-	statusInfo.WriteString(fmt.Sprintf("file:  %v\n", arrSc[6][1].(map[string]interface{})["data"]))
-	statusInfo.WriteString(fmt.Sprintf("ffmt:  %v\n", arrSc[7][1].(map[string]interface{})["data"]))
-	if isSeekable() {
-		statusInfo.WriteString(fmt.Sprintf("time:  duration:  %v\n", arrSc[8][1].(map[string]interface{})["data"]))
-		statusInfo.WriteString(fmt.Sprintf("time:  position:  %v\n", arrSc[9][1].(map[string]interface{})["data"]))
-		statusInfo.WriteString(fmt.Sprintf("time:  remaining: %v\n", arrSc[10][1].(map[string]interface{})["data"]))
-		statusInfo.WriteString(fmt.Sprintf("time:  percent:   %s\n", arrSc[11][1].(map[string]interface{})["data"]))
-	}
-	statusInfo.WriteString(fmt.Sprintf("vol%%:  %v\n", arrSc[12][1].(map[string]interface{})["data"]))
-	statusInfo.WriteString(fmt.Sprintf("eof:   %v\n", arrSc[13][1].(map[string]interface{})["data"]))
+	statusInfo.WriteString(fmt.Sprintf("eof:   %s\n", values["eof-reached"]))
 	statusInfo.WriteString(fmt.Sprintf("meta:\n%s\n", outPretty))
 	return statusInfo.String(), nil
 }
@@ -614,12 +784,11 @@ func StreamPath() string {
 	if !IsRunning() {
 		return ""
 	}
-	cmd := `{"command": ["get_property_string", "path"]}`
-	_, content, errSc := SendCmd(cmd)
-	if errSc != nil {
+	path, errPr := Property("path")
+	if errPr != nil {
 		return ""
 	}
-	return fmt.Sprintf("%v", content["data"])
+	return path
 }
 
 // Stop stops the main program
@@ -627,22 +796,30 @@ func Stop() error {
 	if !IsRunning() {
 		return fmt.Errorf("stop: error: '%s' is not running\n", config.ProgName)
 	}
-	defer func() {
-		if errFi := finish(); errFi != nil {
-			utils.ErrPrint(errFi)
-			log.Fatal(errFi)
-		}
-	}()
 	log.Printf("stop: info: stopping '%s'\n", config.ProgName)
-	cmds := []string{
-		`{"command": ["playlist-remove", "current"]}`,
-		`{"command": ["stop"]}`,
-		`{"command": ["quit"]}`,
+	errQp := quitPlayer()
+	if errFi := finish(); errFi != nil {
+		return errFi
+	}
+	return errQp
+}
+
+// quitPlayer stops the playback and quits the media player
+func quitPlayer() error {
+	cli, errPc := playerClient()
+	if errPc != nil {
+		return errPc
 	}
-	if _, errSc := sendCmds(cmds, false); errSc != nil {
-		return errSc
+	ctx, cancel := playerContext()
+	defer cancel()
+	var errMpv *mpv.Error
+	if errPr := cli.PlaylistRemoveCurrent(ctx); errPr != nil && !errors.As(errPr, &errMpv) {
+		return errPr
 	}
-	return nil
+	if errCs := cli.Stop(ctx); errCs != nil {
+		return errCs
+	}
+	return cli.Quit(ctx)
 }
 
 // stopPlayer stops the media player
@@ -651,14 +828,17 @@ func stopPlayer() error {
 		return nil
 	}
 	content, errRf := os.ReadFile(config.PlayerPidFile)
-	if errRf != nil {
+	if errors.Is(errRf, os.ErrNotExist) {
+		return nil
+	} else if errRf != nil {
 		return errRf
 	}
 	pid, errSa := strconv.Atoi(strings.TrimRight(string(content), "\n"))
 	if errSa != nil {
 		return errSa
 	}
-	if errSk := syscall.Kill(pid, syscall.SIGINT); errSk != nil {
+	// the media player may have quit since its pid file was checked
+	if errSk := syscall.Kill(pid, syscall.SIGINT); errSk != nil && !errors.Is(errSk, syscall.ESRCH) {
 		return errSk
 	}
 	return nil
@@ -669,12 +849,7 @@ func Title() (string, error) {
 	if !IsRunning() {
 		return "", fmt.Errorf("title: error: '%s' is not running\n", config.ProgName)
 	}
-	cmd := fmt.Sprintf(`{"command": ["get_property_string", "%s"]}`, "media-title")
-	_, content, errSc := SendCmd(cmd)
-	if errSc != nil {
-		return "", errSc
-	}
-	return fmt.Sprintf("%v", content["data"]), nil
+	return Property("media-title")
 }
 
 // Toggle toggles property option
@@ -687,9 +862,14 @@ func Toggle(property string) error {
 			return errTv
 		}
 	} else {
-		cmd := fmt.Sprintf(`{"command": ["cycle", "%s"]}`, property)
-		if _, _, errSc := SendCmd(cmd); errSc != nil {
-			return errSc
+		cli, errPc := playerClient()
+		if errPc != nil {
+			return errPc
+		}
+		ctx, cancel := playerContext()
+		defer cancel()
+		if errCc := cli.Cycle(ctx, property); errCc != nil {
+			return errCc
 		}
 	}
 	return nil
@@ -697,19 +877,22 @@ func Toggle(property string) error {
 
 // toggleVideo toggles between video auto/off
 func toggleVideo() error {
-	cmdGv := `{"command": ["get_property_string", "video"]}`
-	_, content, errGv := SendCmd(cmdGv)
+	cli, errPc := playerClient()
+	if errPc != nil {
+		return errPc
+	}
+	ctx, cancel := playerContext()
+	defer cancel()
+	video, errGv := cli.GetPropertyString(ctx, "video")
 	if errGv != nil {
 		return errGv
 	}
-	if content["data"] == "auto" || content["data"] == "yes" || content["data"] == "1" {
-		cmdSf := `{"command": ["set_property", "video", false]}`
-		if _, _, errSf := SendCmd(cmdSf); errSf != nil {
+	if video == "auto" || video == "yes" || video == "1" {
+		if errSf := cli.SetProperty(ctx, "video", false); errSf != nil {
 			return errSf
 		}
 	} else {
-		cmdSa := `{"command": ["set_property", "video", "auto"]}`
-		if _, _, errSa := SendCmd(cmdSa); errSa != nil {
+		if errSa := cli.SetProperty(ctx, "video", "auto"); errSa != nil {
 			return errSa
 		}
 	}
@@ -741,18 +924,28 @@ func Volume(num int) error {
 
 // volumeAbsolute sets the absolute volume (0 means silence, 100 means no reduction)
 func volumeAbsolute(num int) error {
-	cmd := fmt.Sprintf(`{"command": ["set_property", "volume", "%d"]}`, num)
-	if _, _, errSc := SendCmd(cmd); errSc != nil {
-		return errSc
+	cli, errPc := playerClient()
+	if errPc != nil {
+		return errPc
+	}
+	ctx, cancel := playerContext()
+	defer cancel()
+	if errSp := cli.SetProperty(ctx, "volume", num); errSp != nil {
+		return errSp
 	}
 	return nil
 }
 
 // volumeSystem sets the system volume (OSS, ALSA, PulseAudio, etc)
 func volumeSystem(num int) error {
-	cmd := fmt.Sprintf(`{"command": ["set_property", "ao-volume", "%d"]}`, num)
-	if _, _, errSc := SendCmd(cmd); errSc != nil {
-		return errSc
+	cli, errPc := playerClient()
+	if errPc != nil {
+		return errPc
+	}
+	ctx, cancel := playerContext()
+	defer cancel()
+	if errSp := cli.SetProperty(ctx, "ao-volume", num); errSp != nil {
+		return errSp
 	}
 	return nil
 }
@@ -772,8 +965,16 @@ func wmBarUpdate() error {
 
 // wmFileUpdate updates the window manager media title file
 func wmFileUpdate(file string, data []byte, fi os.FileMode) error {
-	if errWf := os.WriteFile(file, data, fi); errWf != nil {
-		return errWf
+	fh, errOp := utils.OpenPrivate(file, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fi)
+	if errOp != nil {
+		return errOp
+	}
+	if _, errFw := fh.Write(data); errFw != nil {
+		fh.Close()
+		return errFw
+	}
+	if errFc := fh.Close(); errFc != nil {
+		return errFc
 	}
 	if errWb := wmBarUpdate(); errWb != nil {
 		return errWb
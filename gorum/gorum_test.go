@@ -0,0 +1,331 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+	"github.com/gonzaru/gorum/internal/configtest"
+	"github.com/gonzaru/gorum/mpvtest"
+)
+
+// setUpTest points the configuration to a temporary directory served by a fake mpv
+func setUpTest(t *testing.T) *mpvtest.Server {
+	t.Helper()
+	t.Setenv("DISPLAY", "")
+	dir := t.TempDir()
+	srv, errNs := mpvtest.NewTempServer(dir)
+	if errNs != nil {
+		t.Fatal(errNs)
+	}
+	restore := configtest.UseDirs(dir, filepath.Join(dir, "data"))
+	config.PlayerControlFile = srv.File
+	if errLo := lock(); errLo != nil {
+		t.Fatal(errLo)
+	}
+	t.Cleanup(func() {
+		unlock()
+		resetClient()
+		srv.Close()
+		restore()
+	})
+	return srv
+}
+
+// testStation returns the current station stream
+func testStation(id int) map[string]string {
+	stream, _ := config.Station(id)
+	return stream
+}
+
+// resetClient closes the persistent media player client
+func resetClient() {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+	if client != nil {
+		client.Close()
+		client = nil
+	}
+}
+
+func TestPlayStream(t *testing.T) {
+	srv := setUpTest(t)
+	if err := Play("1"); err != nil {
+		t.Fatal(err)
+	}
+	playlist := srv.Playlist()
+	if len(playlist) != 1 || playlist[0] != testStation(1)["url"] {
+		t.Fatalf("unexpected playlist %q", playlist)
+	}
+	if video := srv.Get("video"); video != false {
+		t.Fatalf("expected video false without DISPLAY, got %v", video)
+	}
+}
+
+func TestPlayLocalFile(t *testing.T) {
+	srv := setUpTest(t)
+	file := filepath.Join(t.TempDir(), `track "one" \ two.mp3`)
+	if errWf := os.WriteFile(file, []byte{}, 0600); errWf != nil {
+		t.Fatal(errWf)
+	}
+	if err := Play(file); err != nil {
+		t.Fatal(err)
+	}
+	if path := srv.Get("path"); path != file {
+		t.Fatalf("expected path %q, got %v", file, path)
+	}
+	if err := Play(filepath.Join(t.TempDir(), "missing.mp3")); err == nil {
+		t.Fatal("expected error for a missing file")
+	}
+}
+
+func TestPlayNotRunning(t *testing.T) {
+	setUpTest(t)
+	if errUl := unlock(); errUl != nil {
+		t.Fatal(errUl)
+	}
+	if err := Play("1"); err == nil {
+		t.Fatal("expected error when not running")
+	}
+}
+
+func TestSeek(t *testing.T) {
+	srv := setUpTest(t)
+	if err := Seek(10); err == nil {
+		t.Fatal("expected error when idle")
+	}
+	if err := Play("1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Seek(10); err == nil {
+		t.Fatal("expected error when not seekable")
+	}
+	srv.Set("seekable", true)
+	if err := Seek(10); err != nil {
+		t.Fatal(err)
+	}
+	if err := Seek(-4); err != nil {
+		t.Fatal(err)
+	}
+	if pos := srv.Get("time-pos"); pos != float64(6) {
+		t.Fatalf("expected time-pos 6, got %v", pos)
+	}
+}
+
+func TestToggle(t *testing.T) {
+	srv := setUpTest(t)
+	if err := Toggle("mute"); err != nil {
+		t.Fatal(err)
+	}
+	if mute := srv.Get("mute"); mute != true {
+		t.Fatalf("expected mute true, got %v", mute)
+	}
+	if err := Toggle("video"); err != nil {
+		t.Fatal(err)
+	}
+	if video := srv.Get("video"); video != false {
+		t.Fatalf("expected video false, got %v", video)
+	}
+	if err := Toggle("video"); err != nil {
+		t.Fatal(err)
+	}
+	if video := srv.Get("video"); video != "auto" {
+		t.Fatalf("expected video auto, got %v", video)
+	}
+	srv.Fail("cycle", "property unavailable")
+	if err := Toggle("pause"); err == nil {
+		t.Fatal("expected error from a failing cycle")
+	}
+}
+
+func TestVolume(t *testing.T) {
+	srv := setUpTest(t)
+	if err := Volume(config.VolumeMin - 1); err == nil {
+		t.Fatal("expected error below the minimum volume")
+	}
+	if err := Volume(config.VolumeMax + 1); err == nil {
+		t.Fatal("expected error above the maximum volume")
+	}
+	if err := Volume(42); err != nil {
+		t.Fatal(err)
+	}
+	if vol := srv.Get("ao-volume"); vol != float64(42) {
+		t.Fatalf("expected ao-volume 42, got %v", vol)
+	}
+	if vol := srv.Get("volume"); vol != float64(config.VolumeAbsolute) {
+		t.Fatalf("expected volume %d, got %v", config.VolumeAbsolute, vol)
+	}
+}
+
+func TestSendCmds(t *testing.T) {
+	srv := setUpTest(t)
+	cmds := []string{
+		`{"command": ["set_property", "mute", true]}`,
+		`{"command": ["set_property", "volume", 30]}`,
+	}
+	for _, async := range []bool{false, true} {
+		arrSc, errSc := sendCmds(cmds, async)
+		if errSc != nil {
+			t.Fatal(errSc)
+		}
+		if len(arrSc) != len(cmds) {
+			t.Fatalf("unexpected replies %v", arrSc)
+		}
+		for _, reply := range arrSc {
+			if content := reply[1].(map[string]interface{}); content["error"] != "success" {
+				t.Fatalf("unexpected reply %v", reply)
+			}
+		}
+	}
+	if mute, vol := srv.Get("mute"), srv.Get("volume"); mute != true || vol != float64(30) {
+		t.Fatalf("expected mute and volume 30, got %v %v", mute, vol)
+	}
+	srv.Fail("set_property", "property unavailable")
+	arrSc, errSc := sendCmds(cmds, true)
+	if errSc != nil {
+		t.Fatal(errSc)
+	}
+	if content := arrSc[0][1].(map[string]interface{}); content["error"] != "property unavailable" {
+		t.Fatalf("expected the command error in its reply, got %v", arrSc[0])
+	}
+}
+
+func TestStatus(t *testing.T) {
+	srv := setUpTest(t)
+	content, errSt := Status()
+	if errSt != nil {
+		t.Fatal(errSt)
+	}
+	if !strings.Contains(content, "idle:  yes\n") {
+		t.Fatalf("expected idle status, got:\n%s", content)
+	}
+	if err := Play("1"); err != nil {
+		t.Fatal(err)
+	}
+	srv.Set("metadata", map[string]interface{}{"icy-title": "Artist - Song"})
+	content, errSt = Status()
+	if errSt != nil {
+		t.Fatal(errSt)
+	}
+	for _, line := range []string{"idle:  no\n", "file:  " + testStation(1)["url"] + "\n", `"icy-title": "Artist - Song"`} {
+		if !strings.Contains(content, line) {
+			t.Fatalf("expected %q in status, got:\n%s", line, content)
+		}
+	}
+	srv.Fail("get_property_string", "error running command")
+	if _, err := Status(); err == nil {
+		t.Fatal("expected error from a failing player")
+	}
+}
+
+func TestPlayWait(t *testing.T) {
+	srv := setUpTest(t)
+	if err := PlayWait("1", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	srv.FailLoad(testStation(2)["url"], "loading failed")
+	errPw := PlayWait("2", time.Second)
+	if errPw == nil || !strings.Contains(errPw.Error(), "loading failed") {
+		t.Fatalf("expected loading failed error, got %v", errPw)
+	}
+	srv.Delay("loadfile", 500*time.Millisecond)
+	errPw = PlayWait("3", 100*time.Millisecond)
+	if errPw == nil || !strings.Contains(errPw.Error(), "did not start playing") {
+		t.Fatalf("expected timeout error, got %v", errPw)
+	}
+}
+
+func TestPlayDirectory(t *testing.T) {
+	srv := setUpTest(t)
+	dir := t.TempDir()
+	names := []string{"track10.mp3", "Track2.ogg", "track1.flac", "cover.jpg", ".hidden.mp3", "cd2/track1.mp3", "cd10/a.mp3", "cd2/extra/b.mp3", "cd2/z.mp3"}
+	for _, name := range names {
+		file := filepath.Join(dir, name)
+		if errMa := os.MkdirAll(filepath.Dir(file), 0700); errMa != nil {
+			t.Fatal(errMa)
+		}
+		if errWf := os.WriteFile(file, []byte{}, 0600); errWf != nil {
+			t.Fatal(errWf)
+		}
+	}
+	if err := PlayWait(dir, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"track1.flac", "Track2.ogg", "track10.mp3", "cd2/track1.mp3", "cd2/z.mp3", "cd2/extra/b.mp3", "cd10/a.mp3"}
+	playlist := srv.Playlist()
+	if len(playlist) != len(want) {
+		t.Fatalf("unexpected playlist %q", playlist)
+	}
+	for num, name := range want {
+		if playlist[num] != filepath.Join(dir, name) {
+			t.Fatalf("expected %q at %d, got %q", name, num, playlist)
+		}
+	}
+	content, errSt := Status()
+	if errSt != nil {
+		t.Fatal(errSt)
+	}
+	if !strings.Contains(content, "list:  1/7\n") {
+		t.Fatalf("expected playlist position in status, got:\n%s", content)
+	}
+	if err := Play(t.TempDir()); err == nil {
+		t.Fatal("expected error for a directory without media files")
+	}
+}
+
+func TestPlayPlaylistFile(t *testing.T) {
+	srv := setUpTest(t)
+	dir := t.TempDir()
+	if errWf := os.WriteFile(filepath.Join(dir, "a.mp3"), []byte{}, 0600); errWf != nil {
+		t.Fatal(errWf)
+	}
+	file := filepath.Join(dir, "list.m3u")
+	data := "#EXTM3U\n#EXTINF:-1,A\na.mp3\nmissing.mp3\nhttps://example.org/stream\n"
+	if errWf := os.WriteFile(file, []byte(data), 0600); errWf != nil {
+		t.Fatal(errWf)
+	}
+	if err := PlayWait(file, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	playlist := srv.Playlist()
+	if len(playlist) != 2 || playlist[0] != filepath.Join(dir, "a.mp3") || playlist[1] != "https://example.org/stream" {
+		t.Fatalf("unexpected playlist %q", playlist)
+	}
+}
+
+func TestWmFileUpdate(t *testing.T) {
+	setUpTest(t)
+	if err := wmFileUpdate(config.WmFile, []byte("Artist - Song\n"), config.WmFilePerms); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := os.ReadFile(config.WmFile); err != nil || string(content) != "Artist - Song\n" {
+		t.Fatalf("unexpected wm file %q %v", content, err)
+	}
+
+	// a symlink planted in place of the wm file is not followed
+	target := filepath.Join(t.TempDir(), "target")
+	if err := os.WriteFile(target, []byte("keep\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(config.WmFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, config.WmFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := wmFileUpdate(config.WmFile, []byte("Other - Song\n"), config.WmFilePerms); err == nil {
+		t.Fatal("expected error writing through a symlink")
+	}
+	if content, err := os.ReadFile(target); err != nil || string(content) != "keep\n" {
+		t.Fatalf("expected the symlink target unchanged, got %q %v", content, err)
+	}
+}
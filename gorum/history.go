@@ -0,0 +1,180 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+	"github.com/gonzaru/gorum/utils"
+)
+
+// historyEntry data type
+type historyEntry struct {
+	Num      int       `json:"num,omitempty"`
+	Path     string    `json:"path"`
+	Station  int       `json:"station,omitempty"`
+	Name     string    `json:"name,omitempty"`
+	Title    string    `json:"title,omitempty"`
+	Start    time.Time `json:"start"`
+	Stop     time.Time `json:"stop"`
+	Duration float64   `json:"duration"`
+}
+
+// History runs the history command: list (default) or replay n
+func History(args []string) (string, error) {
+	if len(args) > 0 && args[0] == "replay" {
+		return "", historyReplay(args[1:])
+	}
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	since := fs.String("since", "", "entries started since a duration ago (30m, 24h, 7d) or a date (2006-01-02)")
+	station := fs.Int("station", 0, "entries of the station id")
+	asJson := fs.Bool("json", false, "prints the entries as json")
+	if errPf := parseFlags(fs, args); errPf != nil {
+		return "", errPf
+	}
+	if fs.NArg() > 0 {
+		return "", fmt.Errorf("history: error: unexpected argument '%s'\n", fs.Arg(0))
+	}
+	var sinceTime time.Time
+	if *since != "" {
+		var errPs error
+		if sinceTime, errPs = parseSince(*since, time.Now()); errPs != nil {
+			return "", errPs
+		}
+	}
+	entries, errLh := loadHistory()
+	if errLh != nil {
+		return "", errLh
+	}
+	selected := make([]historyEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Start.Before(sinceTime) || (*station != 0 && entry.Station != *station) {
+			continue
+		}
+		selected = append(selected, entry)
+	}
+	if *asJson {
+		data, errJm := json.MarshalIndent(selected, "", "    ")
+		if errJm != nil {
+			return "", errJm
+		}
+		return string(data) + "\n", nil
+	}
+	return historyList(selected)
+}
+
+// historyList returns the history entries as aligned text lines
+func historyList(entries []historyEntry) (string, error) {
+	var list bytes.Buffer
+	if len(entries) == 0 {
+		return "", nil
+	}
+	tw := tabwriter.NewWriter(&list, 0, 0, 2, ' ', 0)
+	numPad := strconv.Itoa(utils.CountDigit(entries[len(entries)-1].Num))
+	for _, entry := range entries {
+		name := entry.Name
+		if name == "" {
+			name = entry.Title
+		}
+		if name == "" {
+			name = filepath.Base(entry.Path)
+		}
+		duration := time.Duration(entry.Duration * float64(time.Second)).Round(time.Second)
+		line := fmt.Sprintf("%"+numPad+"d)\t%s\t%s\t%s\t%s\n", entry.Num, entry.Start.Format("2006-01-02 15:04"), duration, name, entry.Path)
+		if _, errTw := tw.Write([]byte(line)); errTw != nil {
+			return "", errTw
+		}
+	}
+	if errTf := tw.Flush(); errTf != nil {
+		return "", errTf
+	}
+	return list.String(), nil
+}
+
+// historyReplay plays the history entry number n again
+func historyReplay(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("history: error: usage: %s history replay n\n", config.ProgName)
+	}
+	num, errSa := strconv.Atoi(args[0])
+	if errSa != nil {
+		return fmt.Errorf("history: error: '%s' is not a history entry number\n", args[0])
+	}
+	entries, errLh := loadHistory()
+	if errLh != nil {
+		return errLh
+	}
+	if num < 1 || num > len(entries) {
+		return fmt.Errorf("history: error: entry '%d' not found, the history has %d entries\n", num, len(entries))
+	}
+	entry := entries[num-1]
+	if utils.Contains(config.StationUrls(entry.Station), entry.Path) {
+		return PlayWait(strconv.Itoa(entry.Station), config.PlayTimeout)
+	}
+	return PlayWait(entry.Path, config.PlayTimeout)
+}
+
+// loadHistory loads the history entries, the most recent first and numbered from 1
+func loadHistory() ([]historyEntry, error) {
+	var entries []historyEntry
+	errLs := config.LoadStateLines(config.HistoryFile, func(data []byte) error {
+		var entry historyEntry
+		if errJu := json.Unmarshal(data, &entry); errJu != nil {
+			return errJu
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if errLs != nil {
+		return nil, errLs
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	for num := range entries {
+		entries[num].Num = num + 1
+	}
+	return entries, nil
+}
+
+// parseSince returns the time of a duration before now (30m, 24h, 7d) or of a date (2006-01-02)
+func parseSince(since string, now time.Time) (time.Time, error) {
+	if strings.HasSuffix(since, "d") {
+		if num, errSa := strconv.Atoi(strings.TrimSuffix(since, "d")); errSa == nil && num >= 0 {
+			return now.AddDate(0, 0, -num), nil
+		}
+	}
+	if duration, errPd := time.ParseDuration(since); errPd == nil && duration >= 0 {
+		return now.Add(-duration), nil
+	}
+	for _, layout := range []string{"2006-01-02", "2006-01-02 15:04", time.RFC3339} {
+		if date, errTp := time.ParseInLocation(layout, since, time.Local); errTp == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("history: error: '%s' is not a duration or a date\n", since)
+}
+
+// stationOf returns the station id and name of the stream url or mirror url
+func stationOf(path string) (int, string) {
+	streams, _ := config.Stations()
+	for id, stream := range streams {
+		if utils.Contains(config.StationUrls(id), path) {
+			return id, stream["name"]
+		}
+	}
+	return 0, ""
+}
@@ -0,0 +1,90 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+	"github.com/gonzaru/gorum/mpv"
+)
+
+func TestHistory(t *testing.T) {
+	srv := setUpTest(t)
+	cli, errMd := mpv.Dial(config.PlayerControlFile)
+	if errMd != nil {
+		t.Fatal(errMd)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- watchEvents(cli)
+	}()
+	waitFor(t, "observed properties", func() bool {
+		return len(srv.Commands()) >= len(observedProperties)
+	})
+	if err := PlayWait("1", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	srv.Set("metadata", map[string]interface{}{"icy-title": "Artist - Song"})
+	if err := PlayWait("https://example.org/other", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "first history entry", func() bool {
+		entries, _ := loadHistory()
+		return len(entries) == 1
+	})
+	cli.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	entries, errLh := loadHistory()
+	if errLh != nil {
+		t.Fatal(errLh)
+	}
+	if len(entries) != 2 || entries[0].Path != "https://example.org/other" || entries[1].Station != 1 {
+		t.Fatalf("unexpected history %+v", entries)
+	}
+	if entries[1].Title != "Artist - Song" || entries[1].Name != testStation(1)["name"] || entries[1].Stop.Before(entries[1].Start) {
+		t.Fatalf("unexpected history entry %+v", entries[1])
+	}
+	content, errHi := History([]string{"--station", "1", "--json"})
+	if errHi != nil {
+		t.Fatal(errHi)
+	}
+	var selected []historyEntry
+	if errJu := json.Unmarshal([]byte(content), &selected); errJu != nil {
+		t.Fatal(errJu)
+	}
+	if len(selected) != 1 || selected[0].Num != 2 {
+		t.Fatalf("unexpected station history %+v", selected)
+	}
+	content, errHi = History([]string{"--since", "1h"})
+	if errHi != nil {
+		t.Fatal(errHi)
+	}
+	if !strings.HasPrefix(content, "1)") || !strings.Contains(content, testStation(1)["name"]) {
+		t.Fatalf("unexpected history list:\n%s", content)
+	}
+	if content, _ := History([]string{"--since", "2999-01-01"}); content != "" {
+		t.Fatalf("expected no entries in the future, got:\n%s", content)
+	}
+	if _, err := History([]string{"--since", "soon"}); err == nil {
+		t.Fatal("expected error for an invalid since")
+	}
+	if _, err := History([]string{"replay", "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if path := srv.Get("path"); path != testStation(1)["url"] {
+		t.Fatalf("expected replayed station, got %v", path)
+	}
+	if _, err := History([]string{"replay", "3"}); err == nil {
+		t.Fatal("expected error replaying a missing entry")
+	}
+}
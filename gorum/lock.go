@@ -0,0 +1,110 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+	"github.com/gonzaru/gorum/mpv"
+)
+
+const (
+	// lockTries the number of attempts to take the lock, another command may be checking it
+	lockTries = 5
+
+	// lockRetryDelay the time between two attempts to take the lock
+	lockRetryDelay = 20 * time.Millisecond
+)
+
+var (
+	// lockFile the open lock file while the main program is running
+	lockFile *os.File
+
+	// lockMu guards lockFile, the program may finish from the signal handler and from the control socket
+	lockMu sync.Mutex
+)
+
+// lock takes the exclusive lock of the main program, it is held until unlock or the process ends
+func lock() error {
+	if errMr := config.MakeRuntimeDir(); errMr != nil {
+		return errMr
+	}
+	fh, errOf := os.OpenFile(config.LockFile, os.O_CREATE|os.O_RDWR, 0600)
+	if errOf != nil {
+		return errOf
+	}
+	for try := 1; ; try++ {
+		errFl := syscall.Flock(int(fh.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if errFl == nil {
+			break
+		}
+		if !errors.Is(errFl, syscall.EWOULDBLOCK) || try == lockTries {
+			fh.Close()
+			if errors.Is(errFl, syscall.EWOULDBLOCK) {
+				return fmt.Errorf("lock: error: '%s' is already running or locked\n", config.ProgName)
+			}
+			return errFl
+		}
+		time.Sleep(lockRetryDelay)
+	}
+	lockMu.Lock()
+	lockFile = fh
+	lockMu.Unlock()
+	return nil
+}
+
+// locked checks if a process holds the lock of the main program
+func locked() bool {
+	return isLocked(config.LockFile)
+}
+
+// isLocked checks if a process holds the lock file
+func isLocked(file string) bool {
+	fh, errOf := os.Open(file)
+	if errOf != nil {
+		return false
+	}
+	defer fh.Close()
+	errFl := syscall.Flock(int(fh.Fd()), syscall.LOCK_SH|syscall.LOCK_NB)
+	return errors.Is(errFl, syscall.EWOULDBLOCK)
+}
+
+// unlock releases the lock of the main program, the lock file is kept to not race with other processes opening it
+func unlock() error {
+	lockMu.Lock()
+	defer lockMu.Unlock()
+	if lockFile == nil {
+		return nil
+	}
+	fh := lockFile
+	lockFile = nil
+	return fh.Close()
+}
+
+// cleanStale removes the runtime files left by a previous run killed before cleaning up, quitting its player
+func cleanStale() error {
+	if _, errOs := os.Stat(config.PidFile); errOs != nil {
+		return nil
+	}
+	log.Print("setUp: warning: removing the stale files of a previous run\n")
+	if cli, errMd := mpv.Dial(config.PlayerControlFile); errMd == nil {
+		ctx, cancel := playerContext()
+		if errCq := cli.Quit(ctx); errCq != nil {
+			log.Print(errCq)
+		}
+		cancel()
+		cli.Close()
+	}
+	return cleanUp()
+}
@@ -0,0 +1,109 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+)
+
+func TestLock(t *testing.T) {
+	setUpTest(t)
+	if !IsRunning() {
+		t.Fatal("expected running while locked")
+	}
+	if err := lock(); err == nil || !strings.Contains(err.Error(), "already running") {
+		t.Fatalf("expected already running error, got %v", err)
+	}
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if IsRunning() {
+		t.Fatal("expected not running after unlock")
+	}
+	if err := lock(); err != nil {
+		t.Fatal(err)
+	}
+	if !IsRunning() {
+		t.Fatal("expected running after locking again")
+	}
+}
+
+func TestCleanStale(t *testing.T) {
+	srv := setUpTest(t)
+	if err := cleanStale(); err != nil {
+		t.Fatal(err)
+	}
+	if len(srv.Commands()) != 0 {
+		t.Fatalf("expected no commands without stale files, got %v", srv.Commands())
+	}
+
+	// a killed run leaves its pid file and its player running
+	for _, file := range []string{config.PidFile, config.PlayerPidFile} {
+		if err := os.WriteFile(file, []byte("999999\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cleanStale(); err != nil {
+		t.Fatal(err)
+	}
+	commands := srv.Commands()
+	if len(commands) != 1 || commands[0][0] != "quit" {
+		t.Fatalf("expected the stale player quit, got %v", commands)
+	}
+	for _, file := range []string{config.PidFile, config.PlayerPidFile, config.PlayerControlFile} {
+		if _, err := os.Stat(file); !os.IsNotExist(err) {
+			t.Errorf("expected stale file '%s' removed", file)
+		}
+	}
+	if _, err := os.Stat(config.LockFile); err != nil {
+		t.Fatalf("expected the lock file kept, got %v", err)
+	}
+}
+
+func TestFinishTwice(t *testing.T) {
+	setUpTest(t)
+	player := exec.Command("sleep", "10")
+	if err := player.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer player.Process.Kill()
+	if err := os.WriteFile(config.PlayerPidFile, []byte(strconv.Itoa(player.Process.Pid)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(config.PidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// the stop command and the main program finish at the same time
+	errs := make(chan error, 2)
+	for num := 0; num < 2; num++ {
+		go func() {
+			errs <- finish()
+		}()
+	}
+	for num := 0; num < 2; num++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := player.Wait(); err == nil {
+		t.Fatal("expected the player interrupted")
+	}
+	for _, file := range []string{config.PidFile, config.PlayerPidFile, config.PlayerControlFile} {
+		if _, err := os.Stat(file); !os.IsNotExist(err) {
+			t.Errorf("expected file '%s' removed", file)
+		}
+	}
+	if IsRunning() {
+		t.Fatal("expected not running after finishing")
+	}
+}
@@ -0,0 +1,193 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+	"github.com/gonzaru/gorum/mpv"
+)
+
+// modes data type
+type modes struct {
+	Repeat  string `json:"repeat"`
+	Shuffle bool   `json:"shuffle"`
+}
+
+// RepeatModes the repeat modes in cycle order
+var RepeatModes = []string{"off", "one", "all"}
+
+// applyModes sets the saved repeat mode in the media player, the shuffle mode only shuffles the loaded queues
+func applyModes(cli *mpv.Client) error {
+	md, errLm := loadModes()
+	if errLm != nil {
+		return errLm
+	}
+	ctx, cancel := playerContext()
+	defer cancel()
+	return setRepeat(ctx, cli, md.Repeat)
+}
+
+// loadModes loads the saved shuffle and repeat modes
+func loadModes() (modes, error) {
+	md := modes{Repeat: "off"}
+	if errLs := config.LoadState(config.ModesFile, &md); errLs != nil {
+		return md, errLs
+	}
+	if repeatIndex(md.Repeat) < 0 {
+		md.Repeat = "off"
+	}
+	return md, nil
+}
+
+// Modes returns the saved shuffle mode and the media player repeat mode
+func Modes() (bool, string, error) {
+	md, errLm := loadModes()
+	if errLm != nil {
+		return false, "", errLm
+	}
+	cli, errPc := playerClient()
+	if errPc != nil {
+		return false, "", errPc
+	}
+	ctx, cancel := playerContext()
+	defer cancel()
+	loopFile, errGf := cli.GetPropertyString(ctx, "loop-file")
+	if errGf != nil {
+		return false, "", errGf
+	}
+	loopPlaylist, errGp := cli.GetPropertyString(ctx, "loop-playlist")
+	if errGp != nil {
+		return false, "", errGp
+	}
+	return md.Shuffle, repeatMode(loopFile, loopPlaylist), nil
+}
+
+// Repeat sets the repeat mode off, one or all, cycling to the next mode when it is empty
+func Repeat(mode string) error {
+	if !IsRunning() {
+		return fmt.Errorf("repeat: error: '%s' is not running\n", config.ProgName)
+	}
+	if mode != "" && repeatIndex(mode) < 0 {
+		return fmt.Errorf("repeat: error: unknown mode '%s', use %s\n", mode, strings.Join(RepeatModes, "|"))
+	}
+	cli, errPc := playerClient()
+	if errPc != nil {
+		return errPc
+	}
+	ctx, cancel := playerContext()
+	defer cancel()
+	md := modes{Repeat: "off"}
+	return config.UpdateState(config.ModesFile, &md, func() error {
+		if repeatIndex(md.Repeat) < 0 {
+			md.Repeat = "off"
+		}
+		if mode == "" {
+			mode = RepeatModes[(repeatIndex(md.Repeat)+1)%len(RepeatModes)]
+		}
+		if errSr := setRepeat(ctx, cli, mode); errSr != nil {
+			return errSr
+		}
+		md.Repeat = mode
+		return nil
+	})
+}
+
+// repeatIndex returns the repeat mode index or -1 when it is unknown
+func repeatIndex(mode string) int {
+	for num, item := range RepeatModes {
+		if item == mode {
+			return num
+		}
+	}
+	return -1
+}
+
+// repeatMode returns the repeat mode from the media player loop properties
+func repeatMode(loopFile string, loopPlaylist string) string {
+	switch {
+	case loopFile != "" && loopFile != "no":
+		return "one"
+	case loopPlaylist != "" && loopPlaylist != "no":
+		return "all"
+	default:
+		return "off"
+	}
+}
+
+// setRepeat sets the media player loop properties for the repeat mode
+func setRepeat(ctx context.Context, cli *mpv.Client, mode string) error {
+	loopFile, loopPlaylist := "no", "no"
+	switch mode {
+	case "one":
+		loopFile = "inf"
+	case "all":
+		loopPlaylist = "inf"
+	}
+	if errSp := cli.SetProperty(ctx, "loop-file", loopFile); errSp != nil {
+		return errSp
+	}
+	return cli.SetProperty(ctx, "loop-playlist", loopPlaylist)
+}
+
+// Shuffle toggles the queue shuffle mode, shuffling the queue or restoring its order
+func Shuffle() error {
+	if !IsRunning() {
+		return fmt.Errorf("shuffle: error: '%s' is not running\n", config.ProgName)
+	}
+	cli, errPc := playerClient()
+	if errPc != nil {
+		return errPc
+	}
+	ctx, cancel := playerContext()
+	defer cancel()
+	md := modes{Repeat: "off"}
+	return config.UpdateState(config.ModesFile, &md, func() error {
+		md.Shuffle = !md.Shuffle
+		cmd := "playlist-unshuffle"
+		if md.Shuffle {
+			cmd = "playlist-shuffle"
+		}
+		_, errCc := cli.Command(ctx, cmd)
+		return errCc
+	})
+}
+
+// shuffleQueue appends the files to the cleared queue and shuffles them all before playing, returns the first entry,
+// every command waits for its own reply so a large queue does not run out of time
+func shuffleQueue(cli *mpv.Client, files []string) (string, error) {
+	ctxClear, cancelClear := playerContext()
+	errPc := cli.PlaylistClear(ctxClear)
+	cancelClear()
+	if errPc != nil {
+		return "", errPc
+	}
+	for _, file := range files {
+		ctxAppend, cancelAppend := playerContext()
+		errLf := cli.LoadFile(ctxAppend, file, "append")
+		cancelAppend()
+		if errLf != nil {
+			return "", errLf
+		}
+	}
+	ctx, cancel := playerContext()
+	defer cancel()
+	if _, errCc := cli.Command(ctx, "playlist-shuffle"); errCc != nil {
+		return "", errCc
+	}
+	entries, errPl := cli.Playlist(ctx)
+	if errPl != nil {
+		return "", errPl
+	}
+	if len(entries) == 0 {
+		return "", fmt.Errorf("shuffle: error: the queue is empty\n")
+	}
+	return entries[0].Filename, nil
+}
@@ -0,0 +1,171 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+	"github.com/gonzaru/gorum/mpvtest"
+)
+
+func TestRepeat(t *testing.T) {
+	srv := setUpTest(t)
+	if err := Repeat("all"); err != nil {
+		t.Fatal(err)
+	}
+	if loop := srv.Get("loop-playlist"); loop != "inf" {
+		t.Fatalf("expected loop-playlist inf, got %v", loop)
+	}
+	content, errSt := Status()
+	if errSt != nil {
+		t.Fatal(errSt)
+	}
+	if !strings.Contains(content, "rept:  all\n") {
+		t.Fatalf("expected repeat all in status, got:\n%s", content)
+	}
+	if err := Repeat(""); err != nil {
+		t.Fatal(err)
+	}
+	if _, repeat, err := Modes(); err != nil || repeat != "off" {
+		t.Fatalf("expected repeat to cycle to off, got %q %v", repeat, err)
+	}
+	if err := Repeat(""); err != nil {
+		t.Fatal(err)
+	}
+	if loop := srv.Get("loop-file"); loop != "inf" {
+		t.Fatalf("expected loop-file inf, got %v", loop)
+	}
+	if err := Repeat("twice"); err == nil {
+		t.Fatal("expected error for an unknown mode")
+	}
+}
+
+func TestModesConcurrent(t *testing.T) {
+	setUpTest(t)
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for num := 0; num < 5; num++ {
+		wg.Add(1)
+		go func(num int) {
+			defer wg.Done()
+			if num%2 == 0 {
+				errs <- Shuffle()
+			} else {
+				errs <- Repeat("")
+			}
+		}(num)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// every change is saved, none of them is lost by a concurrent one
+	md, errLm := loadModes()
+	if errLm != nil || !md.Shuffle || md.Repeat != "all" {
+		t.Fatalf("expected the saved modes shuffle true and repeat all, got %+v %v", md, errLm)
+	}
+}
+
+func TestShufflePersists(t *testing.T) {
+	srv := setUpTest(t)
+	for _, file := range []string{"1", "2", "3"} {
+		if err := Enqueue(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Shuffle(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Repeat("one"); err != nil {
+		t.Fatal(err)
+	}
+	if !hasCommand(srv, "playlist-shuffle") {
+		t.Fatalf("expected the queue shuffled, got %v", srv.Commands())
+	}
+	if len(srv.Playlist()) != 3 {
+		t.Fatalf("unexpected playlist %q", srv.Playlist())
+	}
+	restarted, errNs := mpvtest.NewTempServer(t.TempDir())
+	if errNs != nil {
+		t.Fatal(errNs)
+	}
+	defer restarted.Close()
+	config.PlayerControlFile = restarted.File
+	resetClient()
+	cli, errPc := playerClient()
+	if errPc != nil {
+		t.Fatal(errPc)
+	}
+	if err := applyModes(cli); err != nil {
+		t.Fatal(err)
+	}
+	shuffle, repeat, errMo := Modes()
+	if errMo != nil || !shuffle || repeat != "one" {
+		t.Fatalf("expected saved modes after restart, got shuffle %v repeat %q %v", shuffle, repeat, errMo)
+	}
+	if shuffle := restarted.Get("shuffle"); shuffle != false {
+		t.Fatalf("expected the player shuffle option unused, got %v", shuffle)
+	}
+
+	// a queue loaded while shuffling is shuffled as a whole before playing, its first entry can change
+	if err := PlayWait("1", 0); err != nil {
+		t.Fatal(err)
+	}
+	if hasCommand(restarted, "playlist-shuffle") {
+		t.Fatal("expected a single entry not shuffled")
+	}
+	dir := t.TempDir()
+	var names []string
+	for _, name := range []string{"a.mp3", "b.mp3", "c.mp3", "d.mp3", "e.mp3", "f.mp3"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte{}, 0600); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, filepath.Join(dir, name))
+	}
+	moved := false
+	for try := 0; try < 20 && !moved; try++ {
+		if err := PlayWait(dir, time.Second); err != nil {
+			t.Fatal(err)
+		}
+		playlist := restarted.Playlist()
+		if len(playlist) != len(names) || restarted.Get("path") != playlist[0] {
+			t.Fatalf("expected the shuffled queue playing its first entry, got %q playing %v", playlist, restarted.Get("path"))
+		}
+		moved = playlist[0] != names[0]
+	}
+	if !moved {
+		t.Fatalf("expected the shuffled queue starting with another entry, got %v", restarted.Commands())
+	}
+	if err := Shuffle(); err != nil {
+		t.Fatal(err)
+	}
+	if playlist := restarted.Playlist(); !hasCommand(restarted, "playlist-unshuffle") || strings.Join(playlist, " ") != strings.Join(names, " ") {
+		t.Fatalf("expected the queue order restored, got %q", playlist)
+	}
+	if shuffle, _, err := Modes(); err != nil || shuffle {
+		t.Fatalf("expected shuffle off, got %v %v", shuffle, err)
+	}
+}
+
+// hasCommand checks if the fake media player received the command
+func hasCommand(srv *mpvtest.Server, name string) bool {
+	for _, cmd := range srv.Commands() {
+		if len(cmd) > 0 && cmd[0] == name {
+			return true
+		}
+	}
+	return false
+}
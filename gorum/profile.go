@@ -0,0 +1,65 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+	"github.com/gonzaru/gorum/control"
+	"github.com/gonzaru/gorum/mpv"
+)
+
+// Profiles returns the running profiles with their pid and their media title
+func Profiles() (string, error) {
+	instances, errIn := config.Instances()
+	if errIn != nil {
+		return "", errIn
+	}
+	var buf strings.Builder
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	for _, inst := range instances {
+		if !isLocked(inst.LockFile) {
+			continue
+		}
+		pid := "-"
+		if content, errRf := os.ReadFile(inst.PidFile); errRf == nil {
+			pid = strings.TrimSpace(string(content))
+		}
+		current := ""
+		if inst.Profile == config.Profile || (config.Profile == "" && inst.Profile == config.DefaultProfile) {
+			current = "*"
+		}
+		if _, errFp := fmt.Fprintf(tw, "%s%s\t%s\t%s\n", inst.Profile, current, pid, instanceTitle(inst)); errFp != nil {
+			return "", errFp
+		}
+	}
+	if errTf := tw.Flush(); errTf != nil {
+		return "", errTf
+	}
+	return buf.String(), nil
+}
+
+// instanceTitle returns the media title playing in the profile instance, idle when nothing is playing
+func instanceTitle(inst config.Instance) string {
+	cli, errCd := control.Dial(inst.ControlFile, mpv.DefaultTimeout)
+	if errCd != nil {
+		return "-"
+	}
+	defer cli.Close()
+	var state controlState
+	if errCc := cli.Call(&state, "state"); errCc != nil {
+		return "-"
+	}
+	if state.Idle {
+		return "idle"
+	}
+	return state.Title
+}
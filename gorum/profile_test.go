@@ -0,0 +1,72 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+	"github.com/gonzaru/gorum/mpvtest"
+)
+
+func TestProfiles(t *testing.T) {
+	setUpTest(t)
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	instances, errIn := config.Instances()
+	if errIn != nil {
+		t.Fatal(errIn)
+	}
+	if content, err := Profiles(); err != nil || content != "" {
+		t.Fatalf("expected no running profiles, got %q %v", content, err)
+	}
+
+	// the kitchen profile is running and playing, the office one was stopped
+	kitchen := filepath.Join(filepath.Dir(instances[0].LockFile), "profiles", "kitchen")
+	office := filepath.Join(filepath.Dir(instances[0].LockFile), "profiles", "office")
+	for _, dir := range []string{kitchen, office} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv, errNs := mpvtest.NewServer(filepath.Join(kitchen, "player-control.socket"))
+	if errNs != nil {
+		t.Fatal(errNs)
+	}
+	defer srv.Close()
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+	config.Profile = "kitchen"
+	config.RuntimeDir, config.LockFile = kitchen, filepath.Join(kitchen, "gorum.lock")
+	config.PidFile, config.PlayerControlFile = filepath.Join(kitchen, "gorum.pid"), srv.File
+	config.ControlFile = filepath.Join(kitchen, "control.socket")
+	if err := lock(); err != nil {
+		t.Fatal(err)
+	}
+	ctl, errSc := startControl()
+	if errSc != nil {
+		t.Fatal(errSc)
+	}
+	defer ctl.close()
+	if err := os.WriteFile(config.PidFile, []byte("4242\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := PlayWait("https://example.org/kitchen", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	srv.Set("media-title", "Kitchen Radio")
+	content, errPr := Profiles()
+	if errPr != nil {
+		t.Fatal(errPr)
+	}
+	if content != "kitchen*  4242  Kitchen Radio\n" {
+		t.Fatalf("unexpected profiles:\n%s", content)
+	}
+}
@@ -0,0 +1,275 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+	"github.com/gonzaru/gorum/mpv"
+	"github.com/gonzaru/gorum/playlist"
+	"github.com/gonzaru/gorum/utils"
+)
+
+// Enqueue appends the station id, url, local file, directory or playlist file to the queue
+func Enqueue(file string) error {
+	var files []string
+	if stream, errSa := strconv.Atoi(file); errSa == nil {
+		station, _ := config.Station(stream)
+		streamUrl, ok := station["url"]
+		if !ok {
+			return fmt.Errorf("enqueue: error: key map '%d' not found in streams\n", stream)
+		}
+		files = []string{streamUrl}
+	} else {
+		var errMf error
+		if files, errMf = mediaFiles(file); errMf != nil {
+			return errMf
+		}
+	}
+	cli, errPc := playerClient()
+	if errPc != nil {
+		return errPc
+	}
+	for _, fileLoad := range files {
+		ctx, cancel := playerContext()
+		errLf := cli.LoadFile(ctx, fileLoad, "append-play")
+		cancel()
+		if errLf != nil {
+			return errLf
+		}
+	}
+	return nil
+}
+
+// isMedia checks if the file has a media extension
+func isMedia(file string) bool {
+	ext := strings.ToLower(filepath.Ext(file))
+	for _, item := range config.MediaExtensions {
+		if ext == item {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaDir returns the media files inside the directory tree sorted naturally, the files of a directory before its subdirectories
+func mediaDir(dir string) ([]string, error) {
+	var files []string
+	errWd := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.IsDir() && isMedia(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if errWd != nil {
+		return nil, errWd
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return mediaLess(files[i], files[j])
+	})
+	if len(files) == 0 {
+		return nil, fmt.Errorf("mediaDir: error: '%s' has no media files\n", dir)
+	}
+	return files, nil
+}
+
+// mediaLess compares the file paths naturally, a file sorts before the subdirectories of its directory
+func mediaLess(a string, b string) bool {
+	aParts := strings.Split(filepath.ToSlash(a), "/")
+	bParts := strings.Split(filepath.ToSlash(b), "/")
+	for num := 0; num < len(aParts) && num < len(bParts); num++ {
+		if aParts[num] == bParts[num] {
+			continue
+		}
+		aFile, bFile := num == len(aParts)-1, num == len(bParts)-1
+		if aFile != bFile {
+			return aFile
+		}
+		return utils.NaturalLess(aParts[num], bParts[num])
+	}
+	return len(aParts) < len(bParts)
+}
+
+// mediaFiles returns the url, local file, directory media files or playlist entries to play
+func mediaFiles(file string) ([]string, error) {
+	fi, errOs := os.Stat(file)
+	if errOs != nil {
+		if utils.ValidUrl(file) {
+			return []string{file}, nil
+		}
+		return nil, fmt.Errorf("mediaFiles: error: '%s' no such file or stream url\n", file)
+	}
+	if fi.IsDir() {
+		return mediaDir(file)
+	}
+	if playlist.IsPlaylist(file) {
+		return mediaPlaylist(file)
+	}
+	fileAbs, errFa := filepath.Abs(file)
+	if errFa != nil {
+		return nil, errFa
+	}
+	return []string{fileAbs}, nil
+}
+
+// mediaPlaylist returns the playlist file urls and existing local files in order
+func mediaPlaylist(file string) ([]string, error) {
+	entries, errPf := playlist.ParseFile(file)
+	if errPf != nil {
+		return nil, errPf
+	}
+	var files []string
+	for _, entry := range entries {
+		if !filepath.IsAbs(entry.Location) {
+			files = append(files, entry.Location)
+			continue
+		}
+		if fi, errOs := os.Stat(entry.Location); errOs == nil && !fi.IsDir() {
+			files = append(files, entry.Location)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("mediaPlaylist: error: '%s' has no playable entries\n", file)
+	}
+	return files, nil
+}
+
+// Jump plays the queue entry number n
+func Jump(num int) error {
+	cli, errPc := playerClient()
+	if errPc != nil {
+		return errPc
+	}
+	ctx, cancel := playerContext()
+	defer cancel()
+	if errQi := queueIndex(cli, num); errQi != nil {
+		return errQi
+	}
+	return cli.PlaylistPlayIndex(ctx, num-1)
+}
+
+// Next plays the next queue entry
+func Next() error {
+	cli, errPc := playerClient()
+	if errPc != nil {
+		return errPc
+	}
+	ctx, cancel := playerContext()
+	defer cancel()
+	var errMpv *mpv.Error
+	if errPn := cli.PlaylistNext(ctx); errPn != nil {
+		if errors.As(errPn, &errMpv) {
+			return fmt.Errorf("next: error: no next entry in the queue\n")
+		}
+		return errPn
+	}
+	return nil
+}
+
+// Prev plays the previous queue entry
+func Prev() error {
+	cli, errPc := playerClient()
+	if errPc != nil {
+		return errPc
+	}
+	ctx, cancel := playerContext()
+	defer cancel()
+	var errMpv *mpv.Error
+	if errPp := cli.PlaylistPrev(ctx); errPp != nil {
+		if errors.As(errPp, &errMpv) {
+			return fmt.Errorf("prev: error: no previous entry in the queue\n")
+		}
+		return errPp
+	}
+	return nil
+}
+
+// Queue runs the queue command: list (default), clear or remove n
+func Queue(args []string) (string, error) {
+	cli, errPc := playerClient()
+	if errPc != nil {
+		return "", errPc
+	}
+	ctx, cancel := playerContext()
+	defer cancel()
+	if len(args) == 0 || args[0] == "list" || args[0] == "ls" {
+		return queueList(cli)
+	}
+	switch args[0] {
+	case "clear":
+		return "", cli.PlaylistClear(ctx)
+	case "remove", "rm":
+		if len(args) != 2 {
+			return "", fmt.Errorf("queue: error: usage: %s queue remove n\n", config.ProgName)
+		}
+		num, errSa := strconv.Atoi(args[1])
+		if errSa != nil {
+			return "", fmt.Errorf("queue: error: '%s' is not a queue entry number\n", args[1])
+		}
+		if errQi := queueIndex(cli, num); errQi != nil {
+			return "", errQi
+		}
+		return "", cli.PlaylistRemove(ctx, num-1)
+	default:
+		return "", fmt.Errorf("queue: error: unknown command '%s'\n", args[0])
+	}
+}
+
+// queueIndex checks that the queue entry number n exists
+func queueIndex(cli *mpv.Client, num int) error {
+	ctx, cancel := playerContext()
+	defer cancel()
+	count, errGi := cli.GetInt(ctx, "playlist-count")
+	if errGi != nil {
+		return errGi
+	}
+	if num < 1 || num > count {
+		return fmt.Errorf("queue: error: entry '%d' not found, the queue has %d entries\n", num, count)
+	}
+	return nil
+}
+
+// queueList returns the queue entries with the current one marked
+func queueList(cli *mpv.Client) (string, error) {
+	var list strings.Builder
+	ctx, cancel := playerContext()
+	defer cancel()
+	entries, errPl := cli.Playlist(ctx)
+	if errPl != nil {
+		return "", errPl
+	}
+	numPad := strconv.Itoa(utils.CountDigit(len(entries)))
+	for num, entry := range entries {
+		selEntry := " "
+		if entry.Current {
+			selEntry = "*"
+		}
+		name := entry.Title
+		if name == "" {
+			name = entry.Filename
+		}
+		list.WriteString(fmt.Sprintf("%s%"+numPad+"d) %s\n", selEntry, num+1, name))
+	}
+	return list.String(), nil
+}
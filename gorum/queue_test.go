@@ -0,0 +1,62 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"testing"
+)
+
+func TestQueue(t *testing.T) {
+	srv := setUpTest(t)
+	if err := Next(); err == nil {
+		t.Fatal("expected error with an empty queue")
+	}
+	for _, file := range []string{"1", "2", "https://example.org/extra"} {
+		if err := Enqueue(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Enqueue("999"); err == nil {
+		t.Fatal("expected error for a missing station")
+	}
+	if path := srv.Get("path"); path != testStation(1)["url"] {
+		t.Fatalf("expected the first enqueued entry to play, got %v", path)
+	}
+	if err := Next(); err != nil {
+		t.Fatal(err)
+	}
+	content, errQu := Queue(nil)
+	if errQu != nil {
+		t.Fatal(errQu)
+	}
+	want := " 1) " + testStation(1)["url"] + "\n*2) " + testStation(2)["url"] + "\n 3) https://example.org/extra\n"
+	if content != want {
+		t.Fatalf("unexpected queue:\n%s", content)
+	}
+	if err := Jump(3); err != nil {
+		t.Fatal(err)
+	}
+	if err := Next(); err == nil {
+		t.Fatal("expected error at the end of the queue")
+	}
+	if err := Prev(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Jump(4); err == nil {
+		t.Fatal("expected error jumping past the end of the queue")
+	}
+	if _, err := Queue([]string{"remove", "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Queue([]string{"remove", "0"}); err == nil {
+		t.Fatal("expected error removing entry 0")
+	}
+	if _, err := Queue([]string{"clear"}); err != nil {
+		t.Fatal(err)
+	}
+	playlist := srv.Playlist()
+	if len(playlist) != 1 || playlist[0] != testStation(2)["url"] {
+		t.Fatalf("unexpected playlist after clear %q", playlist)
+	}
+}
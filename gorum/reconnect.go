@@ -0,0 +1,161 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+	"github.com/gonzaru/gorum/utils"
+)
+
+// reconnectState data type, the stream reconnections shown in the status
+type reconnectState struct {
+	Path       string    `json:"path"`
+	Station    int       `json:"station,omitempty"`
+	Attempts   int       `json:"attempts"`
+	Reconnects int       `json:"reconnects"`
+	Failovers  int       `json:"failovers"`
+	Failures   int       `json:"failures"`
+	Updated    time.Time `json:"updated"`
+}
+
+// reconnector data type, reconnects the dropped streams trying their mirror urls in turn
+type reconnector struct {
+	mu     sync.Mutex
+	cancel chan struct{}
+	urls   []string
+	state  reconnectState
+	play   func(url string) error
+}
+
+// reconnectMaxDelay the maximum time between two reconnection attempts
+const reconnectMaxDelay = time.Minute
+
+// newReconnector returns a reconnector playing the streams with the media player
+func newReconnector() *reconnector {
+	return &reconnector{
+		play: func(url string) error {
+			return PlayWait(url, config.PlayTimeout)
+		},
+	}
+}
+
+// start reconnects the dropped stream url in background unless it is already reconnecting
+func (r *reconnector) start(path string) {
+	if !utils.ValidUrl(path) || config.ReconnectMax == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil {
+		return
+	}
+	urls := []string{path}
+	station, _ := stationOf(path)
+	if station != 0 {
+		urls = config.StationUrls(station)
+	}
+	first := 0
+	for num, url := range urls {
+		if url == path {
+			first = num
+		}
+	}
+	r.cancel = make(chan struct{})
+	r.urls = urls
+	go r.run(urls, first, station, r.cancel)
+}
+
+// stop stops reconnecting
+func (r *reconnector) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil {
+		close(r.cancel)
+		r.cancel = nil
+	}
+}
+
+// owns checks if the path is one of the urls being reconnected
+func (r *reconnector) owns(path string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cancel != nil && utils.Contains(r.urls, path)
+}
+
+// run retries the stream urls with an increasing delay, the first retry is the dropped url
+func (r *reconnector) run(urls []string, first int, station int, cancel chan struct{}) {
+	defer func() {
+		r.mu.Lock()
+		if r.cancel == cancel {
+			r.cancel = nil
+		}
+		r.mu.Unlock()
+	}()
+	delay := config.ReconnectDelay
+	for attempt := 1; attempt <= config.ReconnectMax; attempt++ {
+		select {
+		case <-cancel:
+			log.Print("reconnect: info: cancelled\n")
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > reconnectMaxDelay {
+			delay = reconnectMaxDelay
+		}
+		url := urls[(first+attempt-1)%len(urls)]
+		log.Printf("reconnect: info: attempt %d/%d '%s'\n", attempt, config.ReconnectMax, url)
+		r.update(func(state *reconnectState) {
+			state.Path, state.Station = url, station
+			state.Attempts++
+			if attempt > 1 && url != urls[(first+attempt-2)%len(urls)] {
+				state.Failovers++
+			}
+		})
+		errPl := r.play(url)
+		if errPl == nil {
+			log.Printf("reconnect: info: reconnected '%s'\n", url)
+			r.update(func(state *reconnectState) {
+				state.Reconnects++
+			})
+			return
+		}
+		log.Print(errPl)
+	}
+	log.Printf("reconnect: error: giving up after %d attempts\n", config.ReconnectMax)
+	r.update(func(state *reconnectState) {
+		state.Failures++
+	})
+}
+
+// update changes the reconnection state and saves it for the status
+func (r *reconnector) update(fn func(state *reconnectState)) {
+	r.mu.Lock()
+	fn(&r.state)
+	r.state.Updated = time.Now()
+	state := r.state
+	r.mu.Unlock()
+	if errSs := config.SaveState(config.ReconnectFile, state); errSs != nil {
+		log.Print(errSs)
+	}
+}
+
+// reconnectStatus returns the stream reconnections as a status line, empty when there were none
+func reconnectStatus() string {
+	var state reconnectState
+	if errLs := config.LoadState(config.ReconnectFile, &state); errLs != nil || state.Attempts == 0 {
+		return ""
+	}
+	return fmt.Sprintf(
+		"recon: %d reconnects, %d attempts, %d failovers, %d failures, last %s\n",
+		state.Reconnects, state.Attempts, state.Failovers, state.Failures, state.Updated.Format("2006-01-02 15:04:05"),
+	)
+}
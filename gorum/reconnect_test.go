@@ -0,0 +1,100 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+	"github.com/gonzaru/gorum/mpv"
+)
+
+// loadReconnect loads the saved stream reconnection state
+func loadReconnect(t *testing.T) reconnectState {
+	t.Helper()
+	var state reconnectState
+	if err := config.LoadState(config.ReconnectFile, &state); err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+func TestReconnect(t *testing.T) {
+	srv := setUpTest(t)
+	streams, mirrors := config.Stations()
+	delay := config.ReconnectDelay
+	t.Cleanup(func() {
+		config.SetStations(streams, mirrors)
+		config.ReconnectDelay = delay
+	})
+	config.ReconnectDelay = 10 * time.Millisecond
+	config.SetStations(map[int]map[string]string{
+		1: {"name": "One", "url": "https://one.example/live"},
+	}, map[int][]string{1: {"https://mirror1.example/live", "https://mirror2.example/live"}})
+	cli, errMd := mpv.Dial(config.PlayerControlFile)
+	if errMd != nil {
+		t.Fatal(errMd)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- watchEvents(cli)
+	}()
+	waitFor(t, "observed properties", func() bool {
+		return len(srv.Commands()) >= len(observedProperties)
+	})
+	if err := PlayWait("1", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	srv.FailLoad("https://one.example/live", "loading failed")
+	srv.FailLoad("https://mirror1.example/live", "loading failed")
+	srv.Set("eof-reached", true)
+	waitFor(t, "reconnection", func() bool {
+		return loadReconnect(t).Reconnects == 1
+	})
+	if playlist := srv.Playlist(); len(playlist) != 1 || playlist[0] != "https://mirror2.example/live" {
+		t.Fatalf("expected the second mirror playing, got %q", playlist)
+	}
+	state := loadReconnect(t)
+	if state.Attempts != 3 || state.Failovers != 2 || state.Station != 1 || state.Path != "https://mirror2.example/live" {
+		t.Fatalf("unexpected reconnection state %+v", state)
+	}
+	content, errSt := Status()
+	if errSt != nil {
+		t.Fatal(errSt)
+	}
+	if !strings.Contains(content, "recon: 1 reconnects, 3 attempts, 2 failovers, 0 failures") {
+		t.Fatalf("expected reconnections in status:\n%s", content)
+	}
+
+	// a stream failing to load is not reconnected
+	srv.FailLoad("https://two.example/live", "loading failed")
+	if err := PlayWait("https://two.example/live", time.Second); err == nil {
+		t.Fatal("expected error playing a failing stream")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if state := loadReconnect(t); state.Attempts != 3 {
+		t.Fatalf("unexpected reconnection attempts %+v", state)
+	}
+
+	// a station failing to load plays its first working mirror
+	if err := PlayWait("1", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if playlist := srv.Playlist(); len(playlist) != 1 || playlist[0] != "https://mirror2.example/live" {
+		t.Fatalf("expected the second mirror playing, got %q", playlist)
+	}
+	srv.FailLoad("https://mirror2.example/live", "loading failed")
+	if err := PlayWait("1", time.Second); err == nil {
+		t.Fatal("expected error playing a station without working urls")
+	}
+	cli.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,394 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+	"github.com/gonzaru/gorum/icy"
+	"github.com/gonzaru/gorum/utils"
+)
+
+// recorder data type
+type recorder struct {
+	client  *http.Client
+	dir     string
+	ext     string
+	file    *os.File
+	files   []string
+	split   bool
+	station string
+	title   string
+}
+
+const (
+	// recordCheckInterval the time between two checks that the recorded stream is still playing
+	recordCheckInterval = 2 * time.Second
+
+	// recordNameMax the maximum length in bytes of a recorded file name without its extension
+	recordNameMax = 200
+)
+
+// recordExtensions the recorded file extension by stream content type
+var recordExtensions = map[string]string{
+	"application/ogg": "ogg",
+	"audio/aac":       "aac",
+	"audio/aacp":      "aac",
+	"audio/flac":      "flac",
+	"audio/mp3":       "mp3",
+	"audio/mpeg":      "mp3",
+	"audio/ogg":       "ogg",
+	"audio/opus":      "opus",
+	"audio/x-aac":     "aac",
+	"audio/x-flac":    "flac",
+}
+
+// recording data type, the background recording of the main program
+type recording struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// mainRecording the recording started with the record command
+var mainRecording recording
+
+// Record runs the record command: start [--dir dir] [--split-by-title] or stop
+func Record(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", fmt.Errorf("record: error: usage: %s record start|stop\n", config.ProgName)
+	}
+	switch args[0] {
+	case "start":
+		return recordStart(args[1:])
+	case "stop":
+		return recordStop()
+	}
+	return "", fmt.Errorf("record: error: unknown option '%s'\n", args[0])
+}
+
+// recordStart starts recording the playing stream in background
+func recordStart(args []string) (string, error) {
+	fs := flag.NewFlagSet("record start", flag.ContinueOnError)
+	dir := fs.String("dir", config.RecordDir, "directory of the recorded files")
+	split := fs.Bool("split-by-title", false, "records one file per stream title")
+	if errPf := parseFlags(fs, args); errPf != nil {
+		return "", errPf
+	}
+	if fs.NArg() > 0 {
+		return "", fmt.Errorf("record: error: unexpected argument '%s'\n", fs.Arg(0))
+	}
+	if !IsRunning() {
+		return "", fmt.Errorf("record: error: '%s' is not running\n", config.ProgName)
+	}
+	path := StreamPath()
+	if !utils.ValidUrl(path) {
+		return "", fmt.Errorf("record: error: no internet stream is playing\n")
+	}
+	recordDir, errFa := filepath.Abs(*dir)
+	if errFa != nil {
+		return "", errFa
+	}
+	mainRecording.mu.Lock()
+	defer mainRecording.mu.Unlock()
+	if mainRecording.cancel != nil {
+		return "", fmt.Errorf("record: error: already recording, see '%s record stop'\n", config.ProgName)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	mainRecording.cancel, mainRecording.done = cancel, done
+	go func() {
+		defer func() {
+			mainRecording.mu.Lock()
+			if mainRecording.done == done {
+				mainRecording.cancel, mainRecording.done = nil, nil
+			}
+			mainRecording.mu.Unlock()
+			cancel()
+			close(done)
+			notifyControl()
+		}()
+		if errRs := recordStream(ctx, path, recordDir, *split); errRs != nil {
+			log.Print(errRs)
+		}
+	}()
+	return fmt.Sprintf("record: info: recording '%s' to '%s'\n", path, recordDir), nil
+}
+
+// recordStream records the stream url until it stops playing, the stream ends or the context is done
+func recordStream(ctx context.Context, streamUrl string, dir string, split bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		ticker := time.NewTicker(recordCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if StreamPath() != streamUrl {
+					log.Printf("record: info: '%s' is not playing anymore\n", streamUrl)
+					cancel()
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	_, station := stationOf(streamUrl)
+	rec := recorder{client: icy.Client(config.PlayTimeout), dir: dir, split: split, station: station}
+	log.Printf("record: info: recording '%s' to '%s'\n", streamUrl, dir)
+	files, errRr := rec.record(ctx, streamUrl)
+	log.Printf("record: info: recorded %d files from '%s'\n", len(files), streamUrl)
+	return errRr
+}
+
+// recordStop stops the background recording, waiting for its files to be closed
+func recordStop() (string, error) {
+	mainRecording.mu.Lock()
+	cancel, done := mainRecording.cancel, mainRecording.done
+	mainRecording.mu.Unlock()
+	if cancel == nil {
+		return "", fmt.Errorf("record: error: not recording\n")
+	}
+	cancel()
+	select {
+	case <-done:
+		return "record: info: recording stopped\n", nil
+	case <-time.After(config.PlayTimeout):
+		return "", fmt.Errorf("record: error: the recording did not stop within %s\n", config.PlayTimeout)
+	}
+}
+
+// isRecording checks if the main program is recording a stream
+func isRecording() bool {
+	mainRecording.mu.Lock()
+	defer mainRecording.mu.Unlock()
+	return mainRecording.cancel != nil
+}
+
+// record captures the stream url in its native codec until the context is done or the stream ends
+func (r *recorder) record(ctx context.Context, streamUrl string) ([]string, error) {
+	req, errNr := http.NewRequestWithContext(ctx, http.MethodGet, streamUrl, nil)
+	if errNr != nil {
+		return nil, errNr
+	}
+	req.Header.Set("Icy-MetaData", "1")
+	req.Header.Set("User-Agent", config.ProgName)
+	resp, errCd := r.client.Do(req)
+	if errCd != nil {
+		if ctx.Err() != nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("record: error: %s\n", errCd.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("record: error: '%s' %s\n", streamUrl, resp.Status)
+	}
+	if r.station == "" {
+		r.station = strings.TrimSpace(resp.Header.Get("icy-name"))
+	}
+	if r.station == "" {
+		if u, errUp := url.Parse(streamUrl); errUp == nil {
+			r.station = u.Host
+		}
+	}
+	r.ext = recordExtension(resp.Header.Get("Content-Type"))
+	metaInt, _ := strconv.Atoi(resp.Header.Get("icy-metaint"))
+	errRs := r.readStream(bufio.NewReader(resp.Body), metaInt)
+	errCf := r.closeFile()
+	if ctx.Err() != nil || errors.Is(errRs, io.EOF) || errors.Is(errRs, io.ErrUnexpectedEOF) {
+		errRs = nil
+	}
+	if errRs != nil {
+		return r.files, errRs
+	}
+	return r.files, errCf
+}
+
+// readStream writes the stream audio data to the recorded files, switching files when the stream title changes
+func (r *recorder) readStream(rd *bufio.Reader, metaInt int) error {
+	if metaInt <= 0 {
+		if errOf := r.openFile(""); errOf != nil {
+			return errOf
+		}
+		_, errIc := io.Copy(r.file, rd)
+		return errIc
+	}
+	var pending []byte
+	chunk := make([]byte, metaInt)
+	for {
+		if _, errRf := io.ReadFull(rd, chunk); errRf != nil {
+			if r.file == nil && len(pending) > 0 {
+				if errOf := r.openFile(""); errOf != nil {
+					return errOf
+				}
+				if _, errFw := r.file.Write(pending); errFw != nil {
+					return errFw
+				}
+			}
+			return errRf
+		}
+		if r.file == nil {
+			pending = append(pending[:0], chunk...)
+		} else if _, errFw := r.file.Write(chunk); errFw != nil {
+			return errFw
+		}
+		meta, errRm := icy.ReadMeta(rd)
+		if errRm != nil {
+			return errRm
+		}
+		title, ok := icy.StreamTitle(meta)
+		if r.file == nil {
+			if errOf := r.openFile(title); errOf != nil {
+				return errOf
+			}
+			if _, errFw := r.file.Write(pending); errFw != nil {
+				return errFw
+			}
+			pending = nil
+		} else if r.split && ok && title != "" && title != r.title {
+			if errCf := r.closeFile(); errCf != nil {
+				return errCf
+			}
+			if errOf := r.openFile(title); errOf != nil {
+				return errOf
+			}
+		}
+	}
+}
+
+// openFile creates the next recorded file, named from the stream title when splitting
+func (r *recorder) openFile(title string) error {
+	r.title = title
+	name := title
+	if !r.split || name == "" {
+		name = r.station + " " + time.Now().Format("2006-01-02 15-04-05")
+	}
+	if errMa := os.MkdirAll(r.dir, 0755); errMa != nil {
+		return errMa
+	}
+	name = recordFileName(name)
+	for num := 1; ; num++ {
+		file := filepath.Join(r.dir, name+"."+r.ext)
+		if num > 1 {
+			file = filepath.Join(r.dir, fmt.Sprintf("%s (%d).%s", name, num, r.ext))
+		}
+		fh, errOf := os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if errors.Is(errOf, os.ErrExist) {
+			continue
+		}
+		if errOf != nil {
+			return errOf
+		}
+		r.file = fh
+		r.files = append(r.files, file)
+		log.Printf("record: info: recording file '%s'\n", file)
+		break
+	}
+	if r.ext != "mp3" && r.ext != "aac" {
+		return nil
+	}
+	tagTitle := ""
+	if r.split {
+		tagTitle = title
+	}
+	if _, errFw := r.file.Write(id3Tag(tagTitle, r.station)); errFw != nil {
+		return errFw
+	}
+	return nil
+}
+
+// closeFile closes the current recorded file
+func (r *recorder) closeFile() error {
+	if r.file == nil {
+		return nil
+	}
+	fh := r.file
+	r.file = nil
+	return fh.Close()
+}
+
+// id3Tag returns an id3v2.4 tag with the artist and title of the stream title and the station name
+func id3Tag(title, station string) []byte {
+	var frames bytes.Buffer
+	artist, song := "", title
+	if parts := strings.SplitN(title, " - ", 2); len(parts) == 2 {
+		artist, song = strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+	}
+	for _, frame := range [][2]string{{"TPE1", artist}, {"TIT2", song}, {"TRSN", station}} {
+		if frame[1] == "" {
+			continue
+		}
+		data := append([]byte{3}, frame[1]...) // utf-8 text encoding
+		frames.WriteString(frame[0])
+		frames.Write(syncSafe(len(data)))
+		frames.Write([]byte{0, 0})
+		frames.Write(data)
+	}
+	if frames.Len() == 0 {
+		return nil
+	}
+	tag := append([]byte("ID3\x04\x00\x00"), syncSafe(frames.Len())...)
+	return append(tag, frames.Bytes()...)
+}
+
+// recordExtension returns the recorded file extension of the stream content type
+func recordExtension(contentType string) string {
+	mediaType, _, errPm := mime.ParseMediaType(contentType)
+	if errPm != nil {
+		return "bin"
+	}
+	if ext, ok := recordExtensions[strings.ToLower(mediaType)]; ok {
+		return ext
+	}
+	return "bin"
+}
+
+// recordFileName returns the name without path separators nor control characters
+func recordFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || unicode.IsControl(r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimLeft(strings.TrimSpace(name), ".")
+	for len(name) > recordNameMax {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	if name = strings.TrimSpace(name); name == "" {
+		return "untitled"
+	}
+	return name
+}
+
+// syncSafe returns the id3v2 synchsafe integer of n
+func syncSafe(n int) []byte {
+	return []byte{byte(n >> 21 & 0x7f), byte(n >> 14 & 0x7f), byte(n >> 7 & 0x7f), byte(n & 0x7f)}
+}
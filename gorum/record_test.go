@@ -0,0 +1,183 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+	"github.com/gonzaru/gorum/icy"
+)
+
+// icyBlock returns the icy metadata block of the stream title, an empty title returns an empty block
+func icyBlock(title string) []byte {
+	if title == "" {
+		return []byte{0}
+	}
+	meta := []byte(fmt.Sprintf("StreamTitle='%s';StreamUrl='';", title))
+	size := (len(meta) + 15) / 16
+	return append([]byte{byte(size)}, append(meta, make([]byte, size*16-len(meta))...)...)
+}
+
+// icyStream returns the stream body with the audio chunks of metaInt bytes followed by their metadata blocks
+func icyStream(metaInt int, titles []string) []byte {
+	var body bytes.Buffer
+	for num, title := range titles {
+		body.Write(bytes.Repeat([]byte{byte('A' + num)}, metaInt))
+		body.Write(icyBlock(title))
+	}
+	return body.Bytes()
+}
+
+func TestRecord(t *testing.T) {
+	const metaInt = 16
+	titles := []string{"Artist One - Song", "", "AC/DC - It's Other", ""}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Header().Set("icy-name", "Test Radio")
+		if r.Header.Get("Icy-MetaData") == "1" {
+			w.Header().Set("icy-metaint", fmt.Sprint(metaInt))
+			w.Write(icyStream(metaInt, titles))
+			return
+		}
+		w.Write([]byte("ABCD"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	rec := recorder{client: icy.Client(config.PlayTimeout), dir: dir, split: true}
+	files, errRr := rec.record(context.Background(), srv.URL)
+	if errRr != nil {
+		t.Fatal(errRr)
+	}
+	want := []string{filepath.Join(dir, "Artist One - Song.mp3"), filepath.Join(dir, "AC_DC - It's Other.mp3")}
+	if strings.Join(files, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected files %q, want %q", files, want)
+	}
+	first, errRf := os.ReadFile(files[0])
+	if errRf != nil {
+		t.Fatal(errRf)
+	}
+	tag := id3Tag("Artist One - Song", "Test Radio")
+	if !bytes.HasPrefix(first, tag) || !bytes.Contains(tag, []byte("Test Radio")) {
+		t.Fatalf("unexpected tag %q", first)
+	}
+	if audio := string(first[len(tag):]); audio != strings.Repeat("A", metaInt)+strings.Repeat("B", metaInt)+strings.Repeat("C", metaInt) {
+		t.Fatalf("unexpected first file audio %q", audio)
+	}
+	second, errRf := os.ReadFile(files[1])
+	if errRf != nil {
+		t.Fatal(errRf)
+	}
+	if !bytes.Equal(second, append(id3Tag("AC/DC - It's Other", "Test Radio"), bytes.Repeat([]byte("D"), metaInt)...)) {
+		t.Fatalf("unexpected second file %q", second)
+	}
+
+	rec = recorder{client: icy.Client(config.PlayTimeout), dir: dir, station: "My Station"}
+	files, errRr = rec.record(context.Background(), srv.URL)
+	if errRr != nil {
+		t.Fatal(errRr)
+	}
+	if len(files) != 1 || !strings.HasPrefix(filepath.Base(files[0]), "My Station ") {
+		t.Fatalf("unexpected single file %q", files)
+	}
+	single, errRf := os.ReadFile(files[0])
+	if errRf != nil {
+		t.Fatal(errRf)
+	}
+	if !bytes.HasSuffix(single, []byte(strings.Repeat("A", metaInt)+strings.Repeat("B", metaInt)+strings.Repeat("C", metaInt)+strings.Repeat("D", metaInt))) {
+		t.Fatalf("unexpected single file %q", single)
+	}
+}
+
+func TestRecordShoutcast(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, errHj := w.(http.Hijacker).Hijack()
+		if errHj != nil {
+			t.Error(errHj)
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("ICY 200 OK\r\nicy-name: Old Radio\r\nContent-Type: audio/aacp\r\nicy-metaint: 4\r\n\r\n")
+		buf.Write(icyStream(4, []string{"Only Song"}))
+		buf.Flush()
+	}))
+	defer srv.Close()
+
+	rec := recorder{client: icy.Client(config.PlayTimeout), dir: t.TempDir(), split: true}
+	files, errRr := rec.record(context.Background(), srv.URL)
+	if errRr != nil {
+		t.Fatal(errRr)
+	}
+	if len(files) != 1 || filepath.Base(files[0]) != "Only Song.aac" {
+		t.Fatalf("unexpected files %q", files)
+	}
+	if rec.station != "Old Radio" {
+		t.Fatalf("unexpected station %q", rec.station)
+	}
+}
+
+func TestRecordFileName(t *testing.T) {
+	if name := recordFileName(" ../a/b\n"); name != "_a_b_" {
+		t.Errorf("unexpected file name %q", name)
+	}
+}
+
+func TestRecordControl(t *testing.T) {
+	setUpTest(t)
+	startControlTest(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/mpeg")
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(10 * time.Millisecond):
+				w.Write([]byte("AAAA"))
+				w.(http.Flusher).Flush()
+			}
+		}
+	}))
+	defer srv.Close()
+	if err := Control(nil, "play", srv.URL+"/live"); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	var content string
+	if err := Control(&content, "record", "start", "--dir", dir); err != nil || !strings.Contains(content, "recording") {
+		t.Fatalf("unexpected record start %q %v", content, err)
+	}
+	if err := Control(nil, "record", "start", "--dir", dir); err == nil || !strings.Contains(err.Error(), "already recording") {
+		t.Fatalf("expected already recording error, got %v", err)
+	}
+	var state controlState
+	if err := Control(&state, "state"); err != nil || !state.Recording {
+		t.Fatalf("expected a recording state, got %+v %v", state, err)
+	}
+	waitFor(t, "the recorded file", func() bool {
+		files, _ := filepath.Glob(filepath.Join(dir, "*.mp3"))
+		return len(files) == 1
+	})
+	if err := Control(&content, "record", "stop"); err != nil || content != "record: info: recording stopped\n" {
+		t.Fatalf("unexpected record stop %q %v", content, err)
+	}
+	if err := Control(&state, "state"); err != nil || state.Recording {
+		t.Fatalf("expected the recording stopped, got %+v %v", state, err)
+	}
+	if err := Control(nil, "record", "stop"); err == nil || !strings.Contains(err.Error(), "not recording") {
+		t.Fatalf("expected not recording error, got %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+	"github.com/gonzaru/gorum/mpv"
+)
+
+// resumeEntry data type
+type resumeEntry struct {
+	Position float64   `json:"position"`
+	Updated  time.Time `json:"updated"`
+}
+
+const (
+	// resumeInterval the minimum time between two saves of the playback position
+	resumeInterval = 10 * time.Second
+
+	// resumeMinPosition the playback position in seconds below which it is not worth resuming
+	resumeMinPosition = 5
+)
+
+// resumeMu serializes the resume file updates
+var resumeMu sync.Mutex
+
+// forgetPosition removes the saved playback position of the local file
+func forgetPosition(path string) error {
+	if !filepath.IsAbs(path) {
+		return nil
+	}
+	resumeMu.Lock()
+	defer resumeMu.Unlock()
+	entries, errLr := loadResume()
+	if errLr != nil {
+		return errLr
+	}
+	if _, ok := entries[path]; !ok {
+		return nil
+	}
+	delete(entries, path)
+	return config.SaveState(config.ResumeFile, entries)
+}
+
+// loadResume loads the saved playback positions by absolute file path
+func loadResume() (map[string]resumeEntry, error) {
+	entries := make(map[string]resumeEntry)
+	if errLs := config.LoadState(config.ResumeFile, &entries); errLs != nil {
+		return nil, errLs
+	}
+	return entries, nil
+}
+
+// resumePlayer seeks the current local file to its saved playback position
+func resumePlayer(cli *mpv.Client) error {
+	ctx, cancel := playerContext()
+	defer cancel()
+	path, errGs := cli.GetString(ctx, "path")
+	if errGs != nil {
+		return errGs
+	}
+	pos, ok, errRp := ResumePosition(path)
+	if errRp != nil || !ok {
+		return errRp
+	}
+	if errSa := cli.SeekAbsolute(ctx, pos); errSa != nil {
+		return errSa
+	}
+	return nil
+}
+
+// ResumePosition returns the saved playback position in seconds of the local file
+func ResumePosition(file string) (float64, bool, error) {
+	path, errFa := filepath.Abs(file)
+	if errFa != nil {
+		return 0, false, errFa
+	}
+	entries, errLr := loadResume()
+	if errLr != nil {
+		return 0, false, errLr
+	}
+	entry, ok := entries[path]
+	return entry.Position, ok, nil
+}
+
+// savePosition saves the playback position of the local file
+func savePosition(path string, pos float64) error {
+	if !filepath.IsAbs(path) || pos < resumeMinPosition {
+		return nil
+	}
+	resumeMu.Lock()
+	defer resumeMu.Unlock()
+	entries, errLr := loadResume()
+	if errLr != nil {
+		return errLr
+	}
+	entries[path] = resumeEntry{Position: pos, Updated: time.Now()}
+	return config.SaveState(config.ResumeFile, entries)
+}
+
+// FormatPosition formats the playback position in seconds as hh:mm:ss
+func FormatPosition(pos float64) string {
+	secs := int(pos)
+	return fmt.Sprintf("%02d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+}
@@ -0,0 +1,74 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+	"github.com/gonzaru/gorum/mpv"
+)
+
+func TestResume(t *testing.T) {
+	srv := setUpTest(t)
+	dir := t.TempDir()
+	bookFile, otherFile := filepath.Join(dir, "book.m4b"), filepath.Join(dir, "other.mp3")
+	for _, file := range []string{bookFile, otherFile} {
+		if errWf := os.WriteFile(file, []byte{}, 0600); errWf != nil {
+			t.Fatal(errWf)
+		}
+	}
+	cli, errMd := mpv.Dial(config.PlayerControlFile)
+	if errMd != nil {
+		t.Fatal(errMd)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- watchEvents(cli)
+	}()
+	defer func() {
+		cli.Close()
+		<-done
+	}()
+	srv.Set("seekable", true)
+	waitFor(t, "observed properties", func() bool {
+		return len(srv.Commands()) >= len(observedProperties)
+	})
+	if err := PlayWait(bookFile, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	srv.Set("time-pos", float64(125))
+	waitFor(t, "saved position", func() bool {
+		pos, ok, _ := ResumePosition(bookFile)
+		return ok && pos == 125
+	})
+	srv.Set("time-pos", float64(130))
+	if err := PlayWait(otherFile, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "position saved on file change", func() bool {
+		pos, _, _ := ResumePosition(bookFile)
+		return pos == 130
+	})
+	if err := PlayResume(bookFile, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if pos := srv.Get("time-pos"); pos != float64(130) {
+		t.Fatalf("expected resumed time-pos 130, got %v", pos)
+	}
+	srv.Set("eof-reached", true)
+	waitFor(t, "position removed on eof", func() bool {
+		_, ok, _ := ResumePosition(bookFile)
+		return !ok
+	})
+	if err := PlayResume("https://example.org/stream", time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,488 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+	"github.com/gonzaru/gorum/utils"
+)
+
+// scheduleJob data type
+type scheduleJob struct {
+	Id      int       `json:"id"`
+	Action  string    `json:"action"`
+	Station int       `json:"station"`
+	Days    string    `json:"days"`
+	At      string    `json:"at"`
+	Until   string    `json:"until,omitempty"`
+	Missed  string    `json:"missed"`
+	Split   bool      `json:"split,omitempty"`
+	Once    bool      `json:"once,omitempty"`
+	Fade    float64   `json:"fade,omitempty"`
+	Volume  int       `json:"volume,omitempty"`
+	File    string    `json:"file,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// scheduleActive data type, a running job waiting for its end time
+type scheduleActive struct {
+	Job scheduleJob `json:"job"`
+	End time.Time   `json:"end"`
+}
+
+// scheduleState data type, the scheduler state kept across restarts
+type scheduleState struct {
+	Runs   map[int]time.Time `json:"runs"`
+	Active []scheduleActive  `json:"active"`
+}
+
+// scheduler data type
+type scheduler struct {
+	state scheduleState
+	start func(job scheduleJob) error
+	end   func(job scheduleJob) error
+}
+
+const (
+	// scheduleInterval the time between two checks of the scheduled jobs
+	scheduleInterval = 15 * time.Second
+
+	// scheduleGrace the delay after which a job occurrence is considered missed
+	scheduleGrace = time.Minute
+)
+
+var (
+	// ScheduleActions the actions of the scheduled jobs
+	ScheduleActions = []string{"play", "record"}
+
+	// ScheduleMissed the policies of the missed job occurrences, skip them or run the latest one late
+	ScheduleMissed = []string{"skip", "run"}
+)
+
+// scheduleDays the week day names of the days specification
+var scheduleDays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Schedule runs the schedule command: list (default), add or remove n
+func Schedule(args []string) (string, error) {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch args[0] {
+	case "add":
+		return scheduleAdd(args[1:])
+	case "list", "ls":
+		jobs, errLs := loadSchedule()
+		if errLs != nil {
+			return "", errLs
+		}
+		return scheduleList(jobs, time.Now())
+	case "remove", "rm":
+		return "", scheduleRemove(args[1:])
+	}
+	return "", fmt.Errorf("schedule: error: unknown option '%s'\n", args[0])
+}
+
+// scheduleAdd adds the job: play|record station --at hh:mm [--until hh:mm] [--days d] [--missed skip|run]
+func scheduleAdd(args []string) (string, error) {
+	usage := fmt.Errorf(
+		"schedule: error: usage: %s schedule add play|record number --at hh:mm [--until hh:mm] [--days d] [--missed skip|run] [--split-by-title]\n",
+		config.ProgName,
+	)
+	if len(args) < 2 {
+		return "", usage
+	}
+	job := scheduleJob{Action: args[0], Created: time.Now()}
+	if !utils.Contains(ScheduleActions, job.Action) {
+		return "", fmt.Errorf("schedule: error: unknown action '%s', use %s\n", job.Action, strings.Join(ScheduleActions, "|"))
+	}
+	station, errSa := strconv.Atoi(args[1])
+	if _, ok := config.Station(station); errSa != nil || !ok {
+		return "", fmt.Errorf("schedule: error: station '%s' not found\n", args[1])
+	}
+	job.Station = station
+	fs := flag.NewFlagSet("schedule add", flag.ContinueOnError)
+	fs.StringVar(&job.At, "at", "", "start time (15:04)")
+	fs.StringVar(&job.Until, "until", "", "end time (15:04), the next day when it is not after the start time")
+	fs.StringVar(&job.Days, "days", "daily", "days: daily, weekdays, weekends or a list of mon,tue,wed,thu,fri,sat,sun and ranges mon-fri")
+	fs.StringVar(&job.Missed, "missed", "skip", "missed job policy: skip or run")
+	fs.BoolVar(&job.Split, "split-by-title", false, "records one file per stream title")
+	if errPf := parseFlags(fs, args[2:]); errPf != nil {
+		return "", errPf
+	}
+	if fs.NArg() > 0 {
+		return "", fmt.Errorf("schedule: error: unexpected argument '%s'\n", fs.Arg(0))
+	}
+	if job.At == "" {
+		return "", usage
+	}
+	if errCj := checkJob(job); errCj != nil {
+		return "", errCj
+	}
+	id, errAj := addJob(job)
+	if errAj != nil {
+		return "", errAj
+	}
+	return fmt.Sprintf("schedule: info: added job %d, next run %s\n", id, job.next(time.Now()).Format("2006-01-02 15:04")), nil
+}
+
+// addJob saves the job with the next free number and returns it
+func addJob(job scheduleJob) (int, error) {
+	var jobs []scheduleJob
+	errUs := config.UpdateState(config.ScheduleFile, &jobs, func() error {
+		job.Id = 1
+		for _, other := range jobs {
+			if other.Id >= job.Id {
+				job.Id = other.Id + 1
+			}
+		}
+		jobs = append(jobs, job)
+		return nil
+	})
+	if errUs != nil {
+		return 0, errUs
+	}
+	return job.Id, nil
+}
+
+// scheduleRemove removes the job number n
+func scheduleRemove(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("schedule: error: usage: %s schedule remove n\n", config.ProgName)
+	}
+	id, errSa := strconv.Atoi(args[0])
+	if errSa != nil {
+		return fmt.Errorf("schedule: error: '%s' is not a job number\n", args[0])
+	}
+	removed, errRj := removeJobs(map[int]bool{id: true})
+	if errRj != nil {
+		return errRj
+	}
+	if removed == 0 {
+		return fmt.Errorf("schedule: error: job '%d' not found\n", id)
+	}
+	return nil
+}
+
+// removeJobs removes the jobs by number and returns how many were removed
+func removeJobs(ids map[int]bool) (int, error) {
+	var jobs []scheduleJob
+	removed := 0
+	errUs := config.UpdateState(config.ScheduleFile, &jobs, func() error {
+		kept := jobs[:0]
+		for _, job := range jobs {
+			if !ids[job.Id] {
+				kept = append(kept, job)
+			}
+		}
+		removed = len(jobs) - len(kept)
+		jobs = kept
+		return nil
+	})
+	return removed, errUs
+}
+
+// scheduleList returns the jobs as aligned text lines with their next run
+func scheduleList(jobs []scheduleJob, now time.Time) (string, error) {
+	var list bytes.Buffer
+	tw := tabwriter.NewWriter(&list, 0, 0, 2, ' ', 0)
+	for _, job := range jobs {
+		when := job.At
+		if job.Until != "" {
+			when += "-" + job.Until
+		}
+		action := job.Action
+		if job.Split {
+			action += " (split)"
+		}
+		stream, _ := config.Station(job.Station)
+		target := fmt.Sprintf("%d %s", job.Station, stream["name"])
+		if job.Station == 0 {
+			target = job.File
+		}
+		days := job.Days
+		if job.Once {
+			days = "once"
+		}
+		line := fmt.Sprintf(
+			"%d)\t%s\t%s\t%s %s\tmissed: %s\tnext: %s\n",
+			job.Id, action, target, days, when, job.Missed, job.next(now).Format("2006-01-02 15:04"),
+		)
+		if _, errTw := tw.Write([]byte(line)); errTw != nil {
+			return "", errTw
+		}
+	}
+	if errTf := tw.Flush(); errTf != nil {
+		return "", errTf
+	}
+	return list.String(), nil
+}
+
+// checkJob checks the job times, days and policy
+func checkJob(job scheduleJob) error {
+	if _, errTp := time.Parse("15:04", job.At); errTp != nil {
+		return fmt.Errorf("schedule: error: '%s' is not a time (15:04)\n", job.At)
+	}
+	if job.Until != "" {
+		if _, errTp := time.Parse("15:04", job.Until); errTp != nil {
+			return fmt.Errorf("schedule: error: '%s' is not a time (15:04)\n", job.Until)
+		}
+	} else if job.Action == "record" {
+		return fmt.Errorf("schedule: error: record jobs need an end time, see --until\n")
+	}
+	if _, errPd := parseDays(job.Days); errPd != nil {
+		return errPd
+	}
+	if !utils.Contains(ScheduleMissed, job.Missed) {
+		return fmt.Errorf("schedule: error: unknown missed policy '%s', use %s\n", job.Missed, strings.Join(ScheduleMissed, "|"))
+	}
+	return nil
+}
+
+// parseDays returns the week days of the days specification
+func parseDays(spec string) ([7]bool, error) {
+	var days [7]bool
+	switch strings.ToLower(spec) {
+	case "daily", "*":
+		spec = "sun-sat"
+	case "weekdays":
+		spec = "mon-fri"
+	case "weekends":
+		spec = "sat,sun"
+	}
+	for _, part := range strings.Split(strings.ToLower(spec), ",") {
+		bounds := strings.SplitN(strings.TrimSpace(part), "-", 2)
+		first, okFirst := scheduleDays[bounds[0]]
+		last, okLast := first, okFirst
+		if len(bounds) == 2 {
+			last, okLast = scheduleDays[bounds[1]]
+		}
+		if !okFirst || !okLast {
+			return days, fmt.Errorf("schedule: error: '%s' is not a days specification\n", spec)
+		}
+		for day := first; ; day = (day + 1) % 7 {
+			days[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// occurrence returns the job start time of the day
+func (j scheduleJob) occurrence(day time.Time) (time.Time, bool) {
+	days, errPd := parseDays(j.Days)
+	at, errTp := time.Parse("15:04", j.At)
+	if errPd != nil || errTp != nil {
+		return time.Time{}, false
+	}
+	start := time.Date(day.Year(), day.Month(), day.Day(), at.Hour(), at.Minute(), 0, 0, day.Location())
+	return start, days[start.Weekday()]
+}
+
+// prev returns the latest job start time not after now
+func (j scheduleJob) prev(now time.Time) time.Time {
+	for back := 0; back <= 7; back++ {
+		if start, ok := j.occurrence(now.AddDate(0, 0, -back)); ok && !start.After(now) {
+			return start
+		}
+	}
+	return time.Time{}
+}
+
+// next returns the next job start time after now
+func (j scheduleJob) next(now time.Time) time.Time {
+	for ahead := 0; ahead <= 7; ahead++ {
+		if start, ok := j.occurrence(now.AddDate(0, 0, ahead)); ok && start.After(now) {
+			return start
+		}
+	}
+	return time.Time{}
+}
+
+// endOf returns the job end time of the start time, zero when the job has no end time
+func (j scheduleJob) endOf(start time.Time) time.Time {
+	until, errTp := time.Parse("15:04", j.Until)
+	if j.Until == "" || errTp != nil {
+		return time.Time{}
+	}
+	end := time.Date(start.Year(), start.Month(), start.Day(), until.Hour(), until.Minute(), 0, 0, start.Location())
+	if !end.After(start) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end
+}
+
+// loadSchedule loads the scheduled jobs sorted by number
+func loadSchedule() ([]scheduleJob, error) {
+	var jobs []scheduleJob
+	if errLs := config.LoadState(config.ScheduleFile, &jobs); errLs != nil {
+		return nil, errLs
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].Id < jobs[j].Id
+	})
+	return jobs, nil
+}
+
+// newScheduler returns a scheduler with its saved state
+func newScheduler() (*scheduler, error) {
+	s := &scheduler{start: scheduleStart, end: scheduleEnd}
+	if errLs := config.LoadState(config.ScheduleStateFile, &s.state); errLs != nil {
+		return nil, errLs
+	}
+	if s.state.Runs == nil {
+		s.state.Runs = make(map[int]time.Time)
+	}
+	return s, nil
+}
+
+// tick ends the active jobs past their end time and starts the due ones, running or skipping the missed ones
+func (s *scheduler) tick(now time.Time) error {
+	jobs, errLs := loadSchedule()
+	if errLs != nil {
+		return errLs
+	}
+	changed := false
+	done := make(map[int]bool)
+	active := s.state.Active[:0]
+	for _, act := range s.state.Active {
+		if now.Before(act.End) {
+			active = append(active, act)
+			continue
+		}
+		changed = true
+		log.Printf("schedule: info: ending job %d %s %d\n", act.Job.Id, act.Job.Action, act.Job.Station)
+		if errEn := s.end(act.Job); errEn != nil {
+			log.Print(errEn)
+		}
+	}
+	s.state.Active = active
+	ids := make(map[int]bool, len(jobs))
+	for _, job := range jobs {
+		ids[job.Id] = true
+		start := job.prev(now)
+		last, ok := s.state.Runs[job.Id]
+		if !ok || last.Before(job.Created) {
+			last = job.Created
+		}
+		if start.IsZero() || !start.After(last) {
+			continue
+		}
+		changed = true
+		s.state.Runs[job.Id] = start
+		if job.Once {
+			done[job.Id] = true
+		}
+		end := job.endOf(start)
+		late := now.Sub(start) > scheduleGrace
+		if (!end.IsZero() && !now.Before(end)) || (late && job.Missed != "run") {
+			log.Printf("schedule: info: skipping missed job %d at %s\n", job.Id, start.Format("2006-01-02 15:04"))
+			continue
+		}
+		if late {
+			log.Printf("schedule: info: running missed job %d at %s\n", job.Id, start.Format("2006-01-02 15:04"))
+		}
+		log.Printf("schedule: info: starting job %d %s %d\n", job.Id, job.Action, job.Station)
+		if errSt := s.start(job); errSt != nil {
+			log.Print(errSt)
+			continue
+		}
+		if !end.IsZero() {
+			s.state.Active = append(s.state.Active, scheduleActive{Job: job, End: end})
+		}
+	}
+	if len(done) > 0 {
+		if _, errRj := removeJobs(done); errRj != nil {
+			log.Print(errRj)
+		}
+	}
+	for id := range s.state.Runs {
+		if !ids[id] || done[id] {
+			changed = true
+			delete(s.state.Runs, id)
+		}
+	}
+	if !changed {
+		return nil
+	}
+	notifyControl()
+	return config.SaveState(config.ScheduleStateFile, s.state)
+}
+
+// scheduleStart plays the job station, starts recording it for the record jobs or rings the alarm
+func scheduleStart(job scheduleJob) error {
+	if job.Action == "alarm" {
+		return startAlarm(job)
+	}
+	if errPw := PlayWait(strconv.Itoa(job.Station), config.PlayTimeout); errPw != nil {
+		return errPw
+	}
+	if job.Action != "record" {
+		return nil
+	}
+	var args []string
+	if job.Split {
+		args = append(args, "--split-by-title")
+	}
+	content, errRs := recordStart(args)
+	if errRs != nil {
+		return errRs
+	}
+	log.Print(content)
+	return nil
+}
+
+// scheduleEnd stops the job recording and the job station if it is still playing
+func scheduleEnd(job scheduleJob) error {
+	if job.Action == "record" {
+		if _, errRs := recordStop(); errRs != nil {
+			log.Print(errRs)
+		}
+	}
+	if id, _ := stationOf(StreamPath()); id != job.Station {
+		return nil
+	}
+	return PlayStop()
+}
+
+// startScheduler runs the scheduled jobs in background
+func startScheduler() {
+	s, errNs := newScheduler()
+	if errNs != nil {
+		log.Print(errNs)
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(scheduleInterval)
+		defer ticker.Stop()
+		for {
+			if errTi := s.tick(time.Now()); errTi != nil {
+				log.Print(errTi)
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -0,0 +1,151 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+)
+
+func TestSchedule(t *testing.T) {
+	setUpTest(t)
+	for _, args := range [][]string{
+		{"add", "play", "1", "--at", "07:00"},
+		{"add", "record", "2", "--at", "06:00", "--until", "09:00", "--days", "weekdays", "--missed", "run", "--split-by-title"},
+	} {
+		if _, err := Schedule(args); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, args := range [][]string{
+		{"add", "record", "1", "--at", "06:00"},
+		{"add", "play", "1", "--at", "25:00"},
+		{"add", "play", "1", "--at", "07:00", "--days", "mon-xyz"},
+		{"add", "play", "1", "--at", "07:00", "--missed", "later"},
+		{"add", "stop", "1", "--at", "07:00"},
+		{"add", "play", "999", "--at", "07:00"},
+	} {
+		if _, err := Schedule(args); err == nil {
+			t.Errorf("expected error for %q", args)
+		}
+	}
+	jobs, errLs := loadSchedule()
+	if errLs != nil {
+		t.Fatal(errLs)
+	}
+	if len(jobs) != 2 || jobs[1].Id != 2 || !jobs[1].Split || jobs[0].Days != "daily" || jobs[0].Missed != "skip" {
+		t.Fatalf("unexpected jobs %+v", jobs)
+	}
+	monday := time.Date(2026, 10, 12, 0, 0, 0, 0, time.Local)
+	for num := range jobs {
+		jobs[num].Created = monday
+	}
+	if err := config.SaveState(config.ScheduleFile, jobs); err != nil {
+		t.Fatal(err)
+	}
+
+	var calls []string
+	newTestScheduler := func() *scheduler {
+		s, errNs := newScheduler()
+		if errNs != nil {
+			t.Fatal(errNs)
+		}
+		s.start = func(job scheduleJob) error {
+			calls = append(calls, fmt.Sprintf("start %d", job.Id))
+			return nil
+		}
+		s.end = func(job scheduleJob) error {
+			calls = append(calls, fmt.Sprintf("end %d", job.Id))
+			return nil
+		}
+		return s
+	}
+	s := newTestScheduler()
+	for _, step := range []struct {
+		now  time.Time
+		want string
+	}{
+		{monday.Add(7*time.Hour + 30*time.Second), "start 1,start 2"},
+		{monday.Add(7*time.Hour + 45*time.Second), ""},
+		{monday.Add(9*time.Hour + 10*time.Second), "end 2"},
+		{monday.AddDate(0, 0, 1).Add(7*time.Hour + 30*time.Minute), "start 2"},
+		{monday.AddDate(0, 0, 5).Add(6*time.Hour + 10*time.Second), "end 2"},
+	} {
+		calls = nil
+		if err := s.tick(step.now); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(calls, ","); got != step.want {
+			t.Fatalf("tick at %s: got %q, want %q", step.now, got, step.want)
+		}
+		// the state survives restarts
+		s = newTestScheduler()
+	}
+
+	content, errSc := Schedule(nil)
+	if errSc != nil {
+		t.Fatal(errSc)
+	}
+	if strings.Count(content, "\n") != 2 || !strings.Contains(content, "record (split)") || !strings.Contains(content, "weekdays 06:00-09:00") {
+		t.Fatalf("unexpected schedule list:\n%s", content)
+	}
+	if _, err := Schedule([]string{"remove", "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Schedule([]string{"rm", "1"}); err == nil {
+		t.Fatal("expected error removing a missing job")
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	job := scheduleJob{Days: "sat,sun", At: "20:00", Until: "01:00"}
+	friday := time.Date(2026, 10, 16, 21, 0, 0, 0, time.Local)
+	next := job.next(friday)
+	if want := time.Date(2026, 10, 17, 20, 0, 0, 0, time.Local); !next.Equal(want) {
+		t.Fatalf("next = %s, want %s", next, want)
+	}
+	if end := job.endOf(next); !end.Equal(time.Date(2026, 10, 18, 1, 0, 0, 0, time.Local)) {
+		t.Fatalf("unexpected end %s", end)
+	}
+	if prev := job.prev(friday); !prev.Equal(time.Date(2026, 10, 11, 20, 0, 0, 0, time.Local)) {
+		t.Fatalf("unexpected prev %s", prev)
+	}
+	days, errPd := parseDays("fri-mon")
+	if errPd != nil {
+		t.Fatal(errPd)
+	}
+	if days != [7]bool{true, true, false, false, false, true, true} {
+		t.Fatalf("unexpected days %v", days)
+	}
+}
+
+func TestScheduleConcurrent(t *testing.T) {
+	setUpTest(t)
+	var wg sync.WaitGroup
+	for num := 0; num < 20; num++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := addJob(scheduleJob{Action: "play", Station: 1, At: "07:00", Days: "daily", Missed: "skip"}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	jobs, errLs := loadSchedule()
+	if errLs != nil {
+		t.Fatal(errLs)
+	}
+	if len(jobs) != 20 || jobs[0].Id != 1 || jobs[19].Id != 20 {
+		t.Fatalf("expected 20 jobs numbered in turn, got %+v", jobs)
+	}
+}
@@ -0,0 +1,264 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+)
+
+// sleepTimer data type
+type sleepTimer struct {
+	End  time.Time
+	Fade float64
+	Quit bool
+}
+
+// sleeper data type, the sleep timer of the main program kept in memory
+// mu guards the timer and is never held while talking to the media player, act serializes the volume changes
+type sleeper struct {
+	mu      sync.Mutex
+	act     sync.Mutex
+	timer   sleepTimer
+	wake    *time.Timer
+	running bool
+	fading  bool
+	volume  int
+}
+
+const (
+	// sleepFade the default time in seconds to fade out the volume before stopping
+	sleepFade = 60
+
+	// sleepInterval the time between two volume steps while fading out
+	sleepInterval = time.Second
+)
+
+// mainSleeper the sleep timer set with the sleep command
+var mainSleeper sleeper
+
+// Sleep runs the sleep command: prints the remaining time, cancel or duration [--fade 60s] [--quit]
+func Sleep(args []string) (string, error) {
+	if !IsRunning() {
+		return "", fmt.Errorf("sleep: error: '%s' is not running\n", config.ProgName)
+	}
+	if len(args) == 0 {
+		remaining, ok := sleepRemaining()
+		if !ok {
+			return "sleep: info: no sleep timer\n", nil
+		}
+		return fmt.Sprintf("sleep: info: %s remaining\n", remaining), nil
+	}
+	if args[0] == "cancel" {
+		if errCa := mainSleeper.cancel(); errCa != nil {
+			return "", errCa
+		}
+		return "sleep: info: sleep timer cancelled\n", nil
+	}
+	duration, errPs := parseSleep(args[0])
+	if errPs != nil {
+		return "", errPs
+	}
+	fs := flag.NewFlagSet("sleep", flag.ContinueOnError)
+	fade := fs.Duration("fade", sleepFade*time.Second, "time to fade out the volume before stopping")
+	quit := fs.Bool("quit", false, "stops "+config.ProgName+" instead of stopping the playback")
+	if errPf := parseFlags(fs, args[1:]); errPf != nil {
+		return "", errPf
+	}
+	if fs.NArg() > 0 {
+		return "", fmt.Errorf("sleep: error: unexpected argument '%s'\n", fs.Arg(0))
+	}
+	if *fade < 0 {
+		return "", fmt.Errorf("sleep: error: the fade time cannot be negative\n")
+	}
+	if *fade > duration {
+		*fade = duration
+	}
+	timer := sleepTimer{End: time.Now().Add(duration), Fade: fade.Seconds(), Quit: *quit}
+	mainSleeper.set(timer)
+	action := "stopping the playback"
+	if timer.Quit {
+		action = "stopping " + config.ProgName
+	}
+	return fmt.Sprintf(
+		"sleep: info: %s at %s, fading out the last %s\n", action, timer.End.Format("15:04:05"), *fade,
+	), nil
+}
+
+// parseSleep returns the sleep duration, a number without unit is in minutes
+func parseSleep(str string) (time.Duration, error) {
+	if num, errSa := strconv.Atoi(str); errSa == nil && num > 0 {
+		return time.Duration(num) * time.Minute, nil
+	}
+	duration, errPd := time.ParseDuration(str)
+	if errPd != nil || duration <= 0 {
+		return 0, fmt.Errorf("sleep: error: '%s' is not a duration (30m, 1h30m)\n", str)
+	}
+	return duration, nil
+}
+
+// sleepRemaining returns the remaining time of the sleep timer
+func sleepRemaining() (time.Duration, bool) {
+	mainSleeper.mu.Lock()
+	end := mainSleeper.timer.End
+	mainSleeper.mu.Unlock()
+	if end.IsZero() {
+		return 0, false
+	}
+	remaining := time.Until(end).Round(time.Second)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, true
+}
+
+// set replaces the sleep timer
+func (s *sleeper) set(timer sleepTimer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timer = timer
+	s.arm(time.Now())
+}
+
+// cancel removes the sleep timer and restores the volume
+func (s *sleeper) cancel() error {
+	s.mu.Lock()
+	if s.timer.End.IsZero() {
+		s.mu.Unlock()
+		return fmt.Errorf("sleep: error: no sleep timer\n")
+	}
+	s.timer = sleepTimer{}
+	s.arm(time.Now())
+	s.mu.Unlock()
+	s.act.Lock()
+	defer s.act.Unlock()
+	return s.restore()
+}
+
+// arm wakes the sleeper at the fade out start, at every volume step and at the end, s.mu must be held
+func (s *sleeper) arm(now time.Time) {
+	if s.wake != nil {
+		s.wake.Stop()
+		s.wake = nil
+	}
+	if !s.running || s.timer.End.IsZero() {
+		return
+	}
+	remaining := s.timer.End.Sub(now)
+	delay := remaining - time.Duration(s.timer.Fade*float64(time.Second))
+	if delay <= 0 {
+		delay = sleepInterval
+		if remaining < delay {
+			delay = remaining
+		}
+	}
+	s.wake = time.AfterFunc(delay, func() {
+		if errTi := s.tick(time.Now()); errTi != nil {
+			log.Print(errTi)
+		}
+		s.mu.Lock()
+		s.arm(time.Now())
+		s.mu.Unlock()
+	})
+}
+
+// tick fades out the volume near the sleep timer end and stops when it is reached, restoring the volume
+func (s *sleeper) tick(now time.Time) error {
+	s.act.Lock()
+	s.mu.Lock()
+	timer := s.timer
+	if !timer.End.IsZero() && !timer.End.After(now) {
+		s.timer = sleepTimer{}
+	}
+	s.mu.Unlock()
+	quit, errSt := s.step(timer, now)
+	s.act.Unlock()
+	if quit {
+		return Stop()
+	}
+	return errSt
+}
+
+// step runs the tick of the timer, returns true when the program must stop, s.act must be held
+func (s *sleeper) step(timer sleepTimer, now time.Time) (bool, error) {
+	if timer.End.IsZero() {
+		return false, s.restore()
+	}
+	remaining := timer.End.Sub(now)
+	if remaining <= 0 {
+		defer notifyControl()
+		if timer.Quit {
+			log.Printf("sleep: info: stopping '%s'\n", config.ProgName)
+			if errRe := s.restore(); errRe != nil {
+				log.Print(errRe)
+			}
+			return true, nil
+		}
+		log.Print("sleep: info: stopping the playback\n")
+		if errPs := PlayStop(); errPs != nil {
+			log.Print(errPs)
+		}
+		return false, s.restore()
+	}
+	fade := time.Duration(timer.Fade * float64(time.Second))
+	if remaining > fade {
+		return false, s.restore()
+	}
+	if !s.fading {
+		volume, errSv := systemVolume()
+		if errSv != nil {
+			return false, errSv
+		}
+		s.fading, s.volume = true, volume
+		log.Printf("sleep: info: fading out the volume %d in %s\n", volume, remaining.Round(time.Second))
+	}
+	num := int(math.Round(float64(s.volume) * remaining.Seconds() / fade.Seconds()))
+	if num < config.VolumeMin {
+		num = config.VolumeMin
+	}
+	return false, Volume(num)
+}
+
+// restore restores the volume before the fade out, s.act must be held
+func (s *sleeper) restore() error {
+	if !s.fading {
+		return nil
+	}
+	s.fading = false
+	log.Printf("sleep: info: restoring the volume %d\n", s.volume)
+	return Volume(s.volume)
+}
+
+// systemVolume returns the current system volume
+func systemVolume() (int, error) {
+	cli, errPc := playerClient()
+	if errPc != nil {
+		return 0, errPc
+	}
+	ctx, cancel := playerContext()
+	defer cancel()
+	volume, errGf := cli.GetFloat(ctx, "ao-volume")
+	if errGf != nil {
+		return 0, errGf
+	}
+	return int(math.Round(volume)), nil
+}
+
+// startSleeper runs the sleep timer of the main program
+func startSleeper() {
+	mainSleeper.mu.Lock()
+	defer mainSleeper.mu.Unlock()
+	mainSleeper.running = true
+	mainSleeper.arm(time.Now())
+}
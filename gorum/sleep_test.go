@@ -0,0 +1,114 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSleep(t *testing.T) {
+	srv := setUpTest(t)
+	t.Cleanup(func() {
+		// stops the sleeper without replacing its mutexes, a wake up may still be running
+		s := &mainSleeper
+		s.mu.Lock()
+		s.running, s.timer = false, sleepTimer{}
+		s.arm(time.Now())
+		s.mu.Unlock()
+		s.act.Lock()
+		s.fading = false
+		s.act.Unlock()
+	})
+	if err := PlayWait("1", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := Sleep(nil); err != nil || content != "sleep: info: no sleep timer\n" {
+		t.Fatalf("unexpected sleep %q %v", content, err)
+	}
+	for _, args := range [][]string{{"0"}, {"soon"}, {"10m", "--fade", "-1s"}, {"10m", "extra"}, {"cancel"}} {
+		if _, err := Sleep(args); err == nil {
+			t.Errorf("expected error for %q", args)
+		}
+	}
+	if _, err := Sleep([]string{"10", "--fade", "1m"}); err != nil {
+		t.Fatal(err)
+	}
+	content, errSt := Status()
+	if errSt != nil {
+		t.Fatal(errSt)
+	}
+	if !strings.Contains(content, "sleep: 10m0s\n") && !strings.Contains(content, "sleep: 9m59s\n") {
+		t.Fatalf("expected sleep remaining time in status:\n%s", content)
+	}
+	s := &mainSleeper
+	timer := s.timer
+	if timer.Fade != 60 || timer.Quit || s.wake != nil {
+		t.Fatalf("unexpected timer %+v", timer)
+	}
+
+	for _, step := range []struct {
+		before time.Duration
+		volume float64
+	}{
+		{time.Minute + time.Second, 100},
+		{30 * time.Second, 50},
+		{15 * time.Second, 25},
+	} {
+		if err := s.tick(timer.End.Add(-step.before)); err != nil {
+			t.Fatal(err)
+		}
+		if volume := srv.Get("ao-volume"); volume != step.volume {
+			t.Fatalf("%s before the end: got volume %v, want %v", step.before, volume, step.volume)
+		}
+	}
+	if err := s.tick(timer.End.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if idle := srv.Get("idle-active"); idle != true {
+		t.Fatalf("expected playback stopped, idle %v", idle)
+	}
+	if volume := srv.Get("ao-volume"); volume != float64(100) {
+		t.Fatalf("expected volume restored, got %v", volume)
+	}
+	if _, ok := sleepRemaining(); ok {
+		t.Fatal("expected sleep timer removed")
+	}
+
+	if _, err := Sleep([]string{"1m", "--fade", "1m"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.tick(time.Now().Add(30 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if volume := srv.Get("ao-volume"); volume == float64(100) {
+		t.Fatal("expected volume fading out")
+	}
+	if _, err := Sleep([]string{"cancel"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.tick(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if volume := srv.Get("ao-volume"); volume != float64(100) {
+		t.Fatalf("expected volume restored after cancel, got %v", volume)
+	}
+
+	// the running sleeper wakes up by itself at the end
+	if err := PlayWait("1", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	startSleeper()
+	if _, err := Sleep([]string{"200ms", "--fade", "100ms"}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the sleep timer end", func() bool {
+		_, ok := sleepRemaining()
+		return !ok && srv.Get("idle-active") == true
+	})
+	if volume := srv.Get("ao-volume"); volume != float64(100) {
+		t.Fatalf("expected volume restored, got %v", volume)
+	}
+}
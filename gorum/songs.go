@@ -0,0 +1,159 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+)
+
+// songEntry data type
+type songEntry struct {
+	Station int       `json:"station,omitempty"`
+	Name    string    `json:"name"`
+	Title   string    `json:"title"`
+	Path    string    `json:"path"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+}
+
+// loadSongs loads the song log entries, the most recent first
+func loadSongs() ([]songEntry, error) {
+	var entries []songEntry
+	errLs := config.LoadStateLines(config.SongsFile, func(data []byte) error {
+		var entry songEntry
+		if errJu := json.Unmarshal(data, &entry); errJu != nil {
+			return errJu
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if errLs != nil {
+		return nil, errLs
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+// Songs lists the song log entries matching the case insensitive pattern with their station name or title
+func Songs(args []string) (string, error) {
+	if len(args) > 0 && args[0] == "grep" {
+		args = args[1:]
+	}
+	fs := flag.NewFlagSet("songs", flag.ContinueOnError)
+	since := fs.String("since", "", "songs played since a duration ago (30m, 24h, 7d) or a date (2006-01-02 15:04)")
+	until := fs.String("until", "", "songs played until a duration ago (30m, 24h, 7d) or a date (2006-01-02 15:04)")
+	station := fs.Int("station", 0, "songs of the station id")
+	asJson := fs.Bool("json", false, "prints the songs as json")
+	if errPf := parseFlags(fs, args); errPf != nil {
+		return "", errPf
+	}
+	match := ""
+	if fs.NArg() > 0 {
+		// the flags may follow the pattern too
+		match = fs.Arg(0)
+		if errPf := parseFlags(fs, fs.Args()[1:]); errPf != nil {
+			return "", errPf
+		}
+	}
+	if fs.NArg() > 0 {
+		return "", fmt.Errorf("songs: error: unexpected argument '%s'\n", fs.Arg(0))
+	}
+	var (
+		sinceTime time.Time
+		untilTime = time.Now()
+		pattern   *regexp.Regexp
+	)
+	if *since != "" {
+		var errPs error
+		if sinceTime, errPs = parseSince(*since, time.Now()); errPs != nil {
+			return "", errPs
+		}
+	}
+	if *until != "" {
+		var errPs error
+		if untilTime, errPs = parseSince(*until, time.Now()); errPs != nil {
+			return "", errPs
+		}
+	}
+	if match != "" {
+		var errRc error
+		if pattern, errRc = regexp.Compile("(?i)" + match); errRc != nil {
+			return "", fmt.Errorf("songs: error: invalid pattern '%s': %s\n", match, errRc.Error())
+		}
+	}
+	entries, errLs := loadSongs()
+	if errLs != nil {
+		return "", errLs
+	}
+	selected := make([]songEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.End.Before(sinceTime) || entry.Start.After(untilTime) {
+			continue
+		}
+		if *station != 0 && entry.Station != *station {
+			continue
+		}
+		if pattern != nil && !pattern.MatchString(entry.Name) && !pattern.MatchString(entry.Title) {
+			continue
+		}
+		selected = append(selected, entry)
+	}
+	if *asJson {
+		data, errJm := json.MarshalIndent(selected, "", "    ")
+		if errJm != nil {
+			return "", errJm
+		}
+		return string(data) + "\n", nil
+	}
+	return songsList(selected)
+}
+
+// songsList returns the song log entries as aligned text lines
+func songsList(entries []songEntry) (string, error) {
+	var list bytes.Buffer
+	tw := tabwriter.NewWriter(&list, 0, 0, 2, ' ', 0)
+	for _, entry := range entries {
+		line := fmt.Sprintf(
+			"%s-%s\t%s\t%s\n", entry.Start.Format("2006-01-02 15:04"), entry.End.Format("15:04"), entry.Name, entry.Title,
+		)
+		if _, errTw := tw.Write([]byte(line)); errTw != nil {
+			return "", errTw
+		}
+	}
+	if errTf := tw.Flush(); errTf != nil {
+		return "", errTf
+	}
+	return list.String(), nil
+}
+
+// stationName returns the station id and name of the stream url, using the icy name or the url otherwise
+func stationName(path string, meta map[string]interface{}) (int, string) {
+	if id, name := stationOf(path); id != 0 {
+		return id, name
+	}
+	if name, ok := meta["icy-name"].(string); ok && strings.TrimSpace(name) != "" {
+		return 0, strings.TrimSpace(name)
+	}
+	return 0, path
+}
+
+// songIcyTitle returns the trimmed icy title of the metadata
+func songIcyTitle(meta map[string]interface{}) string {
+	title, _ := meta["icy-title"].(string)
+	return strings.TrimSpace(title)
+}
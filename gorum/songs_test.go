@@ -0,0 +1,90 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+	"github.com/gonzaru/gorum/mpv"
+)
+
+func TestSongs(t *testing.T) {
+	srv := setUpTest(t)
+	cli, errMd := mpv.Dial(config.PlayerControlFile)
+	if errMd != nil {
+		t.Fatal(errMd)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- watchEvents(cli)
+	}()
+	waitFor(t, "observed properties", func() bool {
+		return len(srv.Commands()) >= len(observedProperties)
+	})
+	if err := PlayWait("2", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	for _, title := range []string{"Artist - One", "Artist - One", " Artist - One ", "Other - Two"} {
+		srv.Set("metadata", map[string]interface{}{"icy-title": title, "icy-name": "Icy Name"})
+	}
+	waitFor(t, "first song", func() bool {
+		entries, _ := loadSongs()
+		return len(entries) == 1
+	})
+	if err := PlayWait("https://example.org/unknown", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	srv.Set("metadata", map[string]interface{}{"icy-title": "Goa - Three", "icy-name": "Goa Base"})
+	waitFor(t, "last song title", func() bool {
+		return wmFileContent() == "Goa - Three\n"
+	})
+	cli.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	entries, errLs := loadSongs()
+	if errLs != nil {
+		t.Fatal(errLs)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("unexpected songs %+v", entries)
+	}
+	if entries[0].Name != "Goa Base" || entries[0].Station != 0 || entries[2].Station != 2 || entries[2].Name != testStation(2)["name"] {
+		t.Fatalf("unexpected song stations %+v", entries)
+	}
+	content, errSo := Songs([]string{"grep", "--since", "1h", "goa"})
+	if errSo != nil {
+		t.Fatal(errSo)
+	}
+	if strings.Count(content, "\n") != 1 || !strings.Contains(content, "Goa Base  Goa - Three") {
+		t.Fatalf("unexpected songs grep:\n%s", content)
+	}
+	// the flags may follow the pattern too
+	content, errSo = Songs([]string{"goa", "--since", "1d", "--json"})
+	if errSo != nil || !strings.HasPrefix(content, "[") || !strings.Contains(content, "Goa - Three") || strings.Contains(content, "Artist - One") {
+		t.Fatalf("unexpected songs with the flags after the pattern %q %v", content, errSo)
+	}
+	if _, err := Songs([]string{"goa", "--since", "1d", "extra"}); err == nil || !strings.Contains(err.Error(), "unexpected argument 'extra'") {
+		t.Fatalf("expected an unexpected argument error, got %v", err)
+	}
+	content, errSo = Songs([]string{"--station", "2"})
+	if errSo != nil {
+		t.Fatal(errSo)
+	}
+	if strings.Count(content, "\n") != 2 || !strings.Contains(content, "Artist - One") {
+		t.Fatalf("unexpected station songs:\n%s", content)
+	}
+	if content, _ := Songs([]string{"--until", "2000-01-01"}); content != "" {
+		t.Fatalf("expected no songs, got:\n%s", content)
+	}
+	if _, err := Songs([]string{"("}); err == nil {
+		t.Fatal("expected error for an invalid pattern")
+	}
+}
@@ -0,0 +1,172 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+	"github.com/gonzaru/gorum/utils"
+)
+
+// playerSnapshot data type, the media player state restored after a crash
+type playerSnapshot struct {
+	path      string
+	volume    float64
+	hasVolume bool
+	mute      bool
+	pause     bool
+}
+
+// crashLoop data type, the recent media player crashes
+type crashLoop struct {
+	crashes []time.Time
+	delay   time.Duration
+}
+
+const (
+	// restartDelay the time before restarting the media player after its first crash
+	restartDelay = time.Second
+
+	// restartMaxDelay the maximum time before restarting the media player
+	restartMaxDelay = 30 * time.Second
+
+	// restartWindow the time during which the media player crashes are counted
+	restartWindow = 10 * time.Minute
+)
+
+var (
+	// snapshot the last known media player state
+	snapshot   playerSnapshot
+	snapshotMu sync.Mutex
+)
+
+// crash records the media player crash and returns the delay before restarting it, false when it crashes too often
+func (c *crashLoop) crash(now time.Time) (time.Duration, bool) {
+	recent := c.crashes[:0]
+	for _, crashed := range c.crashes {
+		if now.Sub(crashed) < restartWindow {
+			recent = append(recent, crashed)
+		}
+	}
+	c.crashes = append(recent, now)
+	if len(c.crashes) > config.PlayerRestartMax {
+		return 0, false
+	}
+	if len(c.crashes) == 1 {
+		c.delay = restartDelay
+	} else if c.delay *= 2; c.delay > restartMaxDelay {
+		c.delay = restartMaxDelay
+	}
+	return c.delay, true
+}
+
+// currentSnapshot returns the last known media player state
+func currentSnapshot() playerSnapshot {
+	snapshotMu.Lock()
+	defer snapshotMu.Unlock()
+	return snapshot
+}
+
+// playerCrashed checks if the media player ended abnormally instead of quitting or being stopped
+func playerCrashed(state *os.ProcessState) bool {
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok {
+		return !state.Success()
+	}
+	if status.Signaled() {
+		switch status.Signal() {
+		case syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGHUP:
+			return false
+		}
+		return true
+	}
+	// mpv exits with 4 when it quits because of a signal
+	return status.ExitStatus() != 0 && status.ExitStatus() != 4
+}
+
+// restorePlayer loads the path again and restores the volume, mute and pause state of the snapshot
+func restorePlayer(snap playerSnapshot) error {
+	if snap.path != "" {
+		log.Printf("start: info: restoring '%s'\n", snap.path)
+		var errPw error
+		if utils.ValidUrl(snap.path) {
+			errPw = PlayWait(snap.path, config.PlayTimeout)
+		} else {
+			errPw = PlayResume(snap.path, config.PlayTimeout)
+		}
+		if errPw != nil {
+			return errPw
+		}
+	}
+	cli, errPc := playerClient()
+	if errPc != nil {
+		return errPc
+	}
+	ctx, cancel := playerContext()
+	defer cancel()
+	if snap.hasVolume {
+		if errSp := cli.SetProperty(ctx, "ao-volume", int(math.Round(snap.volume))); errSp != nil {
+			log.Print(errSp)
+		}
+	}
+	if errSp := cli.SetProperty(ctx, "mute", snap.mute); errSp != nil {
+		return errSp
+	}
+	if errSp := cli.SetProperty(ctx, "pause", snap.pause); errSp != nil {
+		return errSp
+	}
+	return nil
+}
+
+// startPlayer starts the media player process, its output is read from the returned pipe
+func startPlayer() (*exec.Cmd, io.ReadCloser, error) {
+	if errOr := os.Remove(config.PlayerControlFile); errOr != nil && !errors.Is(errOr, os.ErrNotExist) {
+		return nil, nil, errOr
+	}
+	args := config.PlayerArgs
+	if config.AudioDevice != "" {
+		args = append(append([]string{}, args...), "--audio-device="+config.AudioDevice)
+	}
+	cmd := exec.Command(config.Player, args...)
+	stdout, errSp := cmd.StdoutPipe()
+	if errSp != nil {
+		return nil, nil, errSp
+	}
+	cmd.Stderr = cmd.Stdout
+	if errCs := cmd.Start(); errCs != nil {
+		return nil, nil, errCs
+	}
+	if errCp := os.WriteFile(config.PlayerPidFile, []byte(strconv.Itoa(cmd.Process.Pid)+"\n"), 0600); errCp != nil {
+		return nil, nil, errCp
+	}
+	msg := fmt.Sprintf(
+		"start: info: %s pid: %d, %s pid: %d\n", config.ProgName, os.Getpid(), config.Player, cmd.Process.Pid,
+	)
+	fmt.Print(msg)
+	log.Print(msg)
+	log.Printf("start: info: run %s\n", strings.Join(cmd.Args, " "))
+	return cmd, stdout, nil
+}
+
+// updateSnapshot changes the last known media player state
+func updateSnapshot(fn func(snap *playerSnapshot)) {
+	snapshotMu.Lock()
+	defer snapshotMu.Unlock()
+	fn(&snapshot)
+}
@@ -0,0 +1,118 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package gorum
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+	"github.com/gonzaru/gorum/mpv"
+)
+
+func TestPlayerCrashed(t *testing.T) {
+	tests := []struct {
+		script  string
+		crashed bool
+	}{
+		{"exit 0", false},
+		{"exit 4", false},
+		{"kill -TERM $$", false},
+		{"exit 1", true},
+		{"kill -SEGV $$", true},
+		{"kill -KILL $$", true},
+	}
+	for _, test := range tests {
+		cmd := exec.Command("sh", "-c", test.script)
+		_ = cmd.Run()
+		if crashed := playerCrashed(cmd.ProcessState); crashed != test.crashed {
+			t.Errorf("%q: expected crashed %v, got %v", test.script, test.crashed, crashed)
+		}
+	}
+}
+
+func TestCrashLoop(t *testing.T) {
+	restartMax := config.PlayerRestartMax
+	t.Cleanup(func() {
+		config.PlayerRestartMax = restartMax
+	})
+	config.PlayerRestartMax = 3
+	var loop crashLoop
+	now := time.Now()
+	for num, want := range []time.Duration{restartDelay, 2 * restartDelay, 4 * restartDelay} {
+		delay, ok := loop.crash(now.Add(time.Duration(num) * time.Second))
+		if !ok || delay != want {
+			t.Fatalf("crash %d: expected restart in %s, got %s %v", num+1, want, delay, ok)
+		}
+	}
+	if _, ok := loop.crash(now.Add(3 * time.Second)); ok {
+		t.Fatal("expected giving up after too many crashes")
+	}
+
+	// the old crashes are forgotten
+	loop = crashLoop{}
+	for num := 0; num < 10; num++ {
+		delay, ok := loop.crash(now.Add(time.Duration(num) * restartWindow))
+		if !ok || delay != restartDelay {
+			t.Fatalf("crash %d: expected restart in %s, got %s %v", num+1, restartDelay, delay, ok)
+		}
+	}
+	loop = crashLoop{}
+	for num := 0; num < 10; num++ {
+		if delay, _ := loop.crash(now); delay > restartMaxDelay {
+			t.Fatalf("expected delay up to %s, got %s", restartMaxDelay, delay)
+		}
+	}
+}
+
+func TestRestorePlayer(t *testing.T) {
+	srv := setUpTest(t)
+	cli, errMd := mpv.Dial(config.PlayerControlFile)
+	if errMd != nil {
+		t.Fatal(errMd)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- watchEvents(cli)
+	}()
+	waitFor(t, "observed properties", func() bool {
+		return len(srv.Commands()) >= len(observedProperties)
+	})
+	if err := PlayWait("https://example.org/stream", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	srv.Set("ao-volume", float64(40))
+	srv.Set("mute", true)
+	srv.Set("pause", true)
+	waitFor(t, "player snapshot", func() bool {
+		snap := currentSnapshot()
+		return snap.path == "https://example.org/stream" && snap.volume == 40 && snap.hasVolume && snap.mute && snap.pause
+	})
+	cli.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	snap := currentSnapshot()
+
+	// a restarted player starts idle with the default state
+	if err := PlayStop(); err != nil {
+		t.Fatal(err)
+	}
+	srv.Set("ao-volume", float64(100))
+	srv.Set("mute", false)
+	srv.Set("pause", false)
+	if err := restorePlayer(snap); err != nil {
+		t.Fatal(err)
+	}
+	if playlist := srv.Playlist(); len(playlist) != 1 || playlist[0] != "https://example.org/stream" {
+		t.Fatalf("expected the stream restored, got %q", playlist)
+	}
+	if volume, mute, pause := srv.Get("ao-volume"), srv.Get("mute"), srv.Get("pause"); volume != float64(40) || mute != true || pause != true {
+		t.Fatalf("unexpected restored state volume %v mute %v pause %v", volume, mute, pause)
+	}
+}
@@ -0,0 +1,121 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package icy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Conn data type, a connection that reads the shoutcast "ICY" status line as "HTTP/1.0"
+type Conn struct {
+	net.Conn
+	checked bool
+	pending []byte
+}
+
+// Read reads from the connection replacing the shoutcast status line protocol
+func (c *Conn) Read(p []byte) (int, error) {
+	if !c.checked {
+		c.checked = true
+		head := make([]byte, 4)
+		num, errRf := io.ReadFull(c.Conn, head)
+		if num == 0 {
+			return 0, errRf
+		}
+		c.pending = head[:num]
+		if bytes.Equal(c.pending, []byte("ICY ")) {
+			c.pending = []byte("HTTP/1.0 ")
+		}
+	}
+	if len(c.pending) > 0 {
+		num := copy(p, c.pending)
+		c.pending = c.pending[num:]
+		return num, nil
+	}
+	return c.Conn.Read(p)
+}
+
+// Client returns an http client understanding the shoutcast responses
+func Client(timeout time.Duration) *http.Client {
+	return newClient(timeout, &tls.Config{})
+}
+
+// newClient returns an http client understanding the shoutcast responses, its https connections use tlsConfig
+func newClient(timeout time.Duration, tlsConfig *tls.Config) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, errDc := dialer.DialContext(ctx, network, addr)
+			if errDc != nil {
+				return nil, errDc
+			}
+			return &Conn{Conn: conn}, nil
+		},
+		// the status line is replaced after the handshake, it is encrypted in the raw connection
+		DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, errDc := dialer.DialContext(ctx, network, addr)
+			if errDc != nil {
+				return nil, errDc
+			}
+			config := tlsConfig.Clone()
+			if config.ServerName == "" {
+				host, _, errSh := net.SplitHostPort(addr)
+				if errSh != nil {
+					host = addr
+				}
+				config.ServerName = host
+			}
+			tlsConn := tls.Client(conn, config)
+			ctxHandshake, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			if errTh := tlsConn.HandshakeContext(ctxHandshake); errTh != nil {
+				conn.Close()
+				return nil, errTh
+			}
+			return &Conn{Conn: tlsConn}, nil
+		},
+		DisableKeepAlives:     true,
+		ResponseHeaderTimeout: timeout,
+	}
+	return &http.Client{Transport: transport}
+}
+
+// ReadMeta reads the metadata block following an audio chunk
+func ReadMeta(rd *bufio.Reader) ([]byte, error) {
+	size, errRb := rd.ReadByte()
+	if errRb != nil {
+		return nil, errRb
+	}
+	meta := make([]byte, int(size)*16)
+	if _, errRf := io.ReadFull(rd, meta); errRf != nil {
+		return nil, errRf
+	}
+	return meta, nil
+}
+
+// StreamTitle returns the stream title of the metadata block
+func StreamTitle(meta []byte) (string, bool) {
+	const key = "StreamTitle='"
+	text := strings.TrimRight(string(meta), "\x00")
+	start := strings.Index(text, key)
+	if start < 0 {
+		return "", false
+	}
+	text = text[start+len(key):]
+	if end := strings.Index(text, "';"); end >= 0 {
+		text = text[:end]
+	} else {
+		text = strings.TrimSuffix(text, "'")
+	}
+	return strings.TrimSpace(text), true
+}
@@ -0,0 +1,106 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package icy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamTitle(t *testing.T) {
+	for meta, want := range map[string]string{
+		"StreamTitle='It's Me - Song';StreamUrl='';\x00\x00": "It's Me - Song",
+		"StreamTitle=' Spaced ';":                            "Spaced",
+		"StreamTitle='';":                                    "",
+	} {
+		if title, ok := StreamTitle([]byte(meta)); !ok || title != want {
+			t.Errorf("StreamTitle(%q) = %q, want %q", meta, title, want)
+		}
+	}
+	if _, ok := StreamTitle([]byte("StreamUrl='';")); ok {
+		t.Error("expected no stream title")
+	}
+}
+
+func TestReadMeta(t *testing.T) {
+	meta := "StreamTitle='Song';"
+	block := string(rune(2)) + meta + strings.Repeat("\x00", 32-len(meta))
+	rd := bufio.NewReader(strings.NewReader(block + "\x00rest"))
+	got, errRm := ReadMeta(rd)
+	if errRm != nil {
+		t.Fatal(errRm)
+	}
+	if title, _ := StreamTitle(got); len(got) != 32 || title != "Song" {
+		t.Fatalf("unexpected metadata block %q", got)
+	}
+	if got, errRm = ReadMeta(rd); errRm != nil || len(got) != 0 {
+		t.Fatalf("expected an empty metadata block, got %q %v", got, errRm)
+	}
+	if _, errRm = ReadMeta(bufio.NewReader(strings.NewReader("\x01short"))); errRm == nil {
+		t.Fatal("expected error reading a truncated metadata block")
+	}
+}
+
+func TestClientShoutcast(t *testing.T) {
+	ln, errNl := net.Listen("tcp", "127.0.0.1:0")
+	if errNl != nil {
+		t.Fatal(errNl)
+	}
+	defer ln.Close()
+	go func() {
+		conn, errLa := ln.Accept()
+		if errLa != nil {
+			return
+		}
+		defer conn.Close()
+		bufio.NewReader(conn).ReadString('\n')
+		fmt.Fprint(conn, "ICY 200 OK\r\nicy-name: Old Radio\r\n\r\naudio")
+	}()
+	resp, errCg := Client(time.Second).Get("http://" + ln.Addr().String() + "/")
+	if errCg != nil {
+		t.Fatal(errCg)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("icy-name") != "Old Radio" {
+		t.Fatalf("unexpected response %s %v", resp.Status, resp.Header)
+	}
+}
+
+func TestClientShoutcastTls(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, errHh := w.(http.Hijacker).Hijack()
+		if errHh != nil {
+			t.Error(errHh)
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(rw, "ICY 200 OK\r\nicy-name: Secure Radio\r\n\r\naudio")
+		rw.Flush()
+	}))
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+	tlsConfig := srv.Client().Transport.(*http.Transport).TLSClientConfig
+	resp, errCg := newClient(time.Second, tlsConfig).Get(srv.URL + "/")
+	if errCg != nil {
+		t.Fatal(errCg)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("icy-name") != "Secure Radio" {
+		t.Fatalf("unexpected response %s %v", resp.Status, resp.Header)
+	}
+
+	// a certificate that is not trusted is refused
+	if _, err := Client(time.Second).Get(srv.URL + "/"); err == nil {
+		t.Fatal("expected error for an untrusted certificate")
+	}
+}
@@ -0,0 +1,79 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package configtest
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+)
+
+// Paths returns the config path variables by name, UseDirs saves and restores all of them
+func Paths() map[string]*string {
+	return map[string]*string{
+		"Profile":           &config.Profile,
+		"ProfileConfigFile": &config.ProfileConfigFile,
+		"ConfigDir":         &config.ConfigDir,
+		"ConfigFile":        &config.ConfigFile,
+		"StationsFile":      &config.StationsFile,
+		"RuntimeDir":        &config.RuntimeDir,
+		"ControlFile":       &config.ControlFile,
+		"LockFile":          &config.LockFile,
+		"Log":               &config.Log,
+		"PidFile":           &config.PidFile,
+		"PlayerControlFile": &config.PlayerControlFile,
+		"PlayerPidFile":     &config.PlayerPidFile,
+		"ReconnectFile":     &config.ReconnectFile,
+		"WmFile":            &config.WmFile,
+		"DataDir":           &config.DataDir,
+		"HistoryFile":       &config.HistoryFile,
+		"ModesFile":         &config.ModesFile,
+		"RecordDir":         &config.RecordDir,
+		"ResumeFile":        &config.ResumeFile,
+		"ScheduleFile":      &config.ScheduleFile,
+		"ScheduleStateFile": &config.ScheduleStateFile,
+		"SongsFile":         &config.SongsFile,
+	}
+}
+
+// UseDirs moves the runtime files into runtime and the state files into data, the returned function restores the previous files
+func UseDirs(runtime string, data string) func() {
+	paths := Paths()
+	saved := make(map[string]string, len(paths))
+	for name, path := range paths {
+		saved[name] = *path
+	}
+	playerArgs := config.PlayerArgs
+	config.RuntimeDir = runtime
+	moveFiles(runtime, &config.ControlFile, &config.LockFile, &config.Log, &config.PidFile,
+		&config.PlayerControlFile, &config.PlayerPidFile, &config.ReconnectFile, &config.WmFile)
+	config.DataDir = data
+	moveFiles(data, &config.HistoryFile, &config.ModesFile, &config.RecordDir, &config.ResumeFile,
+		&config.ScheduleFile, &config.ScheduleStateFile, &config.SongsFile)
+	args := make([]string, 0, len(playerArgs))
+	for _, arg := range playerArgs {
+		if strings.HasPrefix(arg, "--input-ipc-server=") {
+			arg = "--input-ipc-server=" + config.PlayerControlFile
+		}
+		args = append(args, arg)
+	}
+	config.PlayerArgs = args
+	return func() {
+		for name, path := range paths {
+			*path = saved[name]
+		}
+		config.PlayerArgs = playerArgs
+	}
+}
+
+// moveFiles moves the files into the directory keeping their names
+func moveFiles(dir string, files ...*string) {
+	for _, file := range files {
+		*file = filepath.Join(dir, filepath.Base(*file))
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
 )
 
 // local packages
@@ -21,6 +22,26 @@ import (
 
 // main options
 func main() {
+	args := os.Args[1:]
+	if len(args) > 0 && (args[0] == "--profile" || strings.HasPrefix(args[0], "--profile=")) {
+		name := strings.TrimPrefix(args[0], "--profile=")
+		args = args[1:]
+		if name == "--profile" {
+			if len(args) == 0 {
+				gorum.Help()
+				os.Exit(1)
+			}
+			name, args = args[0], args[1:]
+		}
+		if errSp := config.SetProfile(name); errSp != nil {
+			utils.ErrPrint(errSp)
+			os.Exit(1)
+		}
+	}
+	if errCl := config.Load(); errCl != nil {
+		utils.ErrPrint(errCl)
+		os.Exit(1)
+	}
 	if errSl := gorum.SetLog(); errSl != nil {
 		utils.ErrPrint(errSl)
 		log.Fatal(errSl)
@@ -29,7 +50,6 @@ func main() {
 		utils.ErrPrint(errCo)
 		log.Fatal(errCo)
 	}
-	args := os.Args[1:]
 	lenArgs := len(args)
 	if lenArgs == 0 {
 		gorum.Help()
@@ -37,13 +57,42 @@ func main() {
 	}
 	arg := args[0]
 	switch arg {
+	case "alarm", "history", "profiles", "record", "schedule", "songs", "stations":
+		content, err := gorum.ControlOutput(arg, args[1:]...)
+		fmt.Print(content)
+		if err != nil {
+			utils.ErrPrint(err)
+			log.Fatal(err)
+		}
 	case "check":
 		if !gorum.IsRunning() {
 			utils.ErrPrintf("main: error: '%s' is not running\n", config.ProgName)
 			os.Exit(1)
 		}
+	case "enqueue":
+		if len(args) != 2 {
+			gorum.Help()
+			os.Exit(1)
+		}
+		if err := gorum.Control(nil, "enqueue", args[1]); err != nil {
+			utils.ErrPrint(err)
+			log.Fatal(err)
+		}
 	case "help":
 		gorum.Help()
+	case "jump":
+		if len(args) != 2 {
+			gorum.Help()
+			os.Exit(1)
+		}
+		if _, errSa := strconv.Atoi(args[1]); errSa != nil {
+			utils.ErrPrint(errSa)
+			log.Fatal(errSa)
+		}
+		if errJu := gorum.Control(nil, "jump", args[1]); errJu != nil {
+			utils.ErrPrint(errJu)
+			log.Fatal(errJu)
+		}
 	case "menu":
 		go menu.SignalHandler()
 		if errMe := menu.Menu(); errMe != nil {
@@ -51,7 +100,33 @@ func main() {
 			log.Fatal(errMe)
 		}
 	case "mute", "pause", "video":
-		if err := gorum.Toggle(arg); err != nil {
+		if err := gorum.Control(nil, arg); err != nil {
+			utils.ErrPrint(err)
+			log.Fatal(err)
+		}
+	case "next":
+		if err := gorum.Control(nil, "next"); err != nil {
+			utils.ErrPrint(err)
+			log.Fatal(err)
+		}
+	case "prev":
+		if err := gorum.Control(nil, "prev"); err != nil {
+			utils.ErrPrint(err)
+			log.Fatal(err)
+		}
+	case "queue":
+		var content string
+		if err := gorum.Control(&content, "queue", args[1:]...); err != nil {
+			utils.ErrPrint(err)
+			log.Fatal(err)
+		}
+		fmt.Print(content)
+	case "repeat":
+		if len(args) > 2 {
+			gorum.Help()
+			os.Exit(1)
+		}
+		if err := gorum.Control(nil, "repeat", args[1:]...); err != nil {
 			utils.ErrPrint(err)
 			log.Fatal(err)
 		}
@@ -66,15 +141,22 @@ func main() {
 			gorum.Help()
 			os.Exit(1)
 		}
-		secondsInt, errSa := strconv.Atoi(secondsStr)
-		if errSa != nil {
-			utils.ErrPrint(errSa)
-			log.Fatal(errSa)
-		}
-		if errSe := gorum.Seek(secondsInt); errSe != nil {
+		if errSe := gorum.Control(nil, "seek", secondsStr); errSe != nil {
 			utils.ErrPrint(errSe)
 			log.Fatal(errSe)
 		}
+	case "shuffle":
+		if err := gorum.Control(nil, "shuffle"); err != nil {
+			utils.ErrPrint(err)
+			log.Fatal(err)
+		}
+	case "sleep":
+		var content string
+		if err := gorum.Control(&content, "sleep", args[1:]...); err != nil {
+			utils.ErrPrint(err)
+			log.Fatal(err)
+		}
+		fmt.Print(content)
 	case "start":
 		go gorum.SignalHandler()
 		if err := gorum.Start(); err != nil {
@@ -82,25 +164,25 @@ func main() {
 			log.Fatal(err)
 		}
 	case "status":
-		content, err := gorum.Status()
-		if err != nil {
+		var content string
+		if err := gorum.Control(&content, "status"); err != nil {
 			utils.ErrPrint(err)
 			log.Fatal(err)
 		}
 		fmt.Print(content)
 	case "stop":
-		if err := gorum.Stop(); err != nil {
+		if err := gorum.Control(nil, "stop"); err != nil {
 			utils.ErrPrint(err)
 			log.Fatal(err)
 		}
 	case "stopp", "stopplay":
-		if err := gorum.PlayStop(); err != nil {
+		if err := gorum.Control(nil, "stopplay"); err != nil {
 			utils.ErrPrint(err)
 			log.Fatal(err)
 		}
 	case "title":
-		content, err := gorum.Title()
-		if err != nil {
+		var content string
+		if err := gorum.Control(&content, "title"); err != nil {
 			utils.ErrPrint(err)
 			log.Fatal(err)
 		}
@@ -111,24 +193,36 @@ func main() {
 			os.Exit(1)
 		}
 		numStr := args[1]
-		numInt, errSa := strconv.Atoi(numStr)
-		if errSa != nil {
+		if _, errSa := strconv.Atoi(numStr); errSa != nil {
 			utils.ErrPrint(errSa)
 			log.Fatal(errSa)
 		}
-		if errVo := gorum.Volume(numInt); errVo != nil {
+		if errVo := gorum.Control(nil, "volume", numStr); errVo != nil {
 			utils.ErrPrint(errVo)
 			log.Fatal(errVo)
 		}
-	default:
-		if err := gorum.Play(arg); err != nil {
+	case "watch":
+		errWa := gorum.Watch(func(line []byte) error {
+			_, errFp := fmt.Printf("%s\n", line)
+			return errFp
+		})
+		if errWa != nil {
+			utils.ErrPrint(errWa)
+			log.Fatal(errWa)
+		}
+	case "--resume":
+		if len(args) != 2 {
+			gorum.Help()
+			os.Exit(1)
+		}
+		if err := gorum.Control(nil, "resume", args[1]); err != nil {
 			utils.ErrPrint(err)
 			log.Fatal(err)
 		}
-		cmd := `{"command": ["get_property", "filtered-metadata"]}`
-		if _, errSc := gorum.StatusCmd(cmd, "error", config.MaxStatusTries); errSc != nil {
-			utils.ErrPrint(errSc)
-			log.Fatal(errSc)
+	default:
+		if err := gorum.Control(nil, "play", arg); err != nil {
+			utils.ErrPrint(err)
+			log.Fatal(err)
 		}
 	}
 }
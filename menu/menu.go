@@ -66,6 +66,14 @@ func (mf *menuFile) help() string {
 	help.WriteString("sf          # launches sf selector file [.]\n")
 	help.WriteString("number      # plays the selected media stream\n")
 	help.WriteString("url         # plays the stream url\n")
+	help.WriteString("enqueue f   # appends a number, url, file, directory or playlist to the queue\n")
+	help.WriteString("next        # plays the next queue entry\n")
+	help.WriteString("prev        # plays the previous queue entry\n")
+	help.WriteString("jump n      # plays the queue entry number n\n")
+	help.WriteString("queue       # lists the queue [queue clear, queue remove n]\n")
+	help.WriteString("shuffle     # toggles the queue shuffle mode\n")
+	help.WriteString("repeat m    # sets the repeat mode off|one|all, cycles without mode\n")
+	help.WriteString("sleep d     # stops the playback after the duration d fading out [sleep, sleep cancel]\n")
 	help.WriteString("start       # starts " + mf.progTitle + "\n")
 	help.WriteString("stop        # stops " + mf.progTitle + "\n")
 	help.WriteString("stopplay    # stops playing the current media [stopp]\n")
@@ -130,7 +138,10 @@ func (mf *menuFile) draw() error {
 		return errSc
 	}
 	var selStream string
-	curStream := gorum.StreamPath()
+	var curStream string
+	if errCo := gorum.Control(&curStream, "property", "path"); errCo != nil {
+		curStream = ""
+	}
 	numPad := strconv.Itoa(utils.CountDigit(len(mf.streams)))
 	fmt.Printf("%"+numPad+"s### %s ###\n", "", strings.ToUpper(mf.progTitle))
 	fmt.Printf("%"+numPad+"s?) help\n", "")
@@ -145,10 +156,29 @@ func (mf *menuFile) draw() error {
 		}
 		fmt.Printf("%s%"+numPad+"d) %s\n", selStream, key, mf.streams[key]["name"])
 	}
-	fmt.Printf("\n# %s\n> ", strings.TrimRight(mf.statusMsg, "\n"))
+	fmt.Printf("\n%s# %s\n> ", mf.modesLine(), strings.TrimRight(mf.statusMsg, "\n"))
 	return nil
 }
 
+// modesLine returns the shuffle and repeat modes status line
+func (mf *menuFile) modesLine() string {
+	if !gorum.IsRunning() {
+		return ""
+	}
+	var modes struct {
+		Shuffle bool   `json:"shuffle"`
+		Repeat  string `json:"repeat"`
+	}
+	if errCo := gorum.Control(&modes, "modes"); errCo != nil {
+		return ""
+	}
+	shuffleStr := "no"
+	if modes.Shuffle {
+		shuffleStr = "yes"
+	}
+	return fmt.Sprintf("# shuffle: %s, repeat: %s\n", shuffleStr, modes.Repeat)
+}
+
 // doActionDefault executes the default menu option
 func (mf *menuFile) doActionDefault(action string) error {
 	var (
@@ -166,12 +196,8 @@ func (mf *menuFile) doActionDefault(action string) error {
 		return fmt.Errorf("error: invalid option")
 	}
 	mf.numErrors = 0
-	if errPl := gorum.Play(action); errPl != nil {
-		return errPl
-	}
-	cmd := `{"command": ["get_property", "filtered-metadata"]}`
-	if _, errSc := gorum.StatusCmd(cmd, "error", config.MaxStatusTries); errSc != nil {
-		return errSc
+	if errPw := gorum.Control(nil, "play", action); errPw != nil {
+		return errPw
 	}
 	if _, ok := mf.streams[streamId]; ok {
 		mf.statusMsg = mf.streams[streamId]["name"]
@@ -189,25 +215,47 @@ func (mf *menuFile) doActionDefault(action string) error {
 	return nil
 }
 
+// doActionJump executes the jump menu option
+func (mf *menuFile) doActionJump(actionArgs []string) error {
+	regexJump := regexp.MustCompile(`^\d+$`)
+	if len(actionArgs) != 1 || !regexJump.MatchString(actionArgs[0]) {
+		return fmt.Errorf("doActionJump: error: invalid arg")
+	}
+	numInt, errSa := strconv.Atoi(actionArgs[0])
+	if errSa != nil {
+		return errSa
+	}
+	if errJu := gorum.Control(nil, "jump", actionArgs[0]); errJu != nil {
+		return errJu
+	}
+	mf.statusMsg = fmt.Sprintf("info: playing the queue entry %d", numInt)
+	return nil
+}
+
+// doActionQueue executes the queue menu option
+func (mf *menuFile) doActionQueue(actionArgs []string) error {
+	var content string
+	if errQu := gorum.Control(&content, "queue", actionArgs...); errQu != nil {
+		return errQu
+	}
+	if content == "" {
+		content = "empty\n"
+	}
+	mf.statusMsg = "queue\n" + content
+	return nil
+}
+
 // doActionSeek executes the seek menu option
 func (mf *menuFile) doActionSeek(action string, actionArgs []string) error {
 	regexSeek := regexp.MustCompile(`^seek\s[+-]\d+$`)
 	if len(actionArgs) == 0 || !regexSeek.MatchString(action+" "+actionArgs[0]) {
 		return fmt.Errorf("doActionSeek: error: invalid arg")
 	}
-	secondsInt, errSa := strconv.Atoi(actionArgs[0])
-	if errSa != nil {
-		return errSa
-	}
-	if errSe := gorum.Seek(secondsInt); errSe != nil {
+	var content string
+	if errSe := gorum.Control(&content, "seek", actionArgs[0]); errSe != nil {
 		return errSe
 	}
-	cmd := `{"command": ["get_property_string", "playback-time"]}`
-	_, content, errSc := gorum.SendCmd(cmd)
-	if errSc != nil {
-		return errSc
-	}
-	mf.statusMsg = fmt.Sprintf("%s: %s", "time", content["data"])
+	mf.statusMsg = fmt.Sprintf("%s: %s", "time", content)
 	return nil
 }
 
@@ -220,7 +268,7 @@ func (mf *menuFile) doActionStart() error {
 	if errOe != nil {
 		return errOe
 	}
-	cmdCg := exec.Command(curFile, "start")
+	cmdCg := exec.Command(curFile, append(config.ProfileArgs(), "start")...)
 	cmdCg.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 	if errCr := cmdCg.Start(); errCr != nil {
 		return errCr
@@ -231,15 +279,11 @@ func (mf *menuFile) doActionStart() error {
 
 // doActionToggle executes the toggle menu option
 func (mf *menuFile) doActionToggle(action string) error {
-	if errTo := gorum.Toggle(action); errTo != nil {
+	var content string
+	if errTo := gorum.Control(&content, action); errTo != nil {
 		return errTo
 	}
-	cmd := fmt.Sprintf(`{"command": ["get_property_string", "%s"]}`, action)
-	_, content, errSc := gorum.SendCmd(cmd)
-	if errSc != nil {
-		return errSc
-	}
-	mf.statusMsg = fmt.Sprintf("%s: %s", action, content["data"])
+	mf.statusMsg = fmt.Sprintf("%s: %s", action, content)
 	return nil
 }
 
@@ -253,7 +297,7 @@ func (mf *menuFile) doActionVolume(actionBase string, actionArg []string) error
 	if errSa != nil {
 		return errSa
 	}
-	if errSe := gorum.Volume(numInt); errSe != nil {
+	if errSe := gorum.Control(nil, "volume", actionArg[0]); errSe != nil {
 		return errSe
 	}
 	mf.statusMsg = fmt.Sprintf("%s: %d", "volume", numInt)
@@ -276,41 +320,84 @@ func (mf *menuFile) doAction(option string) error {
 		mf.statusMsg = ""
 	case "exit", "quit":
 		os.Exit(0)
+	case "enqueue":
+		if err := gorum.Control(nil, "enqueue", strings.Join(actionArgs, " ")); err != nil {
+			mf.statusMsg = err.Error()
+		} else {
+			mf.statusMsg = "info: enqueued " + strings.Join(actionArgs, " ")
+		}
+	case "jump":
+		if err := mf.doActionJump(actionArgs); err != nil {
+			mf.statusMsg = err.Error()
+		}
 	case "mute", "pause", "video":
 		if err := mf.doActionToggle(action); err != nil {
 			mf.statusMsg = err.Error()
 		}
+	case "next":
+		if err := gorum.Control(nil, "next"); err != nil {
+			mf.statusMsg = err.Error()
+		} else {
+			mf.statusMsg = "info: playing the next queue entry"
+		}
+	case "prev":
+		if err := gorum.Control(nil, "prev"); err != nil {
+			mf.statusMsg = err.Error()
+		} else {
+			mf.statusMsg = "info: playing the previous queue entry"
+		}
+	case "queue":
+		if err := mf.doActionQueue(actionArgs); err != nil {
+			mf.statusMsg = err.Error()
+		}
 	case "number", "url":
 		mf.statusMsg = fmt.Sprintf("info: simply put the stream %s and press ENTER", action)
+	case "repeat":
+		if len(actionArgs) > 1 {
+			mf.statusMsg = "doActionRepeat: error: invalid arg"
+		} else if err := gorum.Control(nil, "repeat", actionArgs...); err != nil {
+			mf.statusMsg = err.Error()
+		}
 	case "seek":
 		if err := mf.doActionSeek(action, actionArgs); err != nil {
 			mf.statusMsg = err.Error()
 		}
+	case "shuffle":
+		if err := gorum.Control(nil, "shuffle"); err != nil {
+			mf.statusMsg = err.Error()
+		}
+	case "sleep":
+		var content string
+		if err := gorum.Control(&content, "sleep", actionArgs...); err != nil {
+			mf.statusMsg = err.Error()
+		} else {
+			mf.statusMsg = strings.TrimSuffix(content, "\n")
+		}
 	case "start":
 		if err := mf.doActionStart(); err != nil {
 			mf.statusMsg = err.Error()
 		}
 	case "status":
-		content, err := gorum.Status()
-		if err != nil {
+		var content string
+		if err := gorum.Control(&content, "status"); err != nil {
 			mf.statusMsg = err.Error()
 		} else {
 			mf.statusMsg = "status\n" + content
 		}
 	case "stop":
-		if err := gorum.Stop(); err != nil {
+		if err := gorum.Control(nil, "stop"); err != nil {
 			mf.statusMsg = err.Error()
 		}
 		if !gorum.IsRunning() {
 			mf.statusMsg = fmt.Sprintf("info: '%s' is not running, see help\n", mf.progTitle)
 		}
 	case "stopp", "stopplay":
-		if err := gorum.PlayStop(); err != nil {
+		if err := gorum.Control(nil, "stopplay"); err != nil {
 			mf.statusMsg = err.Error()
 		}
 	case "title":
-		content, err := gorum.Title()
-		if err != nil {
+		var content string
+		if err := gorum.Control(&content, "title"); err != nil {
 			mf.statusMsg = err.Error()
 		} else {
 			mf.statusMsg = fmt.Sprintf("%s: %s", action, content)
@@ -329,9 +416,10 @@ func (mf *menuFile) doAction(option string) error {
 
 // Menu plays the selected media using a streaming selector
 func Menu() error {
+	streams, _ := config.Stations()
 	mf := menuFile{
 		progTitle: config.ProgName,
-		streams:   config.Streams,
+		streams:   streams,
 	}
 	if !gorum.IsRunning() {
 		mf.statusMsg = fmt.Sprintf("info: '%s' is not running, see help\n", mf.progTitle)
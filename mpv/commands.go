@@ -0,0 +1,211 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package mpv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Error data type
+type Error struct {
+	Command []interface{}
+	Message string
+}
+
+// PlaylistEntry data type
+type PlaylistEntry struct {
+	Filename string `json:"filename"`
+	Current  bool   `json:"current"`
+	Playing  bool   `json:"playing"`
+	Title    string `json:"title"`
+	Id       int64  `json:"id"`
+}
+
+// reply data type
+type reply struct {
+	Data  json.RawMessage `json:"data"`
+	Error string          `json:"error"`
+}
+
+// Error returns the mpv error message
+func (e *Error) Error() string {
+	return fmt.Sprintf("mpv: error: command %v: %s\n", e.Command, e.Message)
+}
+
+// IsUnavailable checks if the error is a mpv property unavailable error
+func IsUnavailable(err error) bool {
+	var errMpv *Error
+	return errors.As(err, &errMpv) && errMpv.Message == "property unavailable"
+}
+
+// Command sends the command arguments and returns the data field of its reply
+func (c *Client) Command(ctx context.Context, args ...interface{}) (json.RawMessage, error) {
+	line, errCr := c.request(ctx, args)
+	if errCr != nil {
+		return nil, errCr
+	}
+	var rep reply
+	if errJu := json.Unmarshal(line, &rep); errJu != nil {
+		return nil, fmt.Errorf("command: error: invalid reply %s\n", line)
+	}
+	if rep.Error != "success" {
+		return nil, &Error{Command: args, Message: rep.Error}
+	}
+	return rep.Data, nil
+}
+
+// Cycle cycles the property value
+func (c *Client) Cycle(ctx context.Context, name string) error {
+	_, err := c.Command(ctx, "cycle", name)
+	return err
+}
+
+// Get gets the property value and stores it in the value pointed to by v
+func (c *Client) Get(ctx context.Context, name string, v interface{}) error {
+	data, errCc := c.Command(ctx, "get_property", name)
+	if errCc != nil {
+		return errCc
+	}
+	if errJu := json.Unmarshal(data, v); errJu != nil {
+		return fmt.Errorf("get: error: property '%s' has unexpected value %s\n", name, data)
+	}
+	return nil
+}
+
+// GetBool gets the boolean property value
+func (c *Client) GetBool(ctx context.Context, name string) (bool, error) {
+	var value bool
+	err := c.Get(ctx, name, &value)
+	return value, err
+}
+
+// GetFloat gets the numeric property value
+func (c *Client) GetFloat(ctx context.Context, name string) (float64, error) {
+	var value float64
+	err := c.Get(ctx, name, &value)
+	return value, err
+}
+
+// GetInt gets the integer property value
+func (c *Client) GetInt(ctx context.Context, name string) (int, error) {
+	var value int
+	err := c.Get(ctx, name, &value)
+	return value, err
+}
+
+// GetString gets the string property value
+func (c *Client) GetString(ctx context.Context, name string) (string, error) {
+	var value string
+	err := c.Get(ctx, name, &value)
+	return value, err
+}
+
+// GetPropertyString gets the property value formatted as a string by mpv
+func (c *Client) GetPropertyString(ctx context.Context, name string) (string, error) {
+	var value string
+	data, errCc := c.Command(ctx, "get_property_string", name)
+	if errCc != nil {
+		return "", errCc
+	}
+	if errJu := json.Unmarshal(data, &value); errJu != nil {
+		return "", fmt.Errorf("getPropertyString: error: property '%s' has unexpected value %s\n", name, data)
+	}
+	return value, nil
+}
+
+// LoadFile loads the file or url, mode is replace, append or append-play
+func (c *Client) LoadFile(ctx context.Context, file string, mode string) error {
+	_, err := c.Command(ctx, "loadfile", file, mode)
+	return err
+}
+
+// Observe observes the property, its changes are delivered as property-change events with id
+func (c *Client) Observe(ctx context.Context, id int64, name string) error {
+	_, err := c.Command(ctx, "observe_property", id, name)
+	return err
+}
+
+// Playlist returns the playlist entries
+func (c *Client) Playlist(ctx context.Context) ([]PlaylistEntry, error) {
+	var entries []PlaylistEntry
+	if err := c.Get(ctx, "playlist", &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// PlaylistClear removes every playlist entry except the current one
+func (c *Client) PlaylistClear(ctx context.Context) error {
+	_, err := c.Command(ctx, "playlist-clear")
+	return err
+}
+
+// PlaylistNext plays the next playlist entry
+func (c *Client) PlaylistNext(ctx context.Context) error {
+	_, err := c.Command(ctx, "playlist-next")
+	return err
+}
+
+// PlaylistPlayIndex plays the playlist entry at index
+func (c *Client) PlaylistPlayIndex(ctx context.Context, index int) error {
+	_, err := c.Command(ctx, "playlist-play-index", index)
+	return err
+}
+
+// PlaylistPrev plays the previous playlist entry
+func (c *Client) PlaylistPrev(ctx context.Context) error {
+	_, err := c.Command(ctx, "playlist-prev")
+	return err
+}
+
+// PlaylistRemove removes the playlist entry at index
+func (c *Client) PlaylistRemove(ctx context.Context, index int) error {
+	_, err := c.Command(ctx, "playlist-remove", index)
+	return err
+}
+
+// PlaylistRemoveCurrent removes the current playlist entry
+func (c *Client) PlaylistRemoveCurrent(ctx context.Context) error {
+	_, err := c.Command(ctx, "playlist-remove", "current")
+	return err
+}
+
+// Quit exits the player
+func (c *Client) Quit(ctx context.Context) error {
+	_, err := c.Command(ctx, "quit")
+	return err
+}
+
+// Seek seeks forward (+n) or backward (-n) in seconds
+func (c *Client) Seek(ctx context.Context, seconds float64) error {
+	_, err := c.Command(ctx, "seek", seconds, "relative")
+	return err
+}
+
+// SeekAbsolute seeks to the position in seconds
+func (c *Client) SeekAbsolute(ctx context.Context, seconds float64) error {
+	_, err := c.Command(ctx, "seek", seconds, "absolute")
+	return err
+}
+
+// SetProperty sets the property value
+func (c *Client) SetProperty(ctx context.Context, name string, value interface{}) error {
+	_, err := c.Command(ctx, "set_property", name, value)
+	return err
+}
+
+// Stop stops playback and clears the playlist
+func (c *Client) Stop(ctx context.Context) error {
+	_, err := c.Command(ctx, "stop")
+	return err
+}
+
+// Unobserve stops observing the properties with id
+func (c *Client) Unobserve(ctx context.Context, id int64) error {
+	_, err := c.Command(ctx, "unobserve_property", id)
+	return err
+}
@@ -0,0 +1,347 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package mpv
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout the default time to wait for a command reply
+const DefaultTimeout = 10 * time.Second
+
+// SubscribeBuffer the number of events a subscriber channel holds, the next ones wait in its queue
+const SubscribeBuffer = 256
+
+// ErrClosed the client connection is closed
+var ErrClosed = errors.New("mpv: error: connection closed")
+
+// Client data type
+type Client struct {
+	Timeout time.Duration
+	closed  bool
+	conn    net.Conn
+	done    chan struct{}
+	err     error
+	mu      sync.Mutex
+	nextId  int64
+	pending map[int64]chan []byte
+	subs    map[*subscriber]bool
+	wmu     sync.Mutex
+}
+
+// Event data type
+type Event struct {
+	Event     string      `json:"event"`
+	Id        int64       `json:"id,omitempty"`
+	Name      string      `json:"name,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
+	Reason    string      `json:"reason,omitempty"`
+	FileError string      `json:"file_error,omitempty"`
+	EntryId   int64       `json:"playlist_entry_id,omitempty"`
+}
+
+// subscriber data type, its events wait in the queue until its channel has room
+type subscriber struct {
+	ch     chan Event
+	closed bool
+	latest map[string]bool
+	mu     sync.Mutex
+	queue  []Event
+	quit   chan struct{}
+	wake   chan struct{}
+}
+
+// message data type
+type message struct {
+	Event     string `json:"event"`
+	RequestId int64  `json:"request_id"`
+}
+
+// request data type
+type request struct {
+	Command   []interface{} `json:"command"`
+	RequestId int64         `json:"request_id"`
+}
+
+// Dial connects to the mpv IPC socket file
+func Dial(file string) (*Client, error) {
+	conn, err := net.Dial("unix", file)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn), nil
+}
+
+// NewClient returns a client that uses the connection conn
+func NewClient(conn net.Conn) *Client {
+	c := &Client{
+		Timeout: DefaultTimeout,
+		conn:    conn,
+		done:    make(chan struct{}),
+		pending: make(map[int64]chan []byte),
+		subs:    make(map[*subscriber]bool),
+	}
+	go c.readLoop()
+	return c
+}
+
+// Close closes the client connection
+func (c *Client) Close() error {
+	err := c.conn.Close()
+	<-c.done
+	return err
+}
+
+// Closed checks if the client connection is closed
+func (c *Client) Closed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Done returns a channel that is closed when the client connection is closed
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns the error that closed the client connection
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Subscribe returns a channel of asynchronous events and a function to unsubscribe it,
+// a slow subscriber never stops the replies, its events wait in a queue and none is dropped,
+// except the changes of the latest properties that only keep their newest value while they wait,
+// the channel is closed when it is unsubscribed or after the last event once the connection is closed
+func (c *Client) Subscribe(latest ...string) (<-chan Event, func()) {
+	sub := &subscriber{
+		ch:     make(chan Event, SubscribeBuffer),
+		latest: make(map[string]bool, len(latest)),
+		quit:   make(chan struct{}),
+		wake:   make(chan struct{}, 1),
+	}
+	for _, name := range latest {
+		sub.latest[name] = true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		close(sub.ch)
+		return sub.ch, func() {}
+	}
+	c.subs[sub] = true
+	go sub.pump()
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			c.mu.Lock()
+			delete(c.subs, sub)
+			c.mu.Unlock()
+			close(sub.quit)
+		})
+	}
+}
+
+// push queues the event without blocking, replacing the waiting change of the same latest property
+func (s *subscriber) push(ev Event) {
+	s.mu.Lock()
+	replaced := false
+	if ev.Event == "property-change" && s.latest[ev.Name] {
+		for num := range s.queue {
+			if s.queue[num].Event == ev.Event && s.queue[num].Name == ev.Name {
+				s.queue[num], replaced = ev, true
+				break
+			}
+		}
+	}
+	if !replaced {
+		s.queue = append(s.queue, ev)
+	}
+	s.mu.Unlock()
+	s.notify()
+}
+
+// close delivers the waiting events and then closes the channel
+func (s *subscriber) close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.notify()
+}
+
+// notify wakes up the pump
+func (s *subscriber) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pump sends the queued events to the channel in order until it is unsubscribed or closed
+func (s *subscriber) pump() {
+	defer close(s.ch)
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return
+			}
+			select {
+			case <-s.wake:
+			case <-s.quit:
+				return
+			}
+			continue
+		}
+		ev := s.queue[0]
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+		select {
+		case s.ch <- ev:
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// readLoop reads the lines from the connection and routes them to their requests or to events
+func (c *Client) readLoop() {
+	reader := bufio.NewReader(c.conn)
+	var errRl error
+	for {
+		line, errRb := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			c.route(line[:len(line)-1])
+		}
+		if errRb != nil {
+			errRl = errRb
+			break
+		}
+	}
+	c.mu.Lock()
+	c.closed = true
+	c.err = errRl
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+	for sub := range c.subs {
+		sub.close()
+		delete(c.subs, sub)
+	}
+	c.mu.Unlock()
+	close(c.done)
+}
+
+// route delivers the line to its pending request or to the events channel
+func (c *Client) route(line []byte) {
+	var msg message
+	if errJu := json.Unmarshal(line, &msg); errJu != nil {
+		return
+	}
+	if msg.Event != "" {
+		var ev Event
+		if errJu := json.Unmarshal(line, &ev); errJu != nil {
+			return
+		}
+		c.mu.Lock()
+		for sub := range c.subs {
+			sub.push(ev)
+		}
+		c.mu.Unlock()
+		return
+	}
+	c.mu.Lock()
+	ch, ok := c.pending[msg.RequestId]
+	delete(c.pending, msg.RequestId)
+	c.mu.Unlock()
+	if ok {
+		ch <- line
+	}
+}
+
+// Send sends the json command object and returns its raw reply
+func (c *Client) Send(cmd []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	return c.SendContext(ctx, cmd)
+}
+
+// SendContext sends the json command object and returns its raw reply, waiting until ctx is done
+func (c *Client) SendContext(ctx context.Context, cmd []byte) ([]byte, error) {
+	var content request
+	decoder := json.NewDecoder(bytes.NewReader(cmd))
+	decoder.UseNumber()
+	if errDd := decoder.Decode(&content); errDd != nil || len(content.Command) == 0 {
+		return nil, fmt.Errorf("send: error: invalid json command %s\n", cmd)
+	}
+	return c.request(ctx, content.Command)
+}
+
+// EncodeCommand encodes the command arguments as a json command object tagged with id
+func EncodeCommand(id int64, args []interface{}) ([]byte, error) {
+	data, err := json.Marshal(request{Command: args, RequestId: id})
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// request tags the command arguments with a new request id, sends them and waits for the reply
+func (c *Client) request(ctx context.Context, args []interface{}) ([]byte, error) {
+	ch := make(chan []byte, 1)
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClosed
+	}
+	c.nextId++
+	id := c.nextId
+	c.pending[id] = ch
+	c.mu.Unlock()
+	data, errEc := EncodeCommand(id, args)
+	if errEc != nil {
+		c.forget(id)
+		return nil, errEc
+	}
+	c.wmu.Lock()
+	_, errCw := c.conn.Write(data)
+	c.wmu.Unlock()
+	if errCw != nil {
+		c.forget(id)
+		return nil, errCw
+	}
+	select {
+	case line, ok := <-ch:
+		if !ok {
+			return nil, ErrClosed
+		}
+		return line, nil
+	case <-ctx.Done():
+		c.forget(id)
+		return nil, fmt.Errorf("send: error: waiting for reply to %s: %w\n", bytes.TrimSpace(data), ctx.Err())
+	}
+}
+
+// forget removes the pending request id
+func (c *Client) forget(id int64) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
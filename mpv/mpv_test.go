@@ -0,0 +1,285 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package mpv
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeServer starts an IPC server that records every received line and replies with success
+func fakeServer(t *testing.T) (string, <-chan []byte) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "mpv.socket")
+	ln, errNl := net.Listen("unix", file)
+	if errNl != nil {
+		t.Fatal(errNl)
+	}
+	t.Cleanup(func() { ln.Close() })
+	lines := make(chan []byte, 16)
+	go func() {
+		conn, errLa := ln.Accept()
+		if errLa != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for {
+			line, errRb := reader.ReadBytes('\n')
+			if errRb != nil {
+				return
+			}
+			lines <- line
+			var content map[string]interface{}
+			if errJu := json.Unmarshal(line, &content); errJu != nil {
+				continue
+			}
+			data, _ := json.Marshal(map[string]interface{}{"request_id": content["request_id"], "error": "success"})
+			if _, errCw := conn.Write(append(data, '\n')); errCw != nil {
+				return
+			}
+		}
+	}()
+	return file, lines
+}
+
+func TestLoadFileHostileNames(t *testing.T) {
+	file, lines := fakeServer(t)
+	cli, errMd := Dial(file)
+	if errMd != nil {
+		t.Fatal(errMd)
+	}
+	defer cli.Close()
+	names := []string{
+		`/music/say "hello".mp3`,
+		`/music/back\slash\.ogg`,
+		`/music/", "append"]}` + "\n" + `{"command": ["quit"]}`,
+		"/music/line\nbreak.flac",
+		"/music/tab\tand\x00nul.mp3",
+		"/music/ünïcödé ☃ 日本語.opus",
+		"https://example.org/stream?a=1&b=\"2\"",
+	}
+	for _, name := range names {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		errLf := cli.LoadFile(ctx, name, "replace")
+		cancel()
+		if errLf != nil {
+			t.Fatalf("LoadFile(%q): %v", name, errLf)
+		}
+		line := <-lines
+		if line[len(line)-1] != '\n' || bytes.Count(line, []byte{'\n'}) != 1 {
+			t.Fatalf("LoadFile(%q): command is not a single line: %q", name, line)
+		}
+		var content struct {
+			Command []string `json:"command"`
+		}
+		if errJu := json.Unmarshal(line, &content); errJu != nil {
+			t.Fatalf("LoadFile(%q): invalid json %q: %v", name, line, errJu)
+		}
+		if len(content.Command) != 3 || content.Command[0] != "loadfile" ||
+			content.Command[1] != name || content.Command[2] != "replace" {
+			t.Fatalf("LoadFile(%q): unexpected command %q", name, content.Command)
+		}
+	}
+}
+
+func TestSendContextRejectsInvalidJson(t *testing.T) {
+	file, lines := fakeServer(t)
+	cli, errMd := Dial(file)
+	if errMd != nil {
+		t.Fatal(errMd)
+	}
+	defer cli.Close()
+	cmds := []string{
+		`{"command": ["loadfile", "a"b", "replace"]}`,
+		`{"command": []}`,
+		`["loadfile", "a"]`,
+	}
+	for _, cmd := range cmds {
+		if _, errCs := cli.Send([]byte(cmd)); errCs == nil {
+			t.Fatalf("Send(%s): expected error", cmd)
+		}
+	}
+	select {
+	case line := <-lines:
+		t.Fatalf("unexpected line sent %q", line)
+	default:
+	}
+}
+
+// readRequest reads the next request from the server side of the connection
+func readRequest(t *testing.T, reader *bufio.Reader) request {
+	t.Helper()
+	line, errRb := reader.ReadBytes('\n')
+	if errRb != nil {
+		t.Error(errRb)
+		return request{}
+	}
+	var req request
+	if errJu := json.Unmarshal(line, &req); errJu != nil {
+		t.Error(errJu)
+	}
+	return req
+}
+
+func TestRequestReplies(t *testing.T) {
+	server, conn := net.Pipe()
+	cli := NewClient(conn)
+	defer cli.Close()
+	go func() {
+		reader := bufio.NewReader(server)
+		first, second := readRequest(t, reader), readRequest(t, reader)
+		// the replies arrive out of order with an unknown id and an event between them
+		for _, reply := range []string{
+			fmt.Sprintf(`{"request_id": %d, "data": "%s", "error": "success"}`, second.RequestId, second.Command[1]),
+			`{"request_id": 999, "data": "unknown", "error": "success"}`,
+			`{"event": "idle"}`,
+			fmt.Sprintf(`{"request_id": %d, "data": "%s", "error": "success"}`, first.RequestId, first.Command[1]),
+		} {
+			if _, err := server.Write([]byte(reply + "\n")); err != nil {
+				return
+			}
+		}
+	}()
+	type result struct {
+		name string
+		data string
+		err  error
+	}
+	results := make(chan result, 2)
+	for num, name := range []string{"volume", "mute"} {
+		go func(name string) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			data, err := cli.GetPropertyString(ctx, name)
+			results <- result{name, data, err}
+		}(name)
+		if num == 0 {
+			// the first request is written before the second one
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	for num := 0; num < 2; num++ {
+		res := <-results
+		if res.err != nil || res.data != res.name {
+			t.Fatalf("expected the reply of %s, got %q %v", res.name, res.data, res.err)
+		}
+	}
+}
+
+func TestSubscribeEvents(t *testing.T) {
+	server, conn := net.Pipe()
+	cli := NewClient(conn)
+	defer cli.Close()
+	first, unsubscribeFirst := cli.Subscribe()
+	defer unsubscribeFirst()
+	second, unsubscribeSecond := cli.Subscribe()
+	unsubscribeSecond()
+	unsubscribeSecond()
+	if _, ok := <-second; ok {
+		t.Fatal("expected the unsubscribed channel to be closed")
+	}
+	go server.Write([]byte(`{"event": "property-change", "id": 1, "name": "pause", "data": true}` + "\n"))
+	select {
+	case ev := <-first:
+		if ev.Event != "property-change" || ev.Id != 1 || ev.Name != "pause" || ev.Data != true {
+			t.Fatalf("unexpected event %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the event")
+	}
+}
+
+func TestSubscribeSlowSubscriber(t *testing.T) {
+	server, conn := net.Pipe()
+	cli := NewClient(conn)
+	defer cli.Close()
+	events, unsubscribe := cli.Subscribe("time-pos")
+	defer unsubscribe()
+	total := SubscribeBuffer * 3
+	go func() {
+		reader := bufio.NewReader(server)
+		req := readRequest(t, reader)
+		// the subscriber reads none of the events while the position changes flood it
+		for num := 1; num <= total; num++ {
+			if _, err := fmt.Fprintf(server, `{"event": "property-change", "id": 6, "name": "time-pos", "data": %d}`+"\n", num); err != nil {
+				return
+			}
+			if num == SubscribeBuffer*2 {
+				server.Write([]byte(`{"event": "end-file", "reason": "eof"}` + "\n"))
+			}
+		}
+		fmt.Fprintf(server, `{"request_id": %d, "error": "success"}`+"\n", req.RequestId)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := cli.request(ctx, []interface{}{"get_version"}); err != nil {
+		t.Fatal(err)
+	}
+	received, ended, pos := 0, false, 0.0
+	for pos != float64(total) || !ended {
+		select {
+		case ev := <-events:
+			received++
+			switch {
+			case ev.Event == "end-file":
+				ended = true
+			case ev.Name == "time-pos":
+				if data := ev.Data.(float64); data <= pos {
+					t.Fatalf("expected the positions in order, got %v after %v", data, pos)
+				} else {
+					pos = data
+				}
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected the last position %d and the end-file event, got %v and %t", total, pos, ended)
+		}
+	}
+	if received > SubscribeBuffer+3 {
+		t.Fatalf("expected the waiting positions to keep only the newest one, got %d events", received)
+	}
+}
+
+func TestDisconnect(t *testing.T) {
+	server, conn := net.Pipe()
+	cli := NewClient(conn)
+	defer cli.Close()
+	events, unsubscribe := cli.Subscribe()
+	defer unsubscribe()
+	go func() {
+		reader := bufio.NewReader(server)
+		readRequest(t, reader)
+		server.Close()
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := cli.request(ctx, []interface{}{"get_version"}); err != ErrClosed {
+		t.Fatalf("expected %v for the pending request, got %v", ErrClosed, err)
+	}
+	select {
+	case <-cli.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected the client to be done")
+	}
+	if !cli.Closed() || cli.Err() == nil {
+		t.Fatalf("expected a closed client with its error, got %v", cli.Err())
+	}
+	if _, ok := <-events; ok {
+		t.Fatal("expected the events channel to be closed")
+	}
+	if _, err := cli.request(ctx, []interface{}{"get_version"}); err != ErrClosed {
+		t.Fatalf("expected %v after closing, got %v", ErrClosed, err)
+	}
+	late, _ := cli.Subscribe()
+	if _, ok := <-late; ok {
+		t.Fatal("expected a closed events channel after closing")
+	}
+}
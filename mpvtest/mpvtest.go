@@ -0,0 +1,692 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package mpvtest
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Server data type
+type Server struct {
+	File       string
+	commands   [][]interface{}
+	conns      map[net.Conn]map[int64]string
+	delays     map[string]time.Duration
+	failures   map[string]string
+	loadErrs   map[string]string
+	ln         net.Listener
+	mu         sync.Mutex
+	outbox     []message
+	playlist   []string
+	playPos    int
+	unshuffled []string
+	props      map[string]interface{}
+	wg         sync.WaitGroup
+	writeMu    sync.Mutex
+	closeOnce  sync.Once
+}
+
+// message data type
+type message struct {
+	conn    net.Conn
+	content map[string]interface{}
+}
+
+// NewServer starts a fake mpv IPC server listening on the unix socket file
+func NewServer(file string) (*Server, error) {
+	ln, err := net.Listen("unix", file)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		File:     file,
+		conns:    make(map[net.Conn]map[int64]string),
+		delays:   make(map[string]time.Duration),
+		failures: make(map[string]string),
+		loadErrs: make(map[string]string),
+		ln:       ln,
+		playPos:  -1,
+		props:    defaultProperties(),
+	}
+	s.wg.Add(1)
+	go s.acceptLoop()
+	return s, nil
+}
+
+// NewTempServer starts a fake mpv IPC server listening on a socket file inside dir
+func NewTempServer(dir string) (*Server, error) {
+	return NewServer(filepath.Join(dir, "mpv.socket"))
+}
+
+// defaultProperties returns the properties of an idle mpv
+func defaultProperties() map[string]interface{} {
+	return map[string]interface{}{
+		"ao-volume":      float64(100),
+		"eof-reached":    false,
+		"idle-active":    true,
+		"loop-file":      false,
+		"loop-playlist":  false,
+		"mute":           false,
+		"pause":          false,
+		"playlist-count": 0,
+		"playlist-pos":   -1,
+		"seekable":       false,
+		"shuffle":        false,
+		"video":          "auto",
+		"volume":         float64(100),
+	}
+}
+
+// Close stops the server and closes all its connections
+func (s *Server) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		err = s.ln.Close()
+		s.mu.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mu.Unlock()
+		s.wg.Wait()
+		if _, errOs := os.Stat(s.File); errOs == nil {
+			os.Remove(s.File)
+		}
+	})
+	return err
+}
+
+// Commands returns the commands received so far
+func (s *Server) Commands() [][]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cmds := make([][]interface{}, len(s.commands))
+	copy(cmds, s.commands)
+	return cmds
+}
+
+// Delay delays the replies of the command name by d, zero removes the delay
+func (s *Server) Delay(name string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if d == 0 {
+		delete(s.delays, name)
+		return
+	}
+	s.delays[name] = d
+}
+
+// Emit sends the event to every connection
+func (s *Server) Emit(event string, fields map[string]interface{}) {
+	content := map[string]interface{}{"event": event}
+	for key, value := range fields {
+		content[key] = value
+	}
+	s.mu.Lock()
+	s.broadcastLocked(content)
+	s.mu.Unlock()
+	s.flush()
+}
+
+// Fail makes the command name reply with the mpv error message, empty message removes the failure
+func (s *Server) Fail(name string, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if message == "" {
+		delete(s.failures, name)
+		return
+	}
+	s.failures[name] = message
+}
+
+// FailLoad makes loading the file end with the mpv file error, empty message removes the failure
+func (s *Server) FailLoad(file string, fileError string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if fileError == "" {
+		delete(s.loadErrs, file)
+		return
+	}
+	s.loadErrs[file] = fileError
+}
+
+// Get returns the property value
+func (s *Server) Get(name string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.props[name]
+}
+
+// Playlist returns the playlist entries
+func (s *Server) Playlist() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := make([]string, len(s.playlist))
+	copy(entries, s.playlist)
+	return entries
+}
+
+// Set sets the property value, nil makes it unavailable
+func (s *Server) Set(name string, value interface{}) {
+	s.mu.Lock()
+	s.setLocked(name, value)
+	s.mu.Unlock()
+	s.flush()
+}
+
+// setLocked sets the property value and notifies its observers, s.mu must be held
+func (s *Server) setLocked(name string, value interface{}) {
+	if value == nil {
+		delete(s.props, name)
+	} else {
+		s.props[name] = value
+	}
+	for conn, observed := range s.conns {
+		for id, prop := range observed {
+			if prop == name {
+				s.queueLocked(conn, propertyChange(id, name, value))
+			}
+		}
+	}
+}
+
+// propertyChange returns the property-change event content
+func propertyChange(id int64, name string, value interface{}) map[string]interface{} {
+	content := map[string]interface{}{"event": "property-change", "id": id, "name": name}
+	if value != nil {
+		content["data"] = value
+	}
+	return content
+}
+
+// acceptLoop accepts the client connections
+func (s *Server) acceptLoop() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = make(map[int64]string)
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.serve(conn)
+	}
+}
+
+// serve reads the commands of the connection
+func (s *Server) serve(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return
+		}
+		var content struct {
+			Command   []interface{} `json:"command"`
+			RequestId int64         `json:"request_id"`
+		}
+		if errJu := json.Unmarshal(line, &content); errJu != nil || len(content.Command) == 0 {
+			s.mu.Lock()
+			s.queueLocked(conn, map[string]interface{}{"error": "invalid parameter"})
+			s.mu.Unlock()
+			s.flush()
+			continue
+		}
+		go s.reply(conn, content.RequestId, content.Command)
+	}
+}
+
+// reply executes the command and writes its reply
+func (s *Server) reply(conn net.Conn, id int64, cmd []interface{}) {
+	name := fmt.Sprintf("%v", cmd[0])
+	s.mu.Lock()
+	s.commands = append(s.commands, cmd)
+	delay := s.delays[name]
+	failure := s.failures[name]
+	s.mu.Unlock()
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	content := map[string]interface{}{"request_id": id, "error": "success"}
+	var (
+		data   interface{}
+		events []map[string]interface{}
+		err    error
+	)
+	if failure != "" {
+		err = errors.New(failure)
+	} else {
+		data, events, err = s.execute(conn, cmd)
+	}
+	if err != nil {
+		content["error"] = err.Error()
+	} else if data != nil {
+		content["data"] = data
+	}
+	s.mu.Lock()
+	s.queueLocked(conn, content)
+	for _, event := range events {
+		s.broadcastLocked(event)
+	}
+	s.mu.Unlock()
+	s.flush()
+	if name == "quit" && err == nil {
+		s.mu.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mu.Unlock()
+	}
+}
+
+// execute runs the command against the property table
+func (s *Server) execute(conn net.Conn, cmd []interface{}) (interface{}, []map[string]interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	args := cmd[1:]
+	argString := func(num int) (string, error) {
+		if num >= len(args) {
+			return "", fmt.Errorf("invalid parameter")
+		}
+		str, ok := args[num].(string)
+		if !ok {
+			return "", fmt.Errorf("invalid parameter")
+		}
+		return str, nil
+	}
+	switch cmd[0] {
+	case "get_property":
+		name, err := argString(0)
+		if err != nil {
+			return nil, nil, err
+		}
+		value, ok := s.propertyLocked(name)
+		if !ok {
+			return nil, nil, fmt.Errorf("property unavailable")
+		}
+		return value, nil, nil
+	case "get_property_string":
+		name, err := argString(0)
+		if err != nil {
+			return nil, nil, err
+		}
+		value, ok := s.propertyLocked(name)
+		if !ok {
+			return nil, nil, fmt.Errorf("property unavailable")
+		}
+		return formatProperty(value), nil, nil
+	case "set_property":
+		name, err := argString(0)
+		if err != nil || len(args) < 2 {
+			return nil, nil, fmt.Errorf("invalid parameter")
+		}
+		s.setLocked(name, args[1])
+		return nil, nil, nil
+	case "cycle":
+		name, err := argString(0)
+		if err != nil {
+			return nil, nil, err
+		}
+		value, ok := s.props[name].(bool)
+		if !ok {
+			return nil, nil, fmt.Errorf("property unavailable")
+		}
+		s.setLocked(name, !value)
+		return nil, nil, nil
+	case "seek":
+		if len(args) == 0 {
+			return nil, nil, fmt.Errorf("invalid parameter")
+		}
+		seconds, err := argFloat(args[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		if seekable, _ := s.props["seekable"].(bool); !seekable {
+			return nil, nil, fmt.Errorf("error running command")
+		}
+		pos, _ := s.props["time-pos"].(float64)
+		if len(args) > 1 && args[1] == "absolute" {
+			pos = 0
+		}
+		s.setLocked("time-pos", pos+seconds)
+		s.setLocked("playback-time", pos+seconds)
+		return nil, nil, nil
+	case "loadfile":
+		file, err := argString(0)
+		if err != nil {
+			return nil, nil, err
+		}
+		mode := "replace"
+		if len(args) > 1 {
+			if mode, err = argString(1); err != nil {
+				return nil, nil, err
+			}
+		}
+		return nil, s.loadLocked(file, mode), nil
+	case "playlist-remove":
+		if len(args) == 0 {
+			return nil, nil, fmt.Errorf("invalid parameter")
+		}
+		index := s.playPos
+		if args[0] != "current" {
+			num, err := argFloat(args[0])
+			if err != nil {
+				return nil, nil, err
+			}
+			index = int(num)
+		}
+		if index < 0 || index >= len(s.playlist) {
+			return nil, nil, fmt.Errorf("error running command")
+		}
+		return nil, s.removeLocked(index), nil
+	case "playlist-clear":
+		if s.playPos >= 0 {
+			s.playlist = []string{s.playlist[s.playPos]}
+			s.playPos = 0
+			s.setLocked("playlist-pos", 0)
+		} else {
+			s.playlist = nil
+		}
+		s.setLocked("playlist-count", len(s.playlist))
+		return nil, nil, nil
+	case "playlist-next", "playlist-prev":
+		index := s.playPos + 1
+		if cmd[0] == "playlist-prev" {
+			index = s.playPos - 1
+		}
+		if loop := formatProperty(s.props["loop-playlist"]); loop != "no" && len(s.playlist) > 0 {
+			index = (index + len(s.playlist)) % len(s.playlist)
+		}
+		if s.playPos < 0 || index < 0 || index >= len(s.playlist) {
+			return nil, nil, fmt.Errorf("error running command")
+		}
+		return nil, s.playIndexLocked(index), nil
+	case "playlist-shuffle":
+		s.unshuffled = append([]string(nil), s.playlist...)
+		for num := len(s.playlist) - 1; num > 0; num-- {
+			swap := rand.Intn(num + 1)
+			s.playlist[num], s.playlist[swap] = s.playlist[swap], s.playlist[num]
+			switch s.playPos {
+			case num:
+				s.playPos = swap
+			case swap:
+				s.playPos = num
+			}
+		}
+		s.setLocked("playlist-pos", s.playPos)
+		return nil, nil, nil
+	case "playlist-unshuffle":
+		if len(s.unshuffled) == len(s.playlist) {
+			current := ""
+			if s.playPos >= 0 {
+				current = s.playlist[s.playPos]
+			}
+			s.playlist = s.unshuffled
+			for num, file := range s.playlist {
+				if s.playPos >= 0 && file == current {
+					s.playPos = num
+					s.setLocked("playlist-pos", num)
+					break
+				}
+			}
+		}
+		s.unshuffled = nil
+		return nil, nil, nil
+	case "playlist-play-index":
+		if len(args) == 0 {
+			return nil, nil, fmt.Errorf("invalid parameter")
+		}
+		num, err := argFloat(args[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		if int(num) < 0 || int(num) >= len(s.playlist) {
+			return nil, nil, fmt.Errorf("error running command")
+		}
+		return nil, s.playIndexLocked(int(num)), nil
+	case "stop":
+		return nil, s.stopLocked(), nil
+	case "observe_property":
+		if len(args) < 2 {
+			return nil, nil, fmt.Errorf("invalid parameter")
+		}
+		num, err := argFloat(args[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		name, err := argString(1)
+		if err != nil {
+			return nil, nil, err
+		}
+		if observed, ok := s.conns[conn]; ok {
+			observed[int64(num)] = name
+		}
+		s.queueLocked(conn, propertyChange(int64(num), name, s.props[name]))
+		return nil, nil, nil
+	case "unobserve_property":
+		if len(args) == 0 {
+			return nil, nil, fmt.Errorf("invalid parameter")
+		}
+		num, err := argFloat(args[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		if observed, ok := s.conns[conn]; ok {
+			delete(observed, int64(num))
+		}
+		return nil, nil, nil
+	case "quit":
+		return nil, []map[string]interface{}{{"event": "shutdown"}}, nil
+	default:
+		return nil, nil, fmt.Errorf("invalid parameter")
+	}
+}
+
+// loadLocked loads the file into the playlist, s.mu must be held
+func (s *Server) loadLocked(file string, mode string) []map[string]interface{} {
+	var events []map[string]interface{}
+	switch mode {
+	case "append", "append-play":
+		s.playlist = append(s.playlist, file)
+		s.setLocked("playlist-count", len(s.playlist))
+		if s.playPos >= 0 || mode == "append" {
+			return nil
+		}
+		events = append(events, s.startLocked(len(s.playlist)-1)...)
+	default:
+		if s.playPos >= 0 {
+			events = append(events, endFile(s.playPos, "stop"))
+		}
+		s.playlist = []string{file}
+		s.setLocked("playlist-count", 1)
+		events = append(events, s.startLocked(0)...)
+	}
+	return events
+}
+
+// startLocked starts playing the playlist entry at index, s.mu must be held
+func (s *Server) startLocked(index int) []map[string]interface{} {
+	file := s.playlist[index]
+	if fileError, ok := s.loadErrs[file]; ok {
+		events := []map[string]interface{}{
+			{"event": "start-file", "playlist_entry_id": index + 1},
+			{"event": "end-file", "reason": "error", "file_error": fileError, "playlist_entry_id": index + 1},
+		}
+		s.playlist = append(s.playlist[:index], s.playlist[index+1:]...)
+		s.setLocked("playlist-count", len(s.playlist))
+		if index < len(s.playlist) {
+			return append(events, s.startLocked(index)...)
+		}
+		return append(events, s.idleLocked()...)
+	}
+	s.playPos = index
+	s.setLocked("playlist-pos", index)
+	s.setLocked("path", file)
+	s.setLocked("filename", filepath.Base(file))
+	s.setLocked("media-title", filepath.Base(file))
+	s.setLocked("idle-active", false)
+	s.setLocked("eof-reached", false)
+	s.setLocked("time-pos", float64(0))
+	s.setLocked("playback-time", float64(0))
+	s.setLocked("metadata", map[string]interface{}{})
+	s.setLocked("filtered-metadata", map[string]interface{}{})
+	return []map[string]interface{}{
+		{"event": "start-file", "playlist_entry_id": index + 1},
+		{"event": "file-loaded"},
+	}
+}
+
+// playIndexLocked ends the current entry and starts the playlist entry at index, s.mu must be held
+func (s *Server) playIndexLocked(index int) []map[string]interface{} {
+	var events []map[string]interface{}
+	if s.playPos >= 0 {
+		events = append(events, endFile(s.playPos, "stop"))
+	}
+	return append(events, s.startLocked(index)...)
+}
+
+// propertyLocked returns the property value, computing the playlist one, s.mu must be held
+func (s *Server) propertyLocked(name string) (interface{}, bool) {
+	if name != "playlist" {
+		value, ok := s.props[name]
+		return value, ok
+	}
+	entries := make([]interface{}, 0, len(s.playlist))
+	for num, file := range s.playlist {
+		entry := map[string]interface{}{"filename": file, "id": num + 1}
+		if num == s.playPos {
+			entry["current"] = true
+			entry["playing"] = true
+		}
+		entries = append(entries, entry)
+	}
+	return entries, true
+}
+
+// removeLocked removes the playlist entry at index, s.mu must be held
+func (s *Server) removeLocked(index int) []map[string]interface{} {
+	s.playlist = append(s.playlist[:index], s.playlist[index+1:]...)
+	s.setLocked("playlist-count", len(s.playlist))
+	if index == s.playPos {
+		events := []map[string]interface{}{endFile(index, "stop")}
+		if index < len(s.playlist) {
+			return append(events, s.startLocked(index)...)
+		}
+		return append(events, s.idleLocked()...)
+	}
+	if index < s.playPos {
+		s.playPos--
+		s.setLocked("playlist-pos", s.playPos)
+	}
+	return nil
+}
+
+// stopLocked stops playback and clears the playlist, s.mu must be held
+func (s *Server) stopLocked() []map[string]interface{} {
+	var events []map[string]interface{}
+	if s.playPos >= 0 {
+		events = append(events, endFile(s.playPos, "stop"))
+	}
+	s.playlist = nil
+	s.setLocked("playlist-count", 0)
+	return append(events, s.idleLocked()...)
+}
+
+// idleLocked resets the playback properties, s.mu must be held
+func (s *Server) idleLocked() []map[string]interface{} {
+	s.playPos = -1
+	s.setLocked("playlist-pos", -1)
+	for _, name := range []string{"path", "filename", "media-title", "time-pos", "playback-time", "metadata", "filtered-metadata"} {
+		s.setLocked(name, nil)
+	}
+	s.setLocked("idle-active", true)
+	return []map[string]interface{}{{"event": "idle"}}
+}
+
+// endFile returns the end-file event content
+func endFile(index int, reason string) map[string]interface{} {
+	return map[string]interface{}{"event": "end-file", "reason": reason, "playlist_entry_id": index + 1}
+}
+
+// broadcastLocked queues the content for every connection, s.mu must be held
+func (s *Server) broadcastLocked(content map[string]interface{}) {
+	for conn := range s.conns {
+		s.queueLocked(conn, content)
+	}
+}
+
+// queueLocked queues the content for the connection, s.mu must be held
+func (s *Server) queueLocked(conn net.Conn, content map[string]interface{}) {
+	s.outbox = append(s.outbox, message{conn: conn, content: content})
+}
+
+// flush writes the queued json content lines in order
+func (s *Server) flush() {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.mu.Lock()
+	msgs := s.outbox
+	s.outbox = nil
+	s.mu.Unlock()
+	for _, msg := range msgs {
+		data, err := json.Marshal(msg.content)
+		if err != nil {
+			continue
+		}
+		msg.conn.Write(append(data, '\n'))
+	}
+}
+
+// argFloat returns the numeric command argument
+func argFloat(arg interface{}) (float64, error) {
+	switch value := arg.(type) {
+	case float64:
+		return value, nil
+	case string:
+		return strconv.ParseFloat(value, 64)
+	default:
+		return 0, fmt.Errorf("invalid parameter")
+	}
+}
+
+// formatProperty formats the property value like mpv get_property_string
+func formatProperty(value interface{}) string {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return "yes"
+		}
+		return "no"
+	case float64:
+		return strconv.FormatFloat(v, 'f', 6, 64)
+	case int:
+		return strconv.Itoa(v)
+	case string:
+		return v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+}
@@ -0,0 +1,307 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package playlist
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Entry data type
+type Entry struct {
+	Title      string
+	Annotation string
+	Location   string
+}
+
+// xspfPlaylist data type
+type xspfPlaylist struct {
+	XMLName xml.Name    `xml:"playlist"`
+	Version string      `xml:"version,attr"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	Title   string      `xml:"title,omitempty"`
+	Tracks  []xspfTrack `xml:"trackList>track"`
+}
+
+// xspfTrack data type
+type xspfTrack struct {
+	Location   string `xml:"location"`
+	Title      string `xml:"title,omitempty"`
+	Annotation string `xml:"annotation,omitempty"`
+}
+
+// Formats the supported playlist formats
+var Formats = []string{"m3u", "pls", "xspf"}
+
+// IsFormat checks if the format is a supported playlist format
+func IsFormat(format string) bool {
+	for _, item := range Formats {
+		if item == format {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPlaylist checks if the file has a supported playlist extension
+func IsPlaylist(file string) bool {
+	_, err := formatOf(file)
+	return err == nil
+}
+
+// formatOf returns the playlist format of the file extension
+func formatOf(file string) (string, error) {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".m3u", ".m3u8":
+		return "m3u", nil
+	case ".pls":
+		return "pls", nil
+	case ".xspf":
+		return "xspf", nil
+	default:
+		return "", fmt.Errorf("playlist: error: '%s' unsupported playlist format\n", file)
+	}
+}
+
+// ParseFile parses the playlist file, relative entries are resolved against the file directory
+func ParseFile(file string) ([]Entry, error) {
+	format, errFo := formatOf(file)
+	if errFo != nil {
+		return nil, errFo
+	}
+	fh, errOo := os.Open(file)
+	if errOo != nil {
+		return nil, errOo
+	}
+	defer fh.Close()
+	fileAbs, errFa := filepath.Abs(file)
+	if errFa != nil {
+		return nil, errFa
+	}
+	return Parse(fh, format, filepath.Dir(fileAbs))
+}
+
+// Parse parses the playlist in format, relative entries are resolved against base
+func Parse(r io.Reader, format string, base string) ([]Entry, error) {
+	var (
+		entries []Entry
+		err     error
+	)
+	switch format {
+	case "m3u":
+		entries, err = parseM3U(r)
+	case "pls":
+		entries, err = parsePLS(r)
+	case "xspf":
+		entries, err = parseXSPF(r)
+	default:
+		return nil, fmt.Errorf("playlist: error: unsupported format '%s'\n", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for num := range entries {
+		entries[num].Location = resolve(entries[num].Location, base)
+	}
+	return entries, nil
+}
+
+// resolve returns the location as an url or an absolute path
+func resolve(location string, base string) string {
+	if u, err := url.Parse(location); err == nil && u.Scheme != "" && len(u.Scheme) > 1 {
+		if u.Scheme == "file" {
+			return u.Path
+		}
+		return location
+	}
+	if filepath.IsAbs(location) || base == "" {
+		return location
+	}
+	if u, err := url.Parse(base); err == nil && u.Scheme != "" && len(u.Scheme) > 1 {
+		if ref, errUp := url.Parse(location); errUp == nil {
+			return u.ResolveReference(ref).String()
+		}
+	}
+	return filepath.Join(base, filepath.FromSlash(strings.ReplaceAll(location, `\`, "/")))
+}
+
+// lines returns the text lines without bom, carriage returns and surrounding spaces
+func lines(r io.Reader) ([]string, error) {
+	var items []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		items = append(items, line)
+	}
+	return items, scanner.Err()
+}
+
+// parseM3U parses the m3u playlist, #EXTINF titles are assigned to the next entry
+func parseM3U(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	items, err := lines(r)
+	if err != nil {
+		return nil, err
+	}
+	title := ""
+	for _, line := range items {
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(strings.ToUpper(line), "#EXTINF:"):
+			title = extinfTitle(line[len("#EXTINF:"):])
+		case strings.HasPrefix(line, "#"):
+			continue
+		default:
+			entries = append(entries, Entry{Title: title, Location: line})
+			title = ""
+		}
+	}
+	return entries, nil
+}
+
+// extinfTitle returns the title after the first comma outside of quoted attributes
+func extinfTitle(info string) string {
+	quoted := false
+	for num, c := range info {
+		switch c {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				return strings.TrimSpace(info[num+1:])
+			}
+		}
+	}
+	return ""
+}
+
+// parsePLS parses the pls playlist, keys are case insensitive and entries are ordered by their number
+func parsePLS(r io.Reader) ([]Entry, error) {
+	items, err := lines(r)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[int]string)
+	titles := make(map[int]string)
+	for _, line := range items {
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		for _, prefix := range []string{"file", "title"} {
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			num, errSa := strconv.Atoi(key[len(prefix):])
+			if errSa != nil {
+				continue
+			}
+			if prefix == "file" {
+				files[num] = value
+			} else {
+				titles[num] = value
+			}
+		}
+	}
+	nums := make([]int, 0, len(files))
+	for num := range files {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	entries := make([]Entry, 0, len(nums))
+	for _, num := range nums {
+		if files[num] == "" {
+			continue
+		}
+		entries = append(entries, Entry{Title: titles[num], Location: files[num]})
+	}
+	return entries, nil
+}
+
+// parseXSPF parses the xspf playlist
+func parseXSPF(r io.Reader) ([]Entry, error) {
+	var xp xspfPlaylist
+	if err := xml.NewDecoder(r).Decode(&xp); err != nil {
+		return nil, fmt.Errorf("playlist: error: invalid xspf: %s\n", err.Error())
+	}
+	entries := make([]Entry, 0, len(xp.Tracks))
+	for _, track := range xp.Tracks {
+		location := strings.TrimSpace(track.Location)
+		if location == "" {
+			continue
+		}
+		if u, err := url.Parse(location); err == nil && u.Scheme == "file" {
+			location = u.Path
+		}
+		entries = append(entries, Entry{
+			Title:      strings.TrimSpace(track.Title),
+			Annotation: strings.TrimSpace(track.Annotation),
+			Location:   location,
+		})
+	}
+	return entries, nil
+}
+
+// Write writes the entries as a playlist in format
+func Write(w io.Writer, format string, title string, entries []Entry) error {
+	var buf bytes.Buffer
+	switch format {
+	case "m3u":
+		buf.WriteString("#EXTM3U\n")
+		for _, entry := range entries {
+			buf.WriteString(fmt.Sprintf("#EXTINF:-1,%s\n%s\n", oneLine(entry.Title), oneLine(entry.Location)))
+		}
+	case "pls":
+		buf.WriteString("[playlist]\n")
+		for num, entry := range entries {
+			buf.WriteString(fmt.Sprintf("File%d=%s\n", num+1, oneLine(entry.Location)))
+			if entry.Title != "" {
+				buf.WriteString(fmt.Sprintf("Title%d=%s\n", num+1, oneLine(entry.Title)))
+			}
+			buf.WriteString(fmt.Sprintf("Length%d=-1\n", num+1))
+		}
+		buf.WriteString(fmt.Sprintf("NumberOfEntries=%d\nVersion=2\n", len(entries)))
+	case "xspf":
+		xp := xspfPlaylist{Version: "1", Xmlns: "http://xspf.org/ns/0/", Title: title}
+		for _, entry := range entries {
+			location := entry.Location
+			if filepath.IsAbs(location) {
+				location = (&url.URL{Scheme: "file", Path: location}).String()
+			}
+			xp.Tracks = append(xp.Tracks, xspfTrack{Location: location, Title: entry.Title, Annotation: entry.Annotation})
+		}
+		buf.WriteString(xml.Header)
+		encoder := xml.NewEncoder(&buf)
+		encoder.Indent("", "  ")
+		if err := encoder.Encode(xp); err != nil {
+			return err
+		}
+		buf.WriteString("\n")
+	default:
+		return fmt.Errorf("playlist: error: unsupported format '%s'\n", format)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// oneLine replaces the line breaks that would split a playlist line
+func oneLine(str string) string {
+	return strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(str)
+}
@@ -0,0 +1,87 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package playlist
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseM3U(t *testing.T) {
+	data := "\ufeff#EXTM3U\r\n" +
+		"#EXTINF:-1 tvg-name=\"a, b\" group-title=\"x\",Radio One\r\n" +
+		"https://example.org/one\r\n" +
+		"\n" +
+		"# comment\n" +
+		"music/two.mp3\n" +
+		"#EXTINF:-1,\n" +
+		"/abs/three.ogg\n"
+	entries, err := Parse(strings.NewReader(data), "m3u", "/base")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Entry{
+		{Title: "Radio One", Location: "https://example.org/one"},
+		{Location: "/base/music/two.mp3"},
+		{Location: "/abs/three.ogg"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+}
+
+func TestParsePLS(t *testing.T) {
+	data := "[Playlist]\n" +
+		"NumberOfEntries=2\n" +
+		"title3=Third\n" +
+		"file3=https://example.org/three\n" +
+		"File0=https://example.org/zero\n" +
+		"FILE1 = https://example.org/one\n" +
+		"Title1=First\n" +
+		"Length1=-1\n" +
+		"Title7=No file\n" +
+		"Version=2\n"
+	entries, err := Parse(strings.NewReader(data), "pls", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Entry{
+		{Location: "https://example.org/zero"},
+		{Title: "First", Location: "https://example.org/one"},
+		{Title: "Third", Location: "https://example.org/three"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+}
+
+func TestWriteParse(t *testing.T) {
+	entries := []Entry{
+		{Title: "One", Annotation: "icy one", Location: "https://example.org/one"},
+		{Title: "Two & <more>", Location: "https://example.org/two?a=1&b=2"},
+	}
+	for _, format := range Formats {
+		var buf bytes.Buffer
+		if err := Write(&buf, format, "test", entries); err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := Parse(&buf, format, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(parsed) != len(entries) {
+			t.Fatalf("%s: unexpected entries %+v", format, parsed)
+		}
+		for num, entry := range parsed {
+			if entry.Title != entries[num].Title || entry.Location != entries[num].Location {
+				t.Fatalf("%s: unexpected entry %+v", format, entry)
+			}
+			if format == "xspf" && entry.Annotation != entries[num].Annotation {
+				t.Fatalf("%s: unexpected annotation %+v", format, entry)
+			}
+		}
+	}
+}
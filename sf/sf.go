@@ -237,21 +237,45 @@ func (sf *selectFile) doActionEnter() error {
 		sf.oldPwd = sf.pwd
 		sf.actionLoop = false
 	} else {
-		if errPl := gorum.Play(curFileName.Name()); errPl != nil {
-			return errPl
+		command := "play"
+		if pos, ok, errRp := gorum.ResumePosition(curFileName.Name()); errRp == nil && ok && !config.Resume {
+			resume, errAr := sf.askResume(pos)
+			if errAr != nil {
+				return errAr
+			}
+			if resume {
+				command = "resume"
+			}
 		}
-		cmd := `{"command": ["get_property", "filtered-metadata"]}`
-		if _, errSc := gorum.StatusCmd(cmd, "error", config.MinStatusTries); errSc != nil {
-			log.Print(errSc)
+		if errPw := gorum.Control(nil, command, curFileName.Name()); errPw != nil {
+			log.Print(errPw)
 			cursor.Move((sf.linesHeader+sf.linesBody+sf.linesFooter)-1, 1)
 			cursor.ClearCurLine()
-			utils.ErrPrintf("# %s", errSc.Error())
+			utils.ErrPrintf("# %s", strings.TrimRight(errPw.Error(), "\n"))
 			cursor.Move(sf.curPos, sf.padInt+1)
 		}
 	}
 	return nil
 }
 
+// askResume asks in the footer line whether to resume the file from its saved position
+func (sf *selectFile) askResume(pos float64) (bool, error) {
+	cursor.Move((sf.linesHeader+sf.linesBody+sf.linesFooter)-1, 1)
+	cursor.ClearCurLine()
+	fmt.Printf("# resume from %s? [y/N]", gorum.FormatPosition(pos))
+	key, errKp := utils.KeyPress()
+	if errKp != nil {
+		return false, errKp
+	}
+	keyName, errKn := utils.KeyPressName(key)
+	if errKn != nil {
+		return false, errKn
+	}
+	cursor.ClearCurLine()
+	cursor.Move(sf.curPos, sf.padInt+1)
+	return keyName == "y" || keyName == "Y", nil
+}
+
 // doActionHelp executes the help sf option
 func (sf *selectFile) doActionHelp() error {
 	cursor.Move((sf.linesHeader+sf.linesBody+sf.linesFooter)-1, 1)
@@ -0,0 +1,285 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package stations
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+	"github.com/gonzaru/gorum/icy"
+	"github.com/gonzaru/gorum/utils"
+)
+
+// Health data type, the probe result of a station stream
+type Health struct {
+	Id          int      `json:"id"`
+	Name        string   `json:"name"`
+	Url         string   `json:"url"`
+	Alive       bool     `json:"alive"`
+	Error       string   `json:"error,omitempty"`
+	ContentType string   `json:"contentType,omitempty"`
+	Bitrate     int      `json:"bitrate,omitempty"`
+	NameIcy     string   `json:"nameIcy,omitempty"`
+	ServerName  string   `json:"serverName,omitempty"`
+	NameMatch   bool     `json:"nameMatch"`
+	Title       string   `json:"title,omitempty"`
+	Latency     int64    `json:"latencyMs"`
+	Mirrors     []Health `json:"mirrors,omitempty"`
+}
+
+// checkWorkers the maximum number of stations probed at the same time
+const checkWorkers = 8
+
+// Check probes the stations streams and their mirrors concurrently, each one during timeout at most
+func Check(ids []int, timeout time.Duration) []Health {
+	client := icy.Client(timeout)
+	results, mirrorResults := make([]Health, len(ids)), make([][]Health, len(ids))
+	// the job of the station url is its mirror number 0
+	type job struct {
+		num    int
+		mirror int
+	}
+	var all []job
+	streams, _ := config.Stations()
+	urls := make([][]string, len(ids))
+	for num, id := range ids {
+		if urls[num] = config.StationUrls(id); len(urls[num]) == 0 {
+			urls[num] = []string{""}
+		}
+		all = append(all, job{num, 0})
+		if mirrors := urls[num][1:]; len(mirrors) > 0 {
+			mirrorResults[num] = make([]Health, len(mirrors))
+			for mirror := range mirrors {
+				all = append(all, job{num, mirror + 1})
+			}
+		}
+	}
+	jobs := make(chan job)
+	var wg sync.WaitGroup
+	for worker := 0; worker < checkWorkers && worker < len(all); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				id := ids[j.num]
+				health := probe(client, id, streams[id], urls[j.num][j.mirror], timeout)
+				if j.mirror == 0 {
+					results[j.num] = health
+				} else {
+					mirrorResults[j.num][j.mirror-1] = health
+				}
+			}
+		}()
+	}
+	for _, j := range all {
+		jobs <- j
+	}
+	close(jobs)
+	wg.Wait()
+	for num := range results {
+		results[num].Mirrors = mirrorResults[num]
+	}
+	return results
+}
+
+// probe requests the station stream url with icy metadata, reading its headers and its first metadata block
+func probe(client *http.Client, id int, stream map[string]string, streamUrl string, timeout time.Duration) Health {
+	health := Health{Id: id, Name: stream["name"], Url: streamUrl, NameIcy: stream["nameIcy"]}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, errNr := http.NewRequestWithContext(ctx, http.MethodGet, health.Url, nil)
+	if errNr != nil {
+		health.Error = errNr.Error()
+		return health
+	}
+	req.Header.Set("Icy-MetaData", "1")
+	req.Header.Set("User-Agent", config.ProgName)
+	start := time.Now()
+	resp, errCd := client.Do(req)
+	if errCd != nil {
+		health.Error = errCd.Error()
+		return health
+	}
+	defer resp.Body.Close()
+	health.Latency = time.Since(start).Milliseconds()
+	health.ContentType, _, _ = mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	health.Bitrate, _ = strconv.Atoi(strings.SplitN(resp.Header.Get("icy-br"), ",", 2)[0])
+	health.ServerName = strings.TrimSpace(resp.Header.Get("icy-name"))
+	health.NameMatch = health.NameIcy == "" || strings.EqualFold(health.NameIcy, health.ServerName)
+	if resp.StatusCode != http.StatusOK {
+		health.Error = resp.Status
+		return health
+	}
+	metaInt, _ := strconv.Atoi(resp.Header.Get("icy-metaint"))
+	rd := bufio.NewReader(resp.Body)
+	if metaInt <= 0 {
+		if _, errRb := rd.ReadByte(); errRb != nil {
+			health.Error = fmt.Sprintf("reading the stream: %s", errRb)
+			return health
+		}
+		health.Alive = true
+		return health
+	}
+	if _, errCd := io.CopyN(io.Discard, rd, int64(metaInt)); errCd != nil {
+		health.Error = fmt.Sprintf("reading the stream: %s", errCd)
+		return health
+	}
+	meta, errRm := icy.ReadMeta(rd)
+	if errRm != nil {
+		health.Error = fmt.Sprintf("reading the metadata: %s", errRm)
+		return health
+	}
+	health.Title, _ = icy.StreamTitle(meta)
+	health.Alive = true
+	return health
+}
+
+// checkFlags parses the check arguments, it returns the station ids, the probe timeout and if the results are json
+func checkFlags(output io.Writer, args []string) ([]int, time.Duration, bool, error) {
+	fs := flag.NewFlagSet("stations check", flag.ContinueOnError)
+	fs.SetOutput(output)
+	asJson := fs.Bool("json", false, "writes the results as json")
+	timeout := fs.Duration("timeout", config.PlayTimeout, "maximum time to probe a station")
+	if errFp := fs.Parse(args); errFp != nil {
+		return nil, 0, false, errFp
+	}
+	if *timeout <= 0 {
+		return nil, 0, false, fmt.Errorf("stations: error: timeout '%s' must be greater than 0\n", *timeout)
+	}
+	streams, _ := config.Stations()
+	ids := Ids(streams)
+	if fs.NArg() > 0 {
+		ids = nil
+		for _, arg := range fs.Args() {
+			id, errPi := parseId(streams, arg)
+			if errPi != nil {
+				return nil, 0, false, errPi
+			}
+			ids = append(ids, id)
+		}
+	}
+	return ids, *timeout, *asJson, nil
+}
+
+// CheckDuration returns the maximum time the check of the arguments takes, 0 when they are not valid
+func CheckDuration(args []string) time.Duration {
+	ids, timeout, _, errCf := checkFlags(io.Discard, args)
+	if errCf != nil {
+		return 0
+	}
+	probes := 0
+	for _, id := range ids {
+		if urls := config.StationUrls(id); len(urls) > 1 {
+			probes += len(urls)
+		} else {
+			probes++
+		}
+	}
+	rounds := (probes + checkWorkers - 1) / checkWorkers
+	return time.Duration(rounds) * timeout
+}
+
+// check checks the stations streams, all of them by default
+func check(w io.Writer, args []string) error {
+	ids, timeout, asJson, errCf := checkFlags(w, args)
+	if errCf != nil {
+		return errCf
+	}
+	results := Check(ids, timeout)
+	if asJson {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "    ")
+		if errJe := enc.Encode(results); errJe != nil {
+			return errJe
+		}
+	} else if errWh := writeHealth(w, results); errWh != nil {
+		return errWh
+	}
+	dead, deadMirrors, mirrors := 0, 0, 0
+	for _, health := range results {
+		if !health.Alive {
+			dead++
+		}
+		for _, mirror := range health.Mirrors {
+			mirrors++
+			if !mirror.Alive {
+				deadMirrors++
+			}
+		}
+	}
+	var deads []string
+	if dead > 0 {
+		deads = append(deads, fmt.Sprintf("%d of %d stations", dead, len(results)))
+	}
+	if deadMirrors > 0 {
+		deads = append(deads, fmt.Sprintf("%d of %d mirrors", deadMirrors, mirrors))
+	}
+	if len(deads) > 0 {
+		return fmt.Errorf("stations: error: %s are dead\n", strings.Join(deads, " and "))
+	}
+	return nil
+}
+
+// writeHealth writes the probe results as a table
+func writeHealth(w io.Writer, results []Health) error {
+	if len(results) == 0 {
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	maxId := 0
+	for _, health := range results {
+		if health.Id > maxId {
+			maxId = health.Id
+		}
+	}
+	numPad := strconv.Itoa(utils.CountDigit(maxId))
+	for _, health := range results {
+		state, details := healthDetails(health)
+		if _, errFp := fmt.Fprintf(tw, "%"+numPad+"d)\t%s\t%s\t%s\n", health.Id, health.Name, state, details); errFp != nil {
+			return errFp
+		}
+		for _, mirror := range health.Mirrors {
+			state, details := healthDetails(mirror)
+			indent := strings.Repeat(" ", utils.CountDigit(maxId)+1)
+			if _, errFp := fmt.Fprintf(tw, "%s\t  mirror %s\t%s\t%s\n", indent, mirror.Url, state, details); errFp != nil {
+				return errFp
+			}
+		}
+	}
+	return tw.Flush()
+}
+
+// healthDetails returns the probe state and its details
+func healthDetails(health Health) (string, string) {
+	state, details := "ok", []string{fmt.Sprintf("%dms", health.Latency)}
+	if !health.Alive {
+		state = "dead"
+		details = []string{health.Error}
+	}
+	if health.ContentType != "" {
+		details = append(details, health.ContentType)
+	}
+	if health.Bitrate > 0 {
+		details = append(details, fmt.Sprintf("%dkbps", health.Bitrate))
+	}
+	if !health.NameMatch {
+		details = append(details, fmt.Sprintf("icy name '%s' differs from '%s'", health.ServerName, health.NameIcy))
+	}
+	return state, strings.Join(details, ", ")
+}
@@ -0,0 +1,132 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package stations
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+)
+
+func TestCheck(t *testing.T) {
+	setUpTest(t)
+	meta := "StreamTitle='Artist - Song';"
+	block := append([]byte{2}, meta+strings.Repeat("\x00", 32-len(meta))...)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/live":
+			if r.Header.Get("Icy-MetaData") != "1" {
+				t.Errorf("expected icy metadata requested")
+			}
+			w.Header().Set("Content-Type", "audio/mpeg")
+			w.Header().Set("icy-br", "128")
+			w.Header().Set("icy-name", "Live Radio")
+			w.Header().Set("icy-metaint", "8")
+			w.Write(append(bytes.Repeat([]byte("A"), 8), block...))
+		case "/plain":
+			w.Header().Set("Content-Type", "audio/ogg")
+			w.Write([]byte("OggS"))
+		case "/truncated":
+			w.Header().Set("icy-metaint", "8")
+			w.Write([]byte("AAAA"))
+		case "/stalled":
+			w.Header().Set("icy-metaint", "8")
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	config.SetStations(map[int]map[string]string{
+		1:  {"name": "Live", "nameIcy": "live radio", "url": srv.URL + "/live"},
+		2:  {"name": "Renamed", "nameIcy": "Old Name", "url": srv.URL + "/live"},
+		3:  {"name": "Plain", "url": srv.URL + "/plain"},
+		4:  {"name": "Gone", "url": srv.URL + "/gone"},
+		5:  {"name": "Truncated", "url": srv.URL + "/truncated"},
+		6:  {"name": "Stalled", "url": srv.URL + "/stalled"},
+		12: {"name": "Offline", "url": "http://127.0.0.1:1/"},
+	}, map[int][]string{3: {srv.URL + "/live", srv.URL + "/gone"}})
+	streams, _ := config.Stations()
+	results := Check(Ids(streams), 500*time.Millisecond)
+	if len(results) != 7 {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	live := results[0]
+	if !live.Alive || live.ContentType != "audio/mpeg" || live.Bitrate != 128 || live.ServerName != "Live Radio" ||
+		!live.NameMatch || live.Title != "Artist - Song" {
+		t.Fatalf("unexpected live station health %+v", live)
+	}
+	if renamed := results[1]; !renamed.Alive || renamed.NameMatch {
+		t.Fatalf("expected the icy name mismatch, got %+v", renamed)
+	}
+	if plain := results[2]; !plain.Alive || plain.ContentType != "audio/ogg" || !plain.NameMatch {
+		t.Fatalf("unexpected plain station health %+v", plain)
+	}
+	if mirrors := results[2].Mirrors; len(mirrors) != 2 || !mirrors[0].Alive || mirrors[0].Url != srv.URL+"/live" || mirrors[1].Alive {
+		t.Fatalf("unexpected mirrors health %+v", mirrors)
+	}
+	for _, dead := range results[3:] {
+		if dead.Alive || dead.Error == "" {
+			t.Errorf("expected station %d dead, got %+v", dead.Id, dead)
+		}
+	}
+	if gone := results[3]; gone.Error != "404 Not Found" {
+		t.Errorf("unexpected error %q", gone.Error)
+	}
+
+	var buf bytes.Buffer
+	if err := writeHealth(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 9 || !strings.HasPrefix(lines[0], " 1)  Live") || !strings.Contains(lines[0], "ok") ||
+		!strings.Contains(lines[0], "audio/mpeg, 128kbps") || !strings.Contains(lines[1], "icy name 'Live Radio' differs from 'Old Name'") ||
+		!strings.Contains(lines[4], "mirror "+srv.URL+"/gone") || !strings.Contains(lines[4], "dead") || !strings.Contains(lines[8], "dead") {
+		t.Fatalf("unexpected check output:\n%s", buf.String())
+	}
+	if err := Run(io.Discard, []string{"check", "--json", "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Run(io.Discard, []string{"check", "3"}); err == nil || !strings.Contains(err.Error(), "error: 1 of 2 mirrors are dead") {
+		t.Fatalf("expected dead mirrors error, got %v", err)
+	}
+	if err := Run(io.Discard, []string{"check", "--timeout", "500ms", "3", "4"}); err == nil || !strings.Contains(err.Error(), "1 of 2 stations and 1 of 2 mirrors are dead") {
+		t.Fatalf("expected dead stations error, got %v", err)
+	}
+}
+
+func TestCheckDuration(t *testing.T) {
+	setUpTest(t)
+	streams := make(map[int]map[string]string)
+	for id := 1; id <= checkWorkers+1; id++ {
+		streams[id] = map[string]string{"name": strconv.Itoa(id), "url": "https://example.org/" + strconv.Itoa(id)}
+	}
+	config.SetStations(streams, map[int][]string{1: {"https://mirror.example.org/1"}})
+	tests := []struct {
+		args []string
+		want time.Duration
+	}{
+		{nil, 2 * config.PlayTimeout},
+		{[]string{"--timeout", "2s"}, 4 * time.Second},
+		{[]string{"--timeout", "2s", "1", "2"}, 2 * time.Second},
+		{[]string{"--timeout", "2s", "999"}, 0},
+		{[]string{"--timeout", "0s"}, 0},
+	}
+	for _, test := range tests {
+		if got := CheckDuration(test.args); got != test.want {
+			t.Errorf("CheckDuration(%q): expected %s, got %s", test.args, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package stations
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+	"github.com/gonzaru/gorum/playlist"
+)
+
+// Skipped data type
+type Skipped struct {
+	Location string
+	Reason   string
+}
+
+// Export writes the stations as a playlist in format
+func Export(w io.Writer, format string) error {
+	var entries []playlist.Entry
+	streams, _ := config.Stations()
+	for _, id := range Ids(streams) {
+		stream := streams[id]
+		entries = append(entries, playlist.Entry{
+			Title:      stream["name"],
+			Annotation: stream["nameIcy"],
+			Location:   stream["url"],
+		})
+	}
+	return playlist.Write(w, format, config.ProgName+" stations", entries)
+}
+
+// Import adds the playlist entries as new stations and returns the skipped ones
+func Import(file string) ([]int, []Skipped, error) {
+	entries, errPf := playlist.ParseFile(file)
+	if errPf != nil {
+		return nil, nil, errPf
+	}
+	streams, mirrors, release, errEs := editStations()
+	if errEs != nil {
+		return nil, nil, errEs
+	}
+	defer release()
+	urls := make(map[string]int, len(streams))
+	for id, stream := range streams {
+		urls[stream["url"]] = id
+	}
+	nextId := 1
+	if ids := Ids(streams); len(ids) > 0 {
+		nextId = ids[len(ids)-1] + 1
+	}
+	var (
+		added   []int
+		skipped []Skipped
+	)
+	for _, entry := range entries {
+		if id, ok := urls[entry.Location]; ok {
+			skipped = append(skipped, Skipped{entry.Location, fmt.Sprintf("url already used by station '%d'", id)})
+			continue
+		}
+		stream := map[string]string{"name": entry.Title, "url": entry.Location}
+		if stream["name"] == "" {
+			stream["name"] = nameFromUrl(entry.Location)
+		}
+		if entry.Annotation != "" {
+			stream["nameIcy"] = entry.Annotation
+		}
+		if errCs := config.CheckStation(nextId, stream, nil); errCs != nil {
+			skipped = append(skipped, Skipped{entry.Location, "not a stream url"})
+			continue
+		}
+		streams[nextId] = stream
+		urls[entry.Location] = nextId
+		added = append(added, nextId)
+		nextId++
+	}
+	if len(added) > 0 {
+		if errSa := save(streams, mirrors); errSa != nil {
+			return nil, nil, errSa
+		}
+	}
+	return added, skipped, nil
+}
+
+// nameFromUrl returns a station name from the stream url host and path
+func nameFromUrl(streamUrl string) string {
+	u, errUp := url.Parse(streamUrl)
+	if errUp != nil || u.Host == "" {
+		return streamUrl
+	}
+	return strings.TrimSuffix(u.Host+u.Path, "/")
+}
+
+// export exports the stations as a playlist
+func export(w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("stations export", flag.ContinueOnError)
+	fs.SetOutput(w)
+	format := fs.String("format", "m3u", "playlist format: "+strings.Join(playlist.Formats, "|"))
+	output := fs.String("output", "", "output file, the standard output by default")
+	if errFp := fs.Parse(args); errFp != nil {
+		return errFp
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("stations: error: unexpected argument '%s'\n", fs.Arg(0))
+	}
+	if !playlist.IsFormat(*format) {
+		return fmt.Errorf("stations: error: unsupported format '%s', use %s\n", *format, strings.Join(playlist.Formats, "|"))
+	}
+	if *output == "" {
+		return Export(w, *format)
+	}
+	fh, errOc := os.Create(*output)
+	if errOc != nil {
+		return errOc
+	}
+	if errEx := Export(fh, *format); errEx != nil {
+		fh.Close()
+		return errEx
+	}
+	if errFc := fh.Close(); errFc != nil {
+		return errFc
+	}
+	streams, _ := config.Stations()
+	fmt.Fprintf(w, "stations: info: exported %d stations to %s\n", len(streams), *output)
+	return nil
+}
+
+// importFile imports the stations from a playlist file
+func importFile(w io.Writer, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("stations: error: usage: %s stations import file\n", config.ProgName)
+	}
+	added, skipped, errIm := Import(args[0])
+	if errIm != nil {
+		return errIm
+	}
+	streams, _ := config.Stations()
+	for _, id := range added {
+		fmt.Fprintf(w, "stations: info: added station '%d' %s\n", id, streams[id]["name"])
+	}
+	for _, skip := range skipped {
+		fmt.Fprintf(w, "stations: info: skipped '%s': %s\n", skip.Location, skip.Reason)
+	}
+	fmt.Fprintf(w, "stations: info: imported %d stations, skipped %d\n", len(added), len(skipped))
+	return nil
+}
@@ -0,0 +1,388 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package stations
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+	"github.com/gonzaru/gorum/utils"
+)
+
+// editMu serializes the stations changes of this process, the stations file lock serializes them with other processes
+var editMu sync.Mutex
+
+// Help writes the stations help information
+func Help(w io.Writer) {
+	progName := config.ProgName
+	fmt.Fprint(w, "Usage:\n")
+	fmt.Fprintf(w, "  %s stations list                # lists the stations [ls]\n", progName)
+	fmt.Fprintf(w, "  %s stations add --name n --url u [--name-icy i] [--mirror u2]... [--id n]\n", progName)
+	fmt.Fprintf(w, "  %s                              # adds a station, the next free id by default\n", strings.Repeat(" ", len(progName)))
+	fmt.Fprintf(w, "  %s stations edit id [--name n] [--url u] [--name-icy i] [--mirror u2]...\n", progName)
+	fmt.Fprintf(w, "  %s                              # edits the station fields, --mirror '' removes the mirrors\n", strings.Repeat(" ", len(progName)))
+	fmt.Fprintf(w, "  %s stations rename id name      # renames a station\n", progName)
+	fmt.Fprintf(w, "  %s stations move id newid       # changes a station id [mv]\n", progName)
+	fmt.Fprintf(w, "  %s stations remove id           # removes a station [rm]\n", progName)
+	fmt.Fprintf(w, "  %s stations check [--json] [--timeout 10s] [id...]\n", progName)
+	fmt.Fprintf(w, "  %s                              # probes the stations streams and mirrors, fails when some are dead\n", strings.Repeat(" ", len(progName)))
+	fmt.Fprintf(w, "  %s stations import file         # imports the stations from a m3u, pls or xspf playlist\n", progName)
+	fmt.Fprintf(w, "  %s stations export [--format m3u|pls|xspf] [--output file]\n", progName)
+	fmt.Fprintf(w, "  %s                              # exports the stations as a playlist\n", strings.Repeat(" ", len(progName)))
+}
+
+// Run runs the stations subcommand writing its output to w
+func Run(w io.Writer, args []string) error {
+	if len(args) == 0 {
+		return List(w)
+	}
+	switch args[0] {
+	case "list", "ls":
+		return List(w)
+	case "add":
+		return add(w, args[1:])
+	case "edit":
+		return edit(w, args[1:])
+	case "rename":
+		return rename(w, args[1:])
+	case "move", "mv":
+		return move(w, args[1:])
+	case "remove", "rm":
+		return remove(w, args[1:])
+	case "check":
+		return check(w, args[1:])
+	case "import":
+		return importFile(w, args[1:])
+	case "export":
+		return export(w, args[1:])
+	case "help":
+		Help(w)
+		return nil
+	default:
+		Help(w)
+		return fmt.Errorf("stations: error: unknown command '%s'\n", args[0])
+	}
+}
+
+// Ids returns the sorted numeric station ids
+func Ids(streams map[int]map[string]string) []int {
+	ids := make([]int, 0, len(streams))
+	for id := range streams {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// List writes the stations list
+func List(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	streams, _ := config.Stations()
+	ids := Ids(streams)
+	if len(ids) == 0 {
+		return nil
+	}
+	numPad := strconv.Itoa(utils.CountDigit(ids[len(ids)-1]))
+	for _, id := range ids {
+		stream := streams[id]
+		if _, errFp := fmt.Fprintf(tw, "%"+numPad+"d)\t%s\t%s\n", id, stream["name"], stream["url"]); errFp != nil {
+			return errFp
+		}
+	}
+	return tw.Flush()
+}
+
+// urlsFlag data type, a flag given once per url
+type urlsFlag []string
+
+// String returns the urls separated by spaces
+func (u *urlsFlag) String() string {
+	return strings.Join(*u, " ")
+}
+
+// Set adds the url, an empty url removes the previous ones
+func (u *urlsFlag) Set(value string) error {
+	if value == "" {
+		*u = nil
+		return nil
+	}
+	*u = append(*u, value)
+	return nil
+}
+
+// copyStreams returns a deep copy of the streams
+func copyStreams(streams map[int]map[string]string) map[int]map[string]string {
+	dup := make(map[int]map[string]string, len(streams))
+	for id, stream := range streams {
+		dup[id] = make(map[string]string, len(stream))
+		for key, value := range stream {
+			dup[id][key] = value
+		}
+	}
+	return dup
+}
+
+// copyMirrors returns a deep copy of the stations mirror urls
+func copyMirrors(mirrors map[int][]string) map[int][]string {
+	dup := make(map[int][]string, len(mirrors))
+	for id, urls := range mirrors {
+		dup[id] = append([]string{}, urls...)
+	}
+	return dup
+}
+
+// editStations locks the stations changes and returns a copy of the saved stations to change, release unlocks them
+func editStations() (map[int]map[string]string, map[int][]string, func(), error) {
+	editMu.Lock()
+	unlock, errLu := config.LockUpdate(config.StationsFile)
+	if errLu != nil {
+		editMu.Unlock()
+		return nil, nil, nil, errLu
+	}
+	release := func() {
+		unlock()
+		editMu.Unlock()
+	}
+	// another process may have changed the stations file since it was loaded
+	streams, mirrors, errLs := config.LoadStations(config.StationsFile)
+	if errLs != nil {
+		release()
+		return nil, nil, nil, errLs
+	}
+	if streams == nil {
+		streams, mirrors = config.Stations()
+	}
+	return copyStreams(streams), copyMirrors(mirrors), release, nil
+}
+
+// parseId returns the existing station id
+func parseId(streams map[int]map[string]string, arg string) (int, error) {
+	id, errSa := strconv.Atoi(arg)
+	if errSa != nil {
+		return 0, fmt.Errorf("stations: error: '%s' is not a station id\n", arg)
+	}
+	if _, ok := streams[id]; !ok {
+		return 0, fmt.Errorf("stations: error: station '%d' not found\n", id)
+	}
+	return id, nil
+}
+
+// urlUnused checks that no station other than except uses the stream url
+func urlUnused(streams map[int]map[string]string, streamUrl string, except int) error {
+	for key, stream := range streams {
+		if key != except && stream["url"] == streamUrl {
+			return fmt.Errorf("stations: error: url '%s' already used by station '%d'\n", streamUrl, key)
+		}
+	}
+	return nil
+}
+
+// save writes the streams and their mirror urls into the stations file and makes them the current ones
+func save(streams map[int]map[string]string, mirrors map[int][]string) error {
+	if errSs := config.SaveStations(config.StationsFile, streams, mirrors); errSs != nil {
+		return errSs
+	}
+	config.SetStations(streams, mirrors)
+	return nil
+}
+
+// add adds a new station
+func add(w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("stations add", flag.ContinueOnError)
+	fs.SetOutput(w)
+	name := fs.String("name", "", "station name")
+	nameIcy := fs.String("name-icy", "", "station icy name")
+	streamUrl := fs.String("url", "", "station stream url")
+	var mirrors urlsFlag
+	fs.Var(&mirrors, "mirror", "station mirror stream url played when the url fails, once per mirror")
+	id := fs.Int("id", 0, "station id, the next free id by default")
+	if errFp := fs.Parse(args); errFp != nil {
+		return errFp
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("stations: error: unexpected argument '%s'\n", fs.Arg(0))
+	}
+	streams, stationMirrors, release, errEs := editStations()
+	if errEs != nil {
+		return errEs
+	}
+	defer release()
+	if errUu := urlUnused(streams, *streamUrl, 0); errUu != nil {
+		return errUu
+	}
+	if *id == 0 {
+		if ids := Ids(streams); len(ids) > 0 {
+			*id = ids[len(ids)-1]
+		}
+		*id++
+	} else if _, ok := streams[*id]; ok {
+		return fmt.Errorf("stations: error: station '%d' already exists\n", *id)
+	}
+	stream := map[string]string{"name": *name, "url": *streamUrl}
+	if *nameIcy != "" {
+		stream["nameIcy"] = *nameIcy
+	}
+	if errCs := config.CheckStation(*id, stream, mirrors); errCs != nil {
+		return errCs
+	}
+	streams[*id] = stream
+	if len(mirrors) > 0 {
+		stationMirrors[*id] = mirrors
+	}
+	if errSa := save(streams, stationMirrors); errSa != nil {
+		return errSa
+	}
+	fmt.Fprintf(w, "stations: info: added station '%d' %s\n", *id, *name)
+	return nil
+}
+
+// edit changes the station fields
+func edit(w io.Writer, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("stations: error: missing station id\n")
+	}
+	streams, stationMirrors, release, errEs := editStations()
+	if errEs != nil {
+		return errEs
+	}
+	defer release()
+	id, errPi := parseId(streams, args[0])
+	if errPi != nil {
+		return errPi
+	}
+	fs := flag.NewFlagSet("stations edit", flag.ContinueOnError)
+	fs.SetOutput(w)
+	name := fs.String("name", streams[id]["name"], "station name")
+	nameIcy := fs.String("name-icy", streams[id]["nameIcy"], "station icy name")
+	streamUrl := fs.String("url", streams[id]["url"], "station stream url")
+	var mirrors urlsFlag
+	fs.Var(&mirrors, "mirror", "station mirror stream url played when the url fails, once per mirror, replaces the mirrors")
+	if errFp := fs.Parse(args[1:]); errFp != nil {
+		return errFp
+	}
+	mirrorSet := false
+	fs.Visit(func(f *flag.Flag) {
+		mirrorSet = mirrorSet || f.Name == "mirror"
+	})
+	if !mirrorSet {
+		mirrors = stationMirrors[id]
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("stations: error: unexpected argument '%s'\n", fs.Arg(0))
+	}
+	if errUu := urlUnused(streams, *streamUrl, id); errUu != nil {
+		return errUu
+	}
+	stream := map[string]string{"name": *name, "url": *streamUrl}
+	if *nameIcy != "" {
+		stream["nameIcy"] = *nameIcy
+	}
+	if errCs := config.CheckStation(id, stream, mirrors); errCs != nil {
+		return errCs
+	}
+	streams[id] = stream
+	delete(stationMirrors, id)
+	if len(mirrors) > 0 {
+		stationMirrors[id] = mirrors
+	}
+	if errSa := save(streams, stationMirrors); errSa != nil {
+		return errSa
+	}
+	fmt.Fprintf(w, "stations: info: edited station '%d' %s\n", id, *name)
+	return nil
+}
+
+// rename changes the station name
+func rename(w io.Writer, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("stations: error: usage: %s stations rename id name\n", config.ProgName)
+	}
+	streams, mirrors, release, errEs := editStations()
+	if errEs != nil {
+		return errEs
+	}
+	defer release()
+	id, errPi := parseId(streams, args[0])
+	if errPi != nil {
+		return errPi
+	}
+	name := strings.Join(args[1:], " ")
+	streams[id]["name"] = name
+	if errCs := config.CheckStation(id, streams[id], mirrors[id]); errCs != nil {
+		return errCs
+	}
+	if errSa := save(streams, mirrors); errSa != nil {
+		return errSa
+	}
+	fmt.Fprintf(w, "stations: info: renamed station '%d' to %s\n", id, name)
+	return nil
+}
+
+// move changes the station id
+func move(w io.Writer, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("stations: error: usage: %s stations move id newid\n", config.ProgName)
+	}
+	streams, mirrors, release, errEs := editStations()
+	if errEs != nil {
+		return errEs
+	}
+	defer release()
+	id, errPi := parseId(streams, args[0])
+	if errPi != nil {
+		return errPi
+	}
+	newId, errSa := strconv.Atoi(args[1])
+	if errSa != nil || newId < 1 {
+		return fmt.Errorf("stations: error: '%s' is not a valid station id\n", args[1])
+	}
+	if _, ok := streams[newId]; ok {
+		return fmt.Errorf("stations: error: station '%d' already exists\n", newId)
+	}
+	streams[newId] = streams[id]
+	delete(streams, id)
+	if urls, ok := mirrors[id]; ok {
+		mirrors[newId] = urls
+		delete(mirrors, id)
+	}
+	if errSa := save(streams, mirrors); errSa != nil {
+		return errSa
+	}
+	fmt.Fprintf(w, "stations: info: moved station '%d' to '%d'\n", id, newId)
+	return nil
+}
+
+// remove removes the station
+func remove(w io.Writer, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("stations: error: usage: %s stations remove id\n", config.ProgName)
+	}
+	streams, mirrors, release, errEs := editStations()
+	if errEs != nil {
+		return errEs
+	}
+	defer release()
+	id, errPi := parseId(streams, args[0])
+	if errPi != nil {
+		return errPi
+	}
+	name := streams[id]["name"]
+	delete(streams, id)
+	delete(mirrors, id)
+	if errSa := save(streams, mirrors); errSa != nil {
+		return errSa
+	}
+	fmt.Fprintf(w, "stations: info: removed station '%d' %s\n", id, name)
+	return nil
+}
@@ -0,0 +1,217 @@
+// by Gonzaru
+// Distributed under the terms of the GNU General Public License v3
+
+package stations
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// local packages
+import (
+	"github.com/gonzaru/gorum/config"
+)
+
+// setUpTest points the stations file to a temporary directory with two stations
+func setUpTest(t *testing.T) {
+	t.Helper()
+	streams, mirrors := config.Stations()
+	stationsFile := config.StationsFile
+	config.StationsFile = filepath.Join(t.TempDir(), "gorum", "stations.json")
+	config.SetStations(map[int]map[string]string{
+		1: {"name": "One", "url": "https://example.org/one"},
+		3: {"name": "Three", "nameIcy": "3", "url": "https://example.org/three"},
+	}, map[int][]string{})
+	t.Cleanup(func() {
+		config.SetStations(streams, mirrors)
+		config.StationsFile = stationsFile
+	})
+}
+
+// loadSaved loads the saved stations file and its mirror urls
+func loadSaved(t *testing.T) (map[int]map[string]string, map[int][]string) {
+	t.Helper()
+	streams, mirrors, err := config.LoadStations(config.StationsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return streams, mirrors
+}
+
+func TestAdd(t *testing.T) {
+	setUpTest(t)
+	if err := Run(io.Discard, []string{"add", "--name", "Four", "--url", "https://example.org/four"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Run(io.Discard, []string{"add", "--name", "Ten", "--url", "https://example.org/ten", "--id", "10", "--mirror", "https://a.org/ten%20fm", "--mirror", "https://b.org/ten"}); err != nil {
+		t.Fatal(err)
+	}
+	streams, mirrors := loadSaved(t)
+	if streams[4]["name"] != "Four" || streams[10]["name"] != "Ten" || streams[3]["nameIcy"] != "3" {
+		t.Fatalf("unexpected stations %v", streams)
+	}
+	if len(mirrors) != 1 || len(mirrors[10]) != 2 {
+		t.Fatalf("unexpected mirrors %v", mirrors)
+	}
+	if urls := config.StationUrls(10); strings.Join(urls, " ") != "https://example.org/ten https://a.org/ten%20fm https://b.org/ten" {
+		t.Fatalf("unexpected station urls %q", urls)
+	}
+	errs := [][]string{
+		{"add", "--name", "Dup", "--url", "https://example.org/one"},
+		{"add", "--name", "Bad", "--url", "not a url"},
+		{"add", "--name", "BadMirror", "--url", "https://example.org/mirror", "--mirror", "not-a-url"},
+		{"add", "--url", "https://example.org/noname"},
+		{"add", "--name", "Taken", "--url", "https://example.org/taken", "--id", "3"},
+	}
+	for _, args := range errs {
+		if err := Run(io.Discard, args); err == nil {
+			t.Fatalf("expected error for %q", args)
+		}
+	}
+	content, errRf := os.ReadFile(config.StationsFile)
+	if errRf != nil {
+		t.Fatal(errRf)
+	}
+	if strings.Index(string(content), `"4"`) > strings.Index(string(content), `"10"`) {
+		t.Fatalf("expected stations ordered by id:\n%s", content)
+	}
+}
+
+func TestAddOtherProcess(t *testing.T) {
+	setUpTest(t)
+	if err := Run(io.Discard, []string{"add", "--name", "Four", "--url", "https://example.org/four"}); err != nil {
+		t.Fatal(err)
+	}
+	// another process adds a station while holding the stations file lock
+	unlock, errLu := config.LockUpdate(config.StationsFile)
+	if errLu != nil {
+		t.Fatal(errLu)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(io.Discard, []string{"add", "--name", "Five", "--url", "https://example.org/five"})
+	}()
+	streams, mirrors := loadSaved(t)
+	streams[20] = map[string]string{"name": "Twenty", "url": "https://example.org/twenty"}
+	if err := config.SaveStations(config.StationsFile, streams, mirrors); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("expected the add to wait for the lock, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	streams, _ = loadSaved(t)
+	if streams[20]["name"] != "Twenty" || streams[21]["name"] != "Five" || len(streams) != 5 {
+		t.Fatalf("expected the stations of both processes, got %v", streams)
+	}
+	if station, _ := config.Station(20); station["name"] != "Twenty" {
+		t.Fatalf("expected the current stations reloaded, got %v", station)
+	}
+}
+
+func TestEditRenameMoveRemove(t *testing.T) {
+	setUpTest(t)
+	if err := Run(io.Discard, []string{"edit", "1", "--url", "https://example.org/uno"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Run(io.Discard, []string{"edit", "1", "--mirror", "https://a.org/uno", "--mirror", "https://b.org/uno"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Run(io.Discard, []string{"edit", "1", "--name", "Uno"}); err != nil {
+		t.Fatalf("expected the station to keep its own url, got %v", err)
+	}
+	if urls := config.StationUrls(1); len(urls) != 3 {
+		t.Fatalf("expected the station to keep its mirrors, got %q", urls)
+	}
+	if err := Run(io.Discard, []string{"edit", "3", "--mirror", "https://a.org/three"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Run(io.Discard, []string{"edit", "3", "--mirror", ""}); err != nil {
+		t.Fatal(err)
+	}
+	if urls := config.StationUrls(3); len(urls) != 1 {
+		t.Fatalf("expected the mirrors removed, got %q", urls)
+	}
+	if err := Run(io.Discard, []string{"edit", "3", "--url", "https://example.org/uno"}); err == nil {
+		t.Fatal("expected error editing a url used by another station")
+	}
+	if err := Run(io.Discard, []string{"rename", "1", "Station", "Uno"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Run(io.Discard, []string{"move", "1", "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Run(io.Discard, []string{"move", "2", "3"}); err == nil {
+		t.Fatal("expected error moving into an existing id")
+	}
+	if err := Run(io.Discard, []string{"remove", "3"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Run(io.Discard, []string{"remove", "3"}); err == nil {
+		t.Fatal("expected error removing a missing station")
+	}
+	streams, mirrors := loadSaved(t)
+	if len(streams) != 1 || streams[2]["name"] != "Station Uno" || streams[2]["url"] != "https://example.org/uno" {
+		t.Fatalf("unexpected stations %v", streams)
+	}
+	if len(mirrors) != 1 || strings.Join(mirrors[2], " ") != "https://a.org/uno https://b.org/uno" {
+		t.Fatalf("expected the mirrors moved with the station, got %v", mirrors)
+	}
+	var out bytes.Buffer
+	if err := List(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "2)  Station Uno  https://example.org/uno\n" {
+		t.Fatalf("unexpected list %q", out.String())
+	}
+}
+
+func TestImportExport(t *testing.T) {
+	setUpTest(t)
+	file := filepath.Join(t.TempDir(), "radios.m3u")
+	data := "#EXTM3U\n" +
+		"#EXTINF:-1,Duplicate\nhttps://example.org/one\n" +
+		"#EXTINF:-1,Four\nhttps://example.org/four\n" +
+		"local.mp3\n" +
+		"https://example.org/five/\n" +
+		"https://example.org/four\n"
+	if errWf := os.WriteFile(file, []byte(data), 0600); errWf != nil {
+		t.Fatal(errWf)
+	}
+	added, skipped, errIm := Import(file)
+	if errIm != nil {
+		t.Fatal(errIm)
+	}
+	if len(added) != 2 || len(skipped) != 3 {
+		t.Fatalf("unexpected import added %v skipped %v", added, skipped)
+	}
+	streams, _ := loadSaved(t)
+	if streams[4]["name"] != "Four" || streams[5]["name"] != "example.org/five" {
+		t.Fatalf("unexpected stations %v", streams)
+	}
+	var out bytes.Buffer
+	if err := Export(&out, "pls"); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"File1=https://example.org/one\n", "Title2=Three\n", "File4=https://example.org/five/\n", "NumberOfEntries=4\n"} {
+		if !strings.Contains(out.String(), line) {
+			t.Fatalf("expected %q in export, got:\n%s", line, out.String())
+		}
+	}
+	if err := Run(io.Discard, []string{"export", "--format", "wav"}); err == nil {
+		t.Fatal("expected error for an unsupported format")
+	}
+}
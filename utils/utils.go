@@ -6,15 +6,30 @@ package utils
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/url"
 	"os"
 	"os/exec"
 	"runtime"
+	"strconv"
 	"strings"
+	"syscall"
+	"unicode"
+	"unicode/utf8"
 )
 
+// Contains checks if the item is in the list
+func Contains(list []string, item string) bool {
+	for _, str := range list {
+		if str == item {
+			return true
+		}
+	}
+	return false
+}
+
 // CountDigit counts the number of digits in a number
 func CountDigit(num int) int {
 	count := 0
@@ -143,9 +158,39 @@ func KeyPressName(key []byte) (string, error) {
 	return keyName, nil
 }
 
+// OpenPrivate opens the file without following a symlink, it must be a regular file of the current user
+func OpenPrivate(file string, flag int, perm os.FileMode) (*os.File, error) {
+	fh, errOf := os.OpenFile(file, (flag&^os.O_TRUNC)|syscall.O_NOFOLLOW, perm)
+	if errors.Is(errOf, syscall.ELOOP) {
+		return nil, fmt.Errorf("OpenPrivate: error: '%s' is a symlink\n", file)
+	} else if errOf != nil {
+		return nil, errOf
+	}
+	fi, errFs := fh.Stat()
+	if errFs != nil {
+		fh.Close()
+		return nil, errFs
+	}
+	if !fi.Mode().IsRegular() {
+		fh.Close()
+		return nil, fmt.Errorf("OpenPrivate: error: '%s' is not a regular file\n", file)
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok && int(st.Uid) != os.Getuid() {
+		fh.Close()
+		return nil, fmt.Errorf("OpenPrivate: error: '%s' is not owned by the current user\n", file)
+	}
+	// truncates only after checking the owner
+	if flag&os.O_TRUNC != 0 {
+		if errFt := fh.Truncate(0); errFt != nil {
+			fh.Close()
+			return nil, errFt
+		}
+	}
+	return fh, nil
+}
+
 // PidFileExists checks if file and pid exist
 func PidFileExists(file string) (bool, error) {
-	status := false
 	if _, errSt := os.Stat(file); os.IsNotExist(errSt) {
 		return false, nil
 	} else if errSt != nil {
@@ -155,18 +200,13 @@ func PidFileExists(file string) (bool, error) {
 	if errRf != nil {
 		return false, errRf
 	}
-	pid := strings.TrimRight(string(content), "\n")
-	pidPath := "/proc/" + pid
-	fi, errOs := os.Stat(pidPath)
-	if os.IsNotExist(errOs) {
+	pid, errSa := strconv.Atoi(strings.TrimSpace(string(content)))
+	if errSa != nil || pid <= 0 {
 		return false, nil
-	} else if errOs != nil {
-		return false, errOs
 	}
-	if fi.IsDir() {
-		status = true
-	}
-	return status, nil
+	// the signal 0 only checks that the process exists, EPERM means it exists owned by another user
+	errSk := syscall.Kill(pid, 0)
+	return errSk == nil || errors.Is(errSk, syscall.EPERM), nil
 }
 
 // ValidUrl checks if it is a valid url format
@@ -177,3 +217,37 @@ func ValidUrl(str string) bool {
 	}
 	return status
 }
+
+// NaturalLess compares the strings in natural order, digit sequences by their numeric value
+func NaturalLess(a string, b string) bool {
+	for a != "" && b != "" {
+		aNum, bNum := digitPrefix(a), digitPrefix(b)
+		if aNum != "" && bNum != "" {
+			aTrim, bTrim := strings.TrimLeft(aNum, "0"), strings.TrimLeft(bNum, "0")
+			if len(aTrim) != len(bTrim) {
+				return len(aTrim) < len(bTrim)
+			}
+			if aTrim != bTrim {
+				return aTrim < bTrim
+			}
+			a, b = a[len(aNum):], b[len(bNum):]
+			continue
+		}
+		aRune, aSize := utf8.DecodeRuneInString(a)
+		bRune, bSize := utf8.DecodeRuneInString(b)
+		if aLow, bLow := unicode.ToLower(aRune), unicode.ToLower(bRune); aLow != bLow {
+			return aLow < bLow
+		}
+		a, b = a[aSize:], b[bSize:]
+	}
+	return len(a) < len(b)
+}
+
+// digitPrefix returns the leading ascii digits of the string
+func digitPrefix(str string) string {
+	num := 0
+	for num < len(str) && str[num] >= '0' && str[num] <= '9' {
+		num++
+	}
+	return str[:num]
+}